@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/auth"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store"
+	"github.com/google/uuid"
+)
+
+// roles are the values the users.role CHECK constraint allows.
+var roles = []string{"user", "moderator", "admin"}
+
+const adminUsage = `usage: chirpy admin <command> [flags]
+
+Commands:
+  create-user      -email EMAIL [-role ROLE]   password is read from stdin
+  reset-password   -user USER                  password is read from stdin
+  grant-red        -user USER
+  revoke-red       -user USER
+  set-role         -user USER -role ROLE
+  revoke-sessions  -user USER
+  delete-chirps    -user USER
+  purge-tokens
+  dead-jobs                                    jobs that ran out of attempts
+  retry-job        -job ID                     run a dead job again
+
+USER is an email address or a user ID. Every command also takes -o table|json.
+Nothing is saved unless -yes is given; without it the command runs in a
+transaction that is rolled back, so you can see what it would do.`
+
+// errDryRun rolls back the transaction of a command run without -yes.
+var errDryRun = errors.New("dry run")
+
+// adminCLI implements `chirpy admin`. It goes through the same queries as the
+// handlers, so constraints and events behave exactly as they do in the API.
+type adminCLI struct {
+	store  store.Store
+	events *events.Hub
+	in     io.Reader
+	out    io.Writer
+	errOut io.Writer
+}
+
+// adminUser is how users are printed. The password hash never leaves the
+// database.
+type adminUser struct {
+	ID          uuid.UUID `json:"id"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type adminJob struct {
+	ID        uuid.UUID       `json:"id"`
+	Kind      string          `json:"kind"`
+	State     string          `json:"state"`
+	Attempts  int32           `json:"attempts"`
+	LastError string          `json:"last_error"`
+	Payload   json.RawMessage `json:"payload"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+func (a *adminCLI) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(adminUsage)
+	}
+	command := args[0]
+
+	flags := flag.NewFlagSet("chirpy admin "+command, flag.ContinueOnError)
+	flags.SetOutput(a.errOut)
+	output := flags.String("o", "table", "output format: table or json")
+	yes := flags.Bool("yes", false, "save the changes instead of rolling them back")
+	userRef := flags.String("user", "", "email address or ID of the user")
+	email := flags.String("email", "", "email address of the new user")
+	role := flags.String("role", "", "role: "+strings.Join(roles, ", "))
+	jobRef := flags.String("job", "", "ID of a dead job")
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+	if *role != "" && !slices.Contains(roles, *role) {
+		return fmt.Errorf("unknown role %q, want one of %s", *role, strings.Join(roles, ", "))
+	}
+
+	// Read the password before the transaction takes any locks
+	var password string
+	if command == "create-user" || command == "reset-password" {
+		password, err = a.readPassword()
+		if err != nil {
+			return err
+		}
+	}
+
+	var result any
+	var author uuid.UUID
+	var deletedChirps []uuid.UUID
+	err = a.store.InTx(ctx, func(q database.Querier) error {
+		var err error
+		switch command {
+		case "create-user":
+			result, err = createUser(ctx, q, *email, password, *role)
+		case "reset-password":
+			result, err = resetPassword(ctx, q, *userRef, password)
+		case "grant-red", "revoke-red":
+			result, err = setRed(ctx, q, *userRef, command == "grant-red")
+		case "set-role":
+			result, err = setRole(ctx, q, *userRef, *role)
+		case "revoke-sessions":
+			result, err = revokeSessions(ctx, q, *userRef)
+		case "delete-chirps":
+			author, deletedChirps, err = deleteChirps(ctx, q, *userRef)
+			result = map[string]any{"user_id": author, "deleted_chirps": len(deletedChirps)}
+		case "purge-tokens":
+			var purged int64
+			purged, err = q.DeleteExpiredRefreshTokens(ctx)
+			result = map[string]any{"purged_tokens": purged}
+		case "dead-jobs":
+			result, err = deadJobs(ctx, q)
+		case "retry-job":
+			result, err = retryJob(ctx, q, *jobRef)
+		default:
+			err = fmt.Errorf("unknown command %q\n\n%s", command, adminUsage)
+		}
+		if err != nil {
+			return err
+		}
+		if !*yes {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return err
+	}
+
+	err = a.print(*output, result)
+	if err != nil {
+		return err
+	}
+	if !*yes {
+		fmt.Fprintln(a.errOut, "Dry run: nothing was saved. Re-run with -yes to apply.")
+		return nil
+	}
+
+	// Tell connected clients, as deleting through the API would
+	for _, id := range deletedChirps {
+		err = a.events.Publish(ctx, events.ChirpDeleted, author, uuid.Nil, map[string]uuid.UUID{
+			"id": id,
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("Couldn't publish chirp event", "err", err)
+		}
+	}
+	return nil
+}
+
+// readPassword reads a single line from stdin, so passwords stay out of the
+// shell history and the process list.
+func (a *adminCLI) readPassword() (string, error) {
+	line, err := bufio.NewReader(a.in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password on stdin")
+	}
+	return password, nil
+}
+
+func createUser(ctx context.Context, q database.Querier, email, password, role string) (any, error) {
+	if email == "" {
+		return nil, errors.New("-email is required")
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user, err := q.CreateUser(ctx, database.CreateUserParams{
+		Email:          email,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create user: %w", err)
+	}
+	if role != "" {
+		user, err = q.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: role})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newAdminUser(user), nil
+}
+
+// resetPassword also signs the user out everywhere, since a reset usually
+// means someone else knows the old password.
+func resetPassword(ctx context.Context, q database.Querier, ref, password string) (any, error) {
+	user, err := findUser(ctx, q, ref)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user, err = q.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             user.ID,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = q.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return newAdminUser(user), nil
+}
+
+func setRed(ctx context.Context, q database.Querier, ref string, red bool) (any, error) {
+	user, err := findUser(ctx, q, ref)
+	if err != nil {
+		return nil, err
+	}
+	user, err = q.SetUserRed(ctx, database.SetUserRedParams{ID: user.ID, IsChirpyRed: red})
+	if err != nil {
+		return nil, err
+	}
+	return newAdminUser(user), nil
+}
+
+func setRole(ctx context.Context, q database.Querier, ref, role string) (any, error) {
+	if role == "" {
+		return nil, errors.New("-role is required")
+	}
+	user, err := findUser(ctx, q, ref)
+	if err != nil {
+		return nil, err
+	}
+	user, err = q.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: role})
+	if err != nil {
+		return nil, err
+	}
+	return newAdminUser(user), nil
+}
+
+// revokeSessions revokes every refresh token the user holds. Access tokens
+// already handed out stay valid until they expire, at most an hour later.
+func revokeSessions(ctx context.Context, q database.Querier, ref string) (any, error) {
+	user, err := findUser(ctx, q, ref)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := q.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"user_id": user.ID, "revoked_sessions": revoked}, nil
+}
+
+// deleteChirps deletes every chirp by the user and returns the user's ID
+// along with the IDs of the deleted chirps.
+func deleteChirps(ctx context.Context, q database.Querier, ref string) (uuid.UUID, []uuid.UUID, error) {
+	user, err := findUser(ctx, q, ref)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	ids, err := q.DeleteUserChirps(ctx, user.ID)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	return user.ID, ids, nil
+}
+
+func deadJobs(ctx context.Context, q database.Querier) (any, error) {
+	dead, err := q.GetDeadJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]adminJob, 0, len(dead))
+	for _, job := range dead {
+		jobs = append(jobs, newAdminJob(job))
+	}
+	return jobs, nil
+}
+
+func retryJob(ctx context.Context, q database.Querier, ref string) (any, error) {
+	id, err := uuid.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("-job must be a job ID: %w", err)
+	}
+	job, err := q.RequeueDeadJob(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no dead job %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []adminJob{newAdminJob(job)}, nil
+}
+
+// findUser looks a user up by ID or, failing that, by email.
+func findUser(ctx context.Context, q database.Querier, ref string) (database.User, error) {
+	if ref == "" {
+		return database.User{}, errors.New("-user is required")
+	}
+	var user database.User
+	id, err := uuid.Parse(ref)
+	if err == nil {
+		user, err = q.GetUserFromID(ctx, id)
+	} else {
+		user, err = q.GetUser(ctx, ref)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("no user %q", ref)
+	}
+	return user, err
+}
+
+func newAdminUser(user database.User) adminUser {
+	return adminUser{
+		ID:          user.ID,
+		Email:       user.Email,
+		Role:        user.Role,
+		IsChirpyRed: user.IsChirpyRed,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+	}
+}
+
+func newAdminJob(job database.Job) adminJob {
+	return adminJob{
+		ID:        job.ID,
+		Kind:      job.Kind,
+		State:     job.State,
+		Attempts:  job.Attempts,
+		LastError: job.LastError.String,
+		Payload:   job.Payload,
+		UpdatedAt: job.UpdatedAt,
+	}
+}
+
+func (a *adminCLI) print(format string, result any) error {
+	if format == "json" {
+		enc := json.NewEncoder(a.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	switch v := result.(type) {
+	case adminUser:
+		fmt.Fprintln(tw, "ID\tEMAIL\tROLE\tRED\tCREATED AT")
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\n", v.ID, v.Email, v.Role, v.IsChirpyRed, v.CreatedAt.Format(time.DateTime))
+	case []adminJob:
+		fmt.Fprintln(tw, "ID\tKIND\tSTATE\tATTEMPTS\tUPDATED AT\tLAST ERROR")
+		for _, job := range v {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", job.ID, job.Kind, job.State, job.Attempts, job.UpdatedAt.Format(time.DateTime), job.LastError)
+		}
+	case map[string]any:
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			fmt.Fprintf(tw, "%s\t%v\n", key, v[key])
+		}
+	}
+	return tw.Flush()
+}
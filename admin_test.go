@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/jobs"
+)
+
+// admin runs `chirpy admin args...` against the test server's store and
+// returns what it printed.
+func (ts *testServer) admin(stdin string, args ...string) (string, error) {
+	ts.t.Helper()
+	var out, errOut bytes.Buffer
+	cli := &adminCLI{
+		store:  ts.store,
+		events: events.NewHub(ts.store),
+		in:     strings.NewReader(stdin),
+		out:    &out,
+		errOut: &errOut,
+	}
+	err := cli.run(context.Background(), args)
+	return out.String(), err
+}
+
+func TestAdminUsers(t *testing.T) {
+	ts := newTestServer(t)
+
+	out, err := ts.admin("", "create-user", "-email", "saul@goodman.com", "-o", "json")
+	if err == nil {
+		t.Fatalf("create-user without a password succeeded: %s", out)
+	}
+
+	// Without -yes nothing is saved
+	_, err = ts.admin(testPassword+"\n", "create-user", "-email", "saul@goodman.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials := map[string]string{"email": "saul@goodman.com", "password": testPassword}
+	expect[any](t, ts.do("POST", "/api/login", "", credentials), http.StatusUnauthorized)
+
+	out, err = ts.admin(testPassword+"\n", "create-user", "-email", "saul@goodman.com", "-role", "moderator", "-o", "json", "-yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created adminUser
+	err = json.Unmarshal([]byte(out), &created)
+	if err != nil {
+		t.Fatalf("Couldn't decode %q: %v", out, err)
+	}
+	if created.Email != "saul@goodman.com" || created.Role != "moderator" {
+		t.Errorf("created %+v", created)
+	}
+	login := expect[User](t, ts.do("POST", "/api/login", "", credentials), http.StatusOK)
+
+	tests := []struct {
+		name    string
+		stdin   string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Grant Red by ID",
+			args: []string{"grant-red", "-user", created.ID.String()},
+			want: "true",
+		},
+		{
+			name: "Revoke Red by email",
+			args: []string{"revoke-red", "-user", "saul@goodman.com"},
+			want: "false",
+		},
+		{
+			name: "Promote",
+			args: []string{"set-role", "-user", "saul@goodman.com", "-role", "admin"},
+			want: "admin",
+		},
+		{
+			name:    "Unknown role",
+			args:    []string{"set-role", "-user", "saul@goodman.com", "-role", "overlord"},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown user",
+			args:    []string{"grant-red", "-user", "kim@wexler.com"},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown command",
+			args:    []string{"launder"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := ts.admin(tt.stdin, append(tt.args, "-yes")...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("admin %v error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("output %q doesn't contain %q", out, tt.want)
+			}
+		})
+	}
+
+	// Resetting the password signs the user out everywhere
+	_, err = ts.admin("better-call-saul\n", "reset-password", "-user", "saul@goodman.com", "-yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect[any](t, ts.do("POST", "/api/login", "", credentials), http.StatusUnauthorized)
+	expect[any](t, ts.do("POST", "/api/refresh", login.RefreshToken, nil), http.StatusUnauthorized)
+	credentials["password"] = "better-call-saul"
+	expect[User](t, ts.do("POST", "/api/login", "", credentials), http.StatusOK)
+}
+
+func TestAdminSessionsAndChirps(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+	ts.chirp(walt.Token, "Say my name")
+	ts.chirp(walt.Token, "I am the danger")
+	kept := ts.chirp(jesse.Token, "Yeah science!")
+
+	out, err := ts.admin("", "delete-chirps", "-user", "walt@breakingbad.com")
+	if err != nil || !strings.Contains(out, "2") {
+		t.Fatalf("dry run = %q, %v", out, err)
+	}
+	chirps := expect[[]Chirp](t, ts.do("GET", "/api/chirps", "", nil), http.StatusOK)
+	if len(chirps) != 3 {
+		t.Fatalf("dry run deleted chirps: %v", chirpBodies(chirps))
+	}
+
+	_, err = ts.admin("", "delete-chirps", "-user", "walt@breakingbad.com", "-yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirps = expect[[]Chirp](t, ts.do("GET", "/api/chirps", "", nil), http.StatusOK)
+	if !sameChirps(chirps, []Chirp{kept}) {
+		t.Errorf("got %v, want only Jesse's chirp", chirpBodies(chirps))
+	}
+
+	_, err = ts.admin("", "revoke-sessions", "-user", "walt@breakingbad.com", "-yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect[any](t, ts.do("POST", "/api/refresh", walt.RefreshToken, nil), http.StatusUnauthorized)
+	expect[any](t, ts.do("POST", "/api/refresh", jesse.RefreshToken, nil), http.StatusOK)
+
+	// Walt's revoked token is purged, Jesse's live one survives
+	out, err = ts.admin("", "purge-tokens", "-o", "json", "-yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var purged map[string]int64
+	err = json.Unmarshal([]byte(out), &purged)
+	if err != nil || purged["purged_tokens"] != 1 {
+		t.Errorf("purge-tokens = %q, %v", out, err)
+	}
+	expect[any](t, ts.do("POST", "/api/refresh", jesse.RefreshToken, nil), http.StatusOK)
+}
+
+func TestAdminDeadJobs(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	err := jobs.Enqueue(ctx, ts.store, "doomed", nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := ts.store.ClaimJob(ctx, sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ts.store.KillJob(ctx, database.KillJobParams{
+		ID:        job.ID,
+		Attempts:  job.Attempts,
+		LastError: sql.NullString{String: "out of cheese", Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ts.admin("", "dead-jobs")
+	if err != nil || !strings.Contains(out, "out of cheese") {
+		t.Fatalf("dead-jobs = %q, %v", out, err)
+	}
+
+	_, err = ts.admin("", "retry-job", "-job", job.ID.String(), "-yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = ts.admin("", "dead-jobs", "-o", "json")
+	if err != nil || strings.TrimSpace(out) != "[]" {
+		t.Errorf("dead-jobs after retry = %q, %v", out, err)
+	}
+	_, err = ts.admin("", "retry-job", "-job", job.ID.String(), "-yes")
+	if err == nil {
+		t.Error("retried a job that isn't dead")
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/auth"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/google/uuid"
+)
+
+type Block struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Mute struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) blockUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+	target, ok := cfg.targetUser(w, r, id)
+	if !ok {
+		return
+	}
+
+	err := cfg.database.CreateBlock(r.Context(), database.CreateBlockParams{
+		BlockerID: id,
+		BlockedID: target,
+	})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't block user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getBlocks(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	blocks, err := cfg.database.GetBlocks(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't get blocks", err)
+		return
+	}
+
+	respBody := []Block{}
+	for _, block := range blocks {
+		respBody = append(respBody, Block{
+			UserID:    block.BlockedID,
+			CreatedAt: block.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, respBody)
+}
+
+func (cfg *apiConfig) unblockUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+	target, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, errInvalidID, "userID isn't a valid UUID", err)
+		return
+	}
+
+	deleted, err := cfg.database.DeleteBlock(r.Context(), database.DeleteBlockParams{
+		BlockerID: id,
+		BlockedID: target,
+	})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't unblock user", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, r, errNotFound, "User is not blocked", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) muteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+	target, ok := cfg.targetUser(w, r, id)
+	if !ok {
+		return
+	}
+
+	err := cfg.database.CreateMute(r.Context(), database.CreateMuteParams{
+		MuterID: id,
+		MutedID: target,
+	})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't mute user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getMutes(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	mutes, err := cfg.database.GetMutes(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't get mutes", err)
+		return
+	}
+
+	respBody := []Mute{}
+	for _, mute := range mutes {
+		respBody = append(respBody, Mute{
+			UserID:    mute.MutedID,
+			CreatedAt: mute.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, respBody)
+}
+
+func (cfg *apiConfig) unmuteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+	target, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, errInvalidID, "userID isn't a valid UUID", err)
+		return
+	}
+
+	deleted, err := cfg.database.DeleteMute(r.Context(), database.DeleteMuteParams{
+		MuterID: id,
+		MutedID: target,
+	})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't unmute user", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, r, errNotFound, "User is not muted", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticate validates the access JWT on the request and writes a 401 when
+// it is missing or invalid.
+func (cfg *apiConfig) authenticate(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, errUnauthorized, "Authorization header failed", err)
+		return uuid.Nil, false
+	}
+	id, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, r, errUnauthorized, "JWT validation failed", err)
+		return uuid.Nil, false
+	}
+	setRequestUser(r.Context(), id)
+	return id, true
+}
+
+// optionalViewer returns the ID of the authenticated user, or uuid.Nil when the
+// request carries no Authorization header. uuid.Nil never matches a block or
+// mute, so anonymous readers see everything.
+func (cfg *apiConfig) optionalViewer(r *http.Request) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	id, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	setRequestUser(r.Context(), id)
+	return id, nil
+}
+
+// targetUser decodes the {"user_id": ...} body shared by the block and mute
+// endpoints and checks that the user exists and isn't the caller.
+func (cfg *apiConfig) targetUser(w http.ResponseWriter, r *http.Request, self uuid.UUID) (uuid.UUID, bool) {
+	type parameters struct {
+		UserID string `json:"user_id" validate:"required,uuid"`
+	}
+	params, ok := decode[parameters](w, r)
+	if !ok {
+		return uuid.Nil, false
+	}
+
+	target := uuid.MustParse(params.UserID)
+	if target == self {
+		respondWithInvalid(w, r, fieldError{Pointer: "/user_id", Code: "self", Detail: "Can't target yourself"})
+		return uuid.Nil, false
+	}
+
+	_, err := cfg.database.GetUserFromID(r.Context(), target)
+	if err != nil {
+		respondWithError(w, r, errNotFound, "Couldn't find user", err)
+		return uuid.Nil, false
+	}
+	return target, true
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBlocksHideChirps(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	hank := ts.signUp("hank@dea.gov")
+	jesse := ts.signUp("jesse@breakingbad.com")
+
+	waltChirp := ts.chirp(walt.Token, "I am the danger")
+	hankChirp := ts.chirp(hank.Token, "Tread lightly")
+	jesseChirp := ts.chirp(jesse.Token, "Yeah, science!")
+
+	expect[any](t, ts.do("POST", "/api/blocks", walt.Token, map[string]string{"user_id": hank.ID.String()}), http.StatusNoContent)
+
+	tests := []struct {
+		name  string
+		token string
+		want  []Chirp
+	}{
+		{
+			name:  "Blocker doesn't see the blocked user",
+			token: walt.Token,
+			want:  []Chirp{waltChirp, jesseChirp},
+		},
+		{
+			name:  "Blocked user doesn't see the blocker",
+			token: hank.Token,
+			want:  []Chirp{hankChirp, jesseChirp},
+		},
+		{
+			name:  "Others see everyone",
+			token: jesse.Token,
+			want:  []Chirp{waltChirp, hankChirp, jesseChirp},
+		},
+		{
+			name: "Anonymous viewers see everyone",
+			want: []Chirp{waltChirp, hankChirp, jesseChirp},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chirps := expect[[]Chirp](t, ts.do("GET", "/api/chirps", tt.token, nil), http.StatusOK)
+			if !sameChirps(chirps, tt.want) {
+				t.Errorf("got %v, want %v", chirpBodies(chirps), chirpBodies(tt.want))
+			}
+		})
+	}
+
+	// Single chirps are hidden too
+	expect[any](t, ts.do("GET", "/api/chirps/"+hankChirp.ID.String(), walt.Token, nil), http.StatusNotFound)
+
+	// Unblocking restores the timeline
+	expect[any](t, ts.do("DELETE", "/api/blocks/"+hank.ID.String(), walt.Token, nil), http.StatusNoContent)
+	chirps := expect[[]Chirp](t, ts.do("GET", "/api/chirps", walt.Token, nil), http.StatusOK)
+	if !sameChirps(chirps, []Chirp{waltChirp, hankChirp, jesseChirp}) {
+		t.Errorf("after unblocking got %v", chirpBodies(chirps))
+	}
+	expect[any](t, ts.do("DELETE", "/api/blocks/"+hank.ID.String(), walt.Token, nil), http.StatusNotFound)
+}
+
+func TestMutesHideChirps(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	skyler := ts.signUp("skyler@breakingbad.com")
+
+	waltChirp := ts.chirp(walt.Token, "I did it for me")
+	skylerChirp := ts.chirp(skyler.Token, "Someone has to protect this family")
+
+	expect[any](t, ts.do("POST", "/api/mutes", walt.Token, map[string]string{"user_id": skyler.ID.String()}), http.StatusNoContent)
+
+	// Muting is one-way
+	chirps := expect[[]Chirp](t, ts.do("GET", "/api/chirps", walt.Token, nil), http.StatusOK)
+	if !sameChirps(chirps, []Chirp{waltChirp}) {
+		t.Errorf("muter got %v", chirpBodies(chirps))
+	}
+	chirps = expect[[]Chirp](t, ts.do("GET", "/api/chirps", skyler.Token, nil), http.StatusOK)
+	if !sameChirps(chirps, []Chirp{waltChirp, skylerChirp}) {
+		t.Errorf("muted user got %v", chirpBodies(chirps))
+	}
+
+	mutes := expect[[]Mute](t, ts.do("GET", "/api/mutes", walt.Token, nil), http.StatusOK)
+	if len(mutes) != 1 || mutes[0].UserID != skyler.ID {
+		t.Errorf("got mutes %v, want only %v", mutes, skyler.ID)
+	}
+
+	// Users can't mute themselves
+	expect[any](t, ts.do("POST", "/api/mutes", walt.Token, map[string]string{"user_id": walt.ID.String()}), http.StatusBadRequest)
+}
@@ -1,156 +1,329 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"database/sql"
+	"errors"
 	"net/http"
 	"sort"
 	"strings"
 	"time"
 
-	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/auth"
 	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
 	"github.com/google/uuid"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
+
+// A chirp is written as a draft, scheduled for later, or published straight
+// away. Only published chirps are visible to anyone but their author.
+const (
+	chirpDraft     = "draft"
+	chirpScheduled = "scheduled"
+	chirpPublished = "published"
+)
+
+func chirpResponse(chirp database.Chirp) Chirp {
+	resp := Chirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+		Status:    chirp.Status,
+	}
+	if chirp.PublishAt.Valid {
+		resp.PublishAt = &chirp.PublishAt.Time
+	}
+	return resp
+}
+
+// chirpParameters is the body of POST /api/chirps and PUT /api/chirps/{id}.
+type chirpParameters struct {
+	Body string `json:"body" validate:"required,max=140"`
+	// Draft keeps the chirp private until it's edited again
+	Draft bool `json:"draft"`
+	// PublishAt schedules the chirp; a time that has passed publishes it now
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// status works out where the chirp goes and, for a scheduled chirp, when.
+// The time is in UTC: Postgres keeps publish_at in a TIMESTAMP column, which
+// would drop any other offset and publish the chirp at the wrong instant.
+func (p chirpParameters) status() (string, sql.NullTime) {
+	switch {
+	case p.Draft:
+		return chirpDraft, sql.NullTime{}
+	case p.PublishAt != nil && p.PublishAt.After(time.Now()):
+		return chirpScheduled, sql.NullTime{Time: p.PublishAt.UTC(), Valid: true}
+	}
+	return chirpPublished, sql.NullTime{}
+}
+
+func (p chirpParameters) validate() []fieldError {
+	if p.Draft && p.PublishAt != nil {
+		return []fieldError{{Pointer: "/publish_at", Code: "not_allowed", Detail: "A draft can't have a publish time"}}
+	}
+	return nil
 }
 
 func (cfg *apiConfig) getChirps(w http.ResponseWriter, r *http.Request) {
-	var respBody []Chirp
-	authorID := r.URL.Query().Get("author_id")
-	sortType := r.URL.Query().Get("sort")
-	var chirps []database.Chirp
-	var err error
+	// Blocks and mutes are filtered in SQL for the authenticated viewer
+	viewer, err := cfg.optionalViewer(r)
+	if err != nil {
+		respondWithError(w, r, errUnauthorized, "JWT validation failed", err)
+		return
+	}
 
-	if authorID != "" {
-		id, err := uuid.Parse(authorID)
-		if err != nil {
-			respondWithError(w, http.StatusNotFound, "Invalid uuid", err)
-			return
-		}
-		chirps, err = cfg.database.GetAuthorChirps(r.Context(), id)
+	var authorID uuid.UUID
+	if s := r.URL.Query().Get("author_id"); s != "" {
+		authorID, err = uuid.Parse(s)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
+			respondWithError(w, r, errInvalidParameter, "author_id isn't a valid UUID", err)
 			return
 		}
+	}
+
+	chirps, err := cfg.listChirps(r.Context(), viewer, authorID, r.URL.Query().Get("sort") == "desc")
+	if err != nil {
+		respondWithFailure(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirps)
+}
+
+// listChirps returns the published chirps the viewer may see, oldest first
+// unless descending, and only authorID's unless that's uuid.Nil.
+func (cfg *apiConfig) listChirps(ctx context.Context, viewer, authorID uuid.UUID, descending bool) ([]Chirp, error) {
+	var chirps []database.Chirp
+	var err error
+	if authorID != uuid.Nil {
+		chirps, err = cfg.database.GetAuthorChirps(ctx, database.GetAuthorChirpsParams{
+			UserID:   authorID,
+			ViewerID: viewer,
+		})
 	} else {
-		chirps, err = cfg.database.GetChirps(r.Context())
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
-			return
-		}
+		chirps, err = cfg.database.GetChirps(ctx, viewer)
+	}
+	if err != nil {
+		return nil, failure(errInternal, "Couldn't get chirps", err)
 	}
 
-	if sortType == "desc" {
+	if descending {
 		sort.Slice(chirps,
 			func(i, j int) bool { return chirps[i].CreatedAt.After(chirps[j].CreatedAt) },
 		)
 	}
 
+	var resp []Chirp
 	for _, chirp := range chirps {
-		respBody = append(respBody, Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		},
-		)
+		resp = append(resp, chirpResponse(chirp))
 	}
-
-	respondWithJSON(w, http.StatusOK, respBody)
+	return resp, nil
 }
 
 func (cfg *apiConfig) getChirp(w http.ResponseWriter, r *http.Request) {
 	chirpIDString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIDString)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Invalid uuid", err)
+		respondWithError(w, r, errInvalidID, "chirpID isn't a valid UUID", err)
 		return
 	}
 
-	chirp, err := cfg.database.GetChirp(r.Context(), chirpID)
+	viewer, err := cfg.optionalViewer(r)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		respondWithError(w, r, errUnauthorized, "JWT validation failed", err)
 		return
 	}
 
-	respBody := Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	chirp, err := cfg.visibleChirp(r.Context(), viewer, chirpID)
+	if err != nil {
+		respondWithFailure(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirp)
+}
+
+// visibleChirp returns a published chirp, unless the viewer and its author
+// have blocked each other.
+func (cfg *apiConfig) visibleChirp(ctx context.Context, viewer, id uuid.UUID) (Chirp, error) {
+	chirp, err := cfg.database.GetVisibleChirp(ctx, database.GetVisibleChirpParams{
+		ID:       id,
+		ViewerID: viewer,
+	})
+	if err != nil {
+		return Chirp{}, failure(errNotFound, "Couldn't get chirp", err)
 	}
-	respondWithJSON(w, http.StatusOK, respBody)
+	return chirpResponse(chirp), nil
 }
 
 func (cfg *apiConfig) newChirp(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Body string `json:"body"`
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err := decoder.Decode(&params)
+	params, ok := decode[chirpParameters](w, r)
+	if !ok {
+		return
+	}
+
+	chirp, err := cfg.createChirp(r.Context(), id, params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithFailure(w, r, err)
 		return
 	}
+	respondWithJSON(w, http.StatusCreated, chirp)
+}
 
-	token, err := auth.GetBearerToken(r.Header)
+// createChirp saves the author's chirp, and tells streaming clients if it's
+// been published.
+func (cfg *apiConfig) createChirp(ctx context.Context, author uuid.UUID, params chirpParameters) (Chirp, error) {
+	// A scheduled chirp and the job that publishes it are saved together
+	status, publishAt := params.status()
+	var chirp database.Chirp
+	err := cfg.database.InTx(ctx, func(q database.Querier) error {
+		var err error
+		chirp, err = q.CreateChirp(ctx, database.CreateChirpParams{
+			Body:      cleanProfanity(params.Body),
+			UserID:    author,
+			Status:    status,
+			PublishAt: publishAt,
+		})
+		if err != nil {
+			return err
+		}
+		return schedulePublish(ctx, q, chirp)
+	})
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Authorization header failed", err)
+		return Chirp{}, failure(errInternal, "Couldn't create chirp", err)
+	}
+	cfg.metrics.ChirpsCreated.WithLabelValues(chirp.Status).Inc()
+
+	resp := chirpResponse(chirp)
+	if chirp.Status == chirpPublished {
+		cfg.publishChirpCreated(ctx, resp)
+	}
+	return resp, nil
+}
+
+func (cfg *apiConfig) getDrafts(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
 		return
 	}
-	id, err := auth.ValidateJWT(token, cfg.secret)
+
+	drafts, err := cfg.drafts(r.Context(), id)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "JWT validation failed", err)
+		respondWithFailure(w, r, err)
 		return
 	}
+	respondWithJSON(w, http.StatusOK, drafts)
+}
+
+// drafts returns the author's drafts and scheduled chirps.
+func (cfg *apiConfig) drafts(ctx context.Context, author uuid.UUID) ([]Chirp, error) {
+	drafts, err := cfg.database.GetDrafts(ctx, author)
+	if err != nil {
+		return nil, failure(errInternal, "Couldn't get drafts", err)
+	}
+
+	resp := make([]Chirp, 0, len(drafts))
+	for _, draft := range drafts {
+		resp = append(resp, chirpResponse(draft))
+	}
+	return resp, nil
+}
 
-	if len(params.Body) > 140 {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+func (cfg *apiConfig) updateDraft(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
 		return
 	}
-	cleanBody := cleanProfanity(params.Body)
 
-	chirp, err := cfg.database.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   cleanBody,
-		UserID: id,
-	},
-	)
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		respondWithError(w, r, errInvalidID, "chirpID isn't a valid UUID", err)
 		return
 	}
 
-	respBody := Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	params, ok := decode[chirpParameters](w, r)
+	if !ok {
+		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, respBody)
+	chirp, err := cfg.editDraft(r.Context(), id, chirpID, params)
+	if err != nil {
+		respondWithFailure(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirp)
 }
 
-func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
-	// Authenticate user
-	token, err := auth.GetBearerToken(r.Header)
+// editDraft rewrites one of the author's unpublished chirps, which may
+// publish or schedule it.
+func (cfg *apiConfig) editDraft(ctx context.Context, author, chirpID uuid.UUID, params chirpParameters) (Chirp, error) {
+	chirp, err := cfg.database.GetChirp(ctx, chirpID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Authorization header failed", err)
-		return
+		return Chirp{}, failure(errNotFound, "Couldn't get chirp", err)
+	}
+	if chirp.UserID != author {
+		return Chirp{}, failure(errForbidden, "Not the chirp author", nil)
+	}
+
+	status, publishAt := params.status()
+	err = cfg.database.InTx(ctx, func(q database.Querier) error {
+		var err error
+		chirp, err = q.UpdateDraft(ctx, database.UpdateDraftParams{
+			ID:        chirpID,
+			Body:      cleanProfanity(params.Body),
+			Status:    status,
+			PublishAt: publishAt,
+		})
+		if err != nil {
+			return err
+		}
+		return schedulePublish(ctx, q, chirp)
+	})
+	// Published chirps are final, including ones published while this
+	// request was on its way
+	if errors.Is(err, sql.ErrNoRows) {
+		return Chirp{}, failure(errConflict, "Chirp is already published", err)
 	}
-	id, err := auth.ValidateJWT(token, cfg.secret)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "JWT validation failed", err)
+		return Chirp{}, failure(errInternal, "Couldn't update chirp", err)
+	}
+
+	resp := chirpResponse(chirp)
+	if chirp.Status == chirpPublished {
+		cfg.publishChirpCreated(ctx, resp)
+	}
+	return resp, nil
+}
+
+// publishChirpCreated tells streaming clients about a chirp that just
+// entered the timeline.
+func (cfg *apiConfig) publishChirpCreated(ctx context.Context, chirp Chirp) {
+	err := cfg.events.Publish(ctx, events.ChirpCreated, chirp.UserID, uuid.Nil, chirp)
+	if err != nil {
+		logging.FromContext(ctx).Error("Couldn't publish chirp event", "err", err)
+	}
+}
+
+func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
+	// Authenticate user
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
 		return
 	}
 
@@ -158,33 +331,54 @@ func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
 	chirpIDString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIDString)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Invalid uuid", err)
+		respondWithError(w, r, errInvalidID, "chirpID isn't a valid UUID", err)
 		return
 	}
 
-	chirp, err := cfg.database.GetChirp(r.Context(), chirpID)
+	err = cfg.removeChirp(r.Context(), id, chirpID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		respondWithFailure(w, r, err)
 		return
 	}
 
+	// Return status code
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeChirp deletes one of the author's chirps.
+func (cfg *apiConfig) removeChirp(ctx context.Context, author, chirpID uuid.UUID) error {
+	chirp, err := cfg.database.GetChirp(ctx, chirpID)
+	if err != nil {
+		return failure(errNotFound, "Couldn't get chirp", err)
+	}
+
 	// Check user is chirp author
-	if chirp.UserID != id {
-		respondWithError(w, http.StatusForbidden, "Not the chirp author", err)
-		return
+	if chirp.UserID != author {
+		return failure(errForbidden, "Not the chirp author", nil)
 	}
 
 	// Delete the chirp
-	err = cfg.database.DeleteChirp(r.Context(), chirp.ID)
+	err = cfg.database.DeleteChirp(ctx, chirp.ID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
-		return
+		return failure(errInternal, "Couldn't delete chirp", err)
 	}
 
-	// Return status code
-	w.WriteHeader(http.StatusNoContent)
+	// Nobody else has seen an unpublished chirp, so there's nothing to retract
+	if chirp.Status == chirpPublished {
+		err = cfg.events.Publish(ctx, events.ChirpDeleted, chirp.UserID, uuid.Nil, map[string]uuid.UUID{
+			"id": chirp.ID,
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("Couldn't publish chirp event", "err", err)
+		}
+	}
+	return nil
 }
 
+// maxBodyLength is the most characters a chirp or message may have. The
+// validate tags on their bodies repeat it.
+const maxBodyLength = 140
+
 func cleanProfanity(str string) string {
 	temp := str
 	temp = strings.ToLower(temp)
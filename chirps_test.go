@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/jobs"
+)
+
+func TestNewChirp(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signUp("walt@breakingbad.com")
+
+	tests := []struct {
+		name     string
+		token    string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid chirp",
+			token:    user.Token,
+			body:     "I am the one who knocks",
+			wantCode: http.StatusCreated,
+			wantBody: "I am the one who knocks",
+		},
+		{
+			name:     "Profanity is cleaned",
+			token:    user.Token,
+			body:     "What a Kerfuffle this is, sharbert!",
+			wantCode: http.StatusCreated,
+			wantBody: "What a **** this is, sharbert!",
+		},
+		{
+			name:     "Too long",
+			token:    user.Token,
+			body:     strings.Repeat("a", maxBodyLength+1),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "No token",
+			body:     "Say my name",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Bad token",
+			token:    "not-a-jwt",
+			body:     "Say my name",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := ts.do("POST", "/api/chirps", tt.token, map[string]string{"body": tt.body})
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusCreated {
+				return
+			}
+			chirp := expect[Chirp](t, resp, http.StatusCreated)
+			if chirp.Body != tt.wantBody {
+				t.Errorf("got body %q, want %q", chirp.Body, tt.wantBody)
+			}
+			if chirp.UserID != user.ID {
+				t.Errorf("got author %v, want %v", chirp.UserID, user.ID)
+			}
+		})
+	}
+}
+
+func TestGetChirps(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+
+	first := ts.chirp(walt.Token, "first")
+	second := ts.chirp(jesse.Token, "second")
+	third := ts.chirp(walt.Token, "third")
+
+	tests := []struct {
+		name  string
+		query string
+		want  []Chirp
+	}{
+		{
+			name: "Oldest first by default",
+			want: []Chirp{first, second, third},
+		},
+		{
+			name:  "Newest first",
+			query: "?sort=desc",
+			want:  []Chirp{third, second, first},
+		},
+		{
+			name:  "By author",
+			query: "?author_id=" + walt.ID.String(),
+			want:  []Chirp{first, third},
+		},
+		{
+			name:  "By author, newest first",
+			query: "?author_id=" + walt.ID.String() + "&sort=desc",
+			want:  []Chirp{third, first},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chirps := expect[[]Chirp](t, ts.do("GET", "/api/chirps"+tt.query, "", nil), http.StatusOK)
+			if !sameChirps(chirps, tt.want) {
+				t.Errorf("got %v, want %v", chirpBodies(chirps), chirpBodies(tt.want))
+			}
+		})
+	}
+}
+
+func TestGetChirp(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signUp("walt@breakingbad.com")
+	chirp := ts.chirp(user.Token, "Say my name")
+
+	tests := []struct {
+		name     string
+		id       string
+		wantCode int
+	}{
+		{
+			name:     "Existing chirp",
+			id:       chirp.ID.String(),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Unknown chirp",
+			id:       "3311741c-680c-4546-99f3-fc9efac2036c",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			id:       "not-a-uuid",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := ts.do("GET", "/api/chirps/"+tt.id, "", nil)
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestDeleteChirp(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+	chirp := ts.chirp(walt.Token, "Say my name")
+	path := "/api/chirps/" + chirp.ID.String()
+
+	expect[any](t, ts.do("DELETE", path, "", nil), http.StatusUnauthorized)
+	expect[any](t, ts.do("DELETE", path, jesse.Token, nil), http.StatusForbidden)
+	expect[any](t, ts.do("DELETE", path, walt.Token, nil), http.StatusNoContent)
+	expect[any](t, ts.do("GET", path, "", nil), http.StatusNotFound)
+	expect[any](t, ts.do("DELETE", path, walt.Token, nil), http.StatusNotFound)
+}
+
+func TestDrafts(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		params     map[string]any
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:       "Draft",
+			params:     map[string]any{"body": "Say my name", "draft": true},
+			wantCode:   http.StatusCreated,
+			wantStatus: chirpDraft,
+		},
+		{
+			name:       "Scheduled",
+			params:     map[string]any{"body": "I am the danger", "publish_at": later},
+			wantCode:   http.StatusCreated,
+			wantStatus: chirpScheduled,
+		},
+		{
+			name:       "Publish time has passed",
+			params:     map[string]any{"body": "Yo", "publish_at": time.Now().Add(-time.Hour)},
+			wantCode:   http.StatusCreated,
+			wantStatus: chirpPublished,
+		},
+		{
+			name:     "Scheduled draft",
+			params:   map[string]any{"body": "Tread lightly", "draft": true, "publish_at": later},
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	var unpublished []Chirp
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := ts.do("POST", "/api/chirps", walt.Token, tt.params)
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusCreated {
+				return
+			}
+			chirp := expect[Chirp](t, resp, http.StatusCreated)
+			if chirp.Status != tt.wantStatus {
+				t.Errorf("got status %q, want %q", chirp.Status, tt.wantStatus)
+			}
+			if chirp.Status != chirpPublished {
+				unpublished = append(unpublished, chirp)
+			}
+		})
+	}
+
+	// Drafts and scheduled chirps are only shown to their author
+	chirps := expect[[]Chirp](t, ts.do("GET", "/api/chirps", "", nil), http.StatusOK)
+	if got := chirpBodies(chirps); len(got) != 1 || got[0] != "Yo" {
+		t.Errorf("timeline shows %v, want only the published chirp", got)
+	}
+	for _, chirp := range unpublished {
+		expect[any](t, ts.do("GET", "/api/chirps/"+chirp.ID.String(), walt.Token, nil), http.StatusNotFound)
+	}
+	drafts := expect[[]Chirp](t, ts.do("GET", "/api/drafts", walt.Token, nil), http.StatusOK)
+	if !sameChirps(drafts, unpublished) {
+		t.Errorf("got drafts %v, want %v", chirpBodies(drafts), chirpBodies(unpublished))
+	}
+	drafts = expect[[]Chirp](t, ts.do("GET", "/api/drafts", jesse.Token, nil), http.StatusOK)
+	if len(drafts) != 0 {
+		t.Errorf("Jesse sees drafts %v", chirpBodies(drafts))
+	}
+
+	// Edit the draft, then publish it
+	path := "/api/chirps/" + unpublished[0].ID.String()
+	edit := map[string]any{"body": "Say my name, kerfuffle", "draft": true}
+	expect[any](t, ts.do("PUT", path, jesse.Token, edit), http.StatusForbidden)
+	edited := expect[Chirp](t, ts.do("PUT", path, walt.Token, edit), http.StatusOK)
+	if edited.Body != "Say my name, ****" || edited.Status != chirpDraft {
+		t.Errorf("edited draft = %+v", edited)
+	}
+	edited = expect[Chirp](t, ts.do("PUT", path, walt.Token, map[string]any{"body": "Say my name"}), http.StatusOK)
+	if edited.Status != chirpPublished {
+		t.Errorf("got status %q, want published", edited.Status)
+	}
+	expect[Chirp](t, ts.do("GET", path, "", nil), http.StatusOK)
+	expect[any](t, ts.do("PUT", path, walt.Token, edit), http.StatusConflict)
+}
+
+func TestChirpParametersStatus(t *testing.T) {
+	later := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	est := time.FixedZone("EST", -5*60*60)
+
+	tests := []struct {
+		name       string
+		params     chirpParameters
+		wantStatus string
+		wantAt     time.Time
+	}{
+		{name: "Now", params: chirpParameters{Body: "Yo"}, wantStatus: chirpPublished},
+		{name: "Draft", params: chirpParameters{Body: "Yo", Draft: true}, wantStatus: chirpDraft},
+		{name: "Past", params: chirpParameters{Body: "Yo", PublishAt: timePtr(later.Add(-48 * time.Hour))}, wantStatus: chirpPublished},
+		{name: "UTC", params: chirpParameters{Body: "Yo", PublishAt: timePtr(later.UTC())}, wantStatus: chirpScheduled, wantAt: later},
+		// Postgres would drop the offset, so it must be gone before then
+		{name: "Offset", params: chirpParameters{Body: "Yo", PublishAt: timePtr(later.In(est))}, wantStatus: chirpScheduled, wantAt: later},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, publishAt := tt.params.status()
+			if status != tt.wantStatus {
+				t.Errorf("got status %q, want %q", status, tt.wantStatus)
+			}
+			if publishAt.Valid != !tt.wantAt.IsZero() || !publishAt.Time.Equal(tt.wantAt) {
+				t.Fatalf("got publish_at %v, want %v", publishAt, tt.wantAt)
+			}
+			if publishAt.Valid && publishAt.Time.Location() != time.UTC {
+				t.Errorf("publish_at %v isn't in UTC", publishAt.Time)
+			}
+		})
+	}
+}
+
+func TestScheduledChirpPublishes(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	scheduled := expect[Chirp](t, ts.do("POST", "/api/chirps", walt.Token, map[string]any{
+		"body":       "I am the one who knocks",
+		"publish_at": time.Now().Add(time.Hour),
+	}), http.StatusCreated)
+
+	// Bring it forward; the job for the original time is left behind
+	path := "/api/chirps/" + scheduled.ID.String()
+	expect[Chirp](t, ts.do("PUT", path, walt.Token, map[string]any{
+		"body":       "I am the one who knocks",
+		"publish_at": time.Now().Add(100 * time.Millisecond),
+	}), http.StatusOK)
+	expect[any](t, ts.do("GET", path, "", nil), http.StatusNotFound)
+
+	// Two instances race to publish it
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub := events.NewHub(ts.store)
+	for range 2 {
+		runner := jobs.NewRunner(ts.store)
+		runner.PollInterval = 10 * time.Millisecond
+		err := registerJobs(runner, ts.store, hub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go runner.Run(ctx)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ts.do("GET", path, "", nil).StatusCode != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduled chirp was never published")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Give a duplicate publish the chance to happen, then count announcements
+	time.Sleep(100 * time.Millisecond)
+	evs, err := hub.Since(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := 0
+	for _, ev := range evs {
+		if ev.Type == events.ChirpCreated {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Errorf("chirp was announced %d times, want once", created)
+	}
+}
+
+func sameChirps(got, want []Chirp) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i].ID != want[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+func chirpBodies(chirps []Chirp) []string {
+	bodies := make([]string, 0, len(chirps))
+	for _, chirp := range chirps {
+		bodies = append(bodies, chirp.Body)
+	}
+	return bodies
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/chirpyclient"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/cli"
+)
+
+// cli runs `chirpy-cli args...` against the test server, with its login
+// saved in dir, and returns what it printed.
+func (ts *testServer) cli(dir, stdin string, args ...string) (string, error) {
+	ts.t.Helper()
+	var out bytes.Buffer
+	c := &cli.CLI{
+		Server:     ts.URL,
+		ConfigDir:  dir,
+		HTTPClient: ts.Client(),
+		In:         strings.NewReader(stdin),
+		Out:        &out,
+		ErrOut:     &bytes.Buffer{},
+	}
+	err := c.Run(ts.t.Context(), args)
+	return out.String(), err
+}
+
+func decodeOutput[T any](t *testing.T, out string) T {
+	t.Helper()
+	var v T
+	err := json.Unmarshal([]byte(out), &v)
+	if err != nil {
+		t.Fatalf("Couldn't decode %q: %v", out, err)
+	}
+	return v
+}
+
+func TestCLI(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+	user := ts.signUp("walt@breakingbad.com")
+
+	_, err := ts.cli(dir, "", "post", "Say my name")
+	if err == nil || !strings.Contains(err.Error(), "not logged in") {
+		t.Fatalf("post before login returned %v", err)
+	}
+	_, err = ts.cli(dir, "wrong\n", "login", "-email", "walt@breakingbad.com")
+	if !errors.Is(err, chirpyclient.ErrInvalidCredentials) {
+		t.Fatalf("login with the wrong password returned %v", err)
+	}
+
+	out, err := ts.cli(dir, testPassword+"\n", "login", "-email", "walt@breakingbad.com", "-o", "json")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if got := decodeOutput[chirpyclient.User](t, out); got.ID != user.ID || got.Token != "" {
+		t.Errorf("login printed %+v, want user %s without tokens", got, user.ID)
+	}
+	info, err := os.Stat(filepath.Join(dir, "credentials.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("credentials are %v, want -rw-------", perm)
+	}
+
+	out, err = ts.cli(dir, "", "post", "-o", "json", "Say", "my", "name")
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	first := decodeOutput[chirpyclient.Chirp](t, out)
+	out, err = ts.cli(dir, "You're goddamn right\n", "post", "-o", "json")
+	if err != nil {
+		t.Fatalf("post from stdin: %v", err)
+	}
+	second := decodeOutput[chirpyclient.Chirp](t, out)
+	if first.Body != "Say my name" || second.Body != "You're goddamn right" {
+		t.Errorf("posted %q and %q", first.Body, second.Body)
+	}
+
+	out, err = ts.cli(dir, "", "timeline", "-o", "json")
+	if err != nil {
+		t.Fatalf("timeline: %v", err)
+	}
+	// Oldest first, like tail
+	if got := decodeOutput[[]chirpyclient.Chirp](t, out); len(got) != 2 || got[0].ID != first.ID || got[1].ID != second.ID {
+		t.Errorf("timeline printed %+v", got)
+	}
+	out, err = ts.cli(dir, "", "timeline", "-n", "1")
+	if err != nil {
+		t.Fatalf("timeline: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], second.ID.String()) {
+		t.Errorf("timeline -n 1 printed %q", out)
+	}
+
+	_, err = ts.cli(dir, "", "delete", first.ID.String())
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	_, err = ts.cli(dir, "", "delete", first.ID.String())
+	if !errors.Is(err, chirpyclient.ErrNotFound) {
+		t.Errorf("deleting twice returned %v", err)
+	}
+
+	_, err = ts.cli(dir, "", "profile", "-email", "heisenberg@breakingbad.com", "-o", "json")
+	if err != nil {
+		t.Fatalf("profile: %v", err)
+	}
+	out, err = ts.cli(dir, "", "profile", "-o", "json")
+	if err != nil {
+		t.Fatalf("profile: %v", err)
+	}
+	if got := decodeOutput[chirpyclient.User](t, out); got.Email != "heisenberg@breakingbad.com" {
+		t.Errorf("profile shows %s after changing the email", got.Email)
+	}
+
+	_, err = ts.cli(dir, "", "logout")
+	if err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	_, err = os.Stat(filepath.Join(dir, "credentials.json"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("credentials still there after logout: %v", err)
+	}
+}
+
+// TestCLIRefresh checks that a refreshed access token is saved for the
+// next run.
+func TestCLIRefresh(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+	ts.signUp("walt@breakingbad.com")
+	_, err := ts.cli(dir, testPassword+"\n", "login", "-email", "walt@breakingbad.com")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	path := filepath.Join(dir, "credentials.json")
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]any
+	json.Unmarshal(dat, &saved)
+	saved["access_token"] = "garbage"
+	dat, _ = json.Marshal(saved)
+	err = os.WriteFile(path, dat, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ts.cli(dir, "", "post", "I am the danger")
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	dat, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(dat, []byte("garbage")) {
+		t.Error("the refreshed access token wasn't saved")
+	}
+}
+
+func TestCLIFollow(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+	walt := ts.signUp("walt@breakingbad.com")
+	ts.signUp("jesse@breakingbad.com")
+	_, err := ts.cli(dir, testPassword+"\n", "login", "-email", "jesse@breakingbad.com")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	var out syncBuffer
+	c := &cli.CLI{
+		Server:     ts.URL,
+		ConfigDir:  dir,
+		HTTPClient: ts.Client(),
+		In:         strings.NewReader(""),
+		Out:        &out,
+		ErrOut:     &bytes.Buffer{},
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run(ctx, []string{"timeline", "-f", "-o", "json"})
+	}()
+
+	// Chirps posted before the stream is subscribed aren't printed, so keep
+	// posting until one is
+	var posted []string
+	for !strings.Contains(out.String(), `"user_id":"`+walt.ID.String()) {
+		if ctx.Err() != nil {
+			t.Fatalf("timeline -f never printed a new chirp; posted %v, printed %q", posted, out.String())
+		}
+		posted = append(posted, ts.chirp(walt.Token, "Jesse, we need to cook").ID.String())
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	err = <-done
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("timeline -f returned %v after cancelling", err)
+	}
+}
@@ -0,0 +1,54 @@
+package chirpyclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Block struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Mute struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type targetUser struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// Block hides the user's chirps from the signed-in user, and stops them
+// messaging each other.
+func (c *Client) Block(ctx context.Context, userID uuid.UUID) error {
+	return c.do(ctx, "POST", "/api/blocks", targetUser{UserID: userID}, nil)
+}
+
+func (c *Client) Unblock(ctx context.Context, userID uuid.UUID) error {
+	return c.do(ctx, "DELETE", "/api/blocks/"+userID.String(), nil, nil)
+}
+
+func (c *Client) Blocks(ctx context.Context) ([]Block, error) {
+	var blocks []Block
+	err := c.do(ctx, "GET", "/api/blocks", nil, &blocks)
+	return blocks, err
+}
+
+// Mute hides the user's chirps from the signed-in user without them
+// knowing.
+func (c *Client) Mute(ctx context.Context, userID uuid.UUID) error {
+	return c.do(ctx, "POST", "/api/mutes", targetUser{UserID: userID}, nil)
+}
+
+func (c *Client) Unmute(ctx context.Context, userID uuid.UUID) error {
+	return c.do(ctx, "DELETE", "/api/mutes/"+userID.String(), nil, nil)
+}
+
+func (c *Client) Mutes(ctx context.Context) ([]Mute, error) {
+	var mutes []Mute
+	err := c.do(ctx, "GET", "/api/mutes", nil, &mutes)
+	return mutes, err
+}
@@ -0,0 +1,95 @@
+package chirpyclient
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Chirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+	// Status is "draft", "scheduled" or "published"
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
+
+// ChirpParams is a new chirp. Set Draft to save it without publishing, or
+// PublishAt to publish it later.
+type ChirpParams struct {
+	Body      string     `json:"body"`
+	Draft     bool       `json:"draft,omitempty"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
+
+func (c *Client) CreateChirp(ctx context.Context, params ChirpParams) (*Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, "POST", "/api/chirps", params, &chirp)
+	if err != nil {
+		return nil, err
+	}
+	return &chirp, nil
+}
+
+type ListChirpsOptions struct {
+	// AuthorID limits the list to one user's chirps
+	AuthorID uuid.UUID
+	// Descending lists the newest chirps first
+	Descending bool
+}
+
+// ListChirps lists published chirps, oldest first unless opts says
+// otherwise. Signed in, it leaves out chirps by users blocked or muted.
+func (c *Client) ListChirps(ctx context.Context, opts ListChirpsOptions) ([]Chirp, error) {
+	query := url.Values{}
+	if opts.AuthorID != uuid.Nil {
+		query.Set("author_id", opts.AuthorID.String())
+	}
+	if opts.Descending {
+		query.Set("sort", "desc")
+	}
+	path := "/api/chirps"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var chirps []Chirp
+	err := c.do(ctx, "GET", path, nil, &chirps)
+	return chirps, err
+}
+
+func (c *Client) GetChirp(ctx context.Context, id uuid.UUID) (*Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, "GET", "/api/chirps/"+id.String(), nil, &chirp)
+	if err != nil {
+		return nil, err
+	}
+	return &chirp, nil
+}
+
+// UpdateDraft edits one of the signed-in user's drafts or scheduled chirps.
+// Published chirps can't be edited.
+func (c *Client) UpdateDraft(ctx context.Context, id uuid.UUID, params ChirpParams) (*Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, "PUT", "/api/chirps/"+id.String(), params, &chirp)
+	if err != nil {
+		return nil, err
+	}
+	return &chirp, nil
+}
+
+func (c *Client) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, "DELETE", "/api/chirps/"+id.String(), nil, nil)
+}
+
+// Drafts lists the signed-in user's drafts and scheduled chirps.
+func (c *Client) Drafts(ctx context.Context) ([]Chirp, error) {
+	var chirps []Chirp
+	err := c.do(ctx, "GET", "/api/drafts", nil, &chirps)
+	return chirps, err
+}
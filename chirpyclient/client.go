@@ -0,0 +1,226 @@
+// Package chirpyclient is a client for the Chirpy API.
+//
+// A Client holds the signed-in user's tokens. Log in once, or hand it saved
+// tokens with WithTokens, and every call after that is authenticated. The
+// access token is refreshed with the refresh token shortly before it
+// expires, and again if the server turns it down, so callers never see an
+// expired token.
+//
+//	client := chirpyclient.New("https://chirpy.example.com")
+//	_, err := client.Login(ctx, email, password)
+//	chirp, err := client.CreateChirp(ctx, chirpyclient.ChirpParams{Body: "Hello"})
+//
+// Failed calls return an *Error, which errors.Is matches against ErrNotFound
+// and the other sentinels by its code.
+package chirpyclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// refreshMargin is how long before the access token expires that it's
+// refreshed, leaving room for clock skew and the request in flight.
+const refreshMargin = 30 * time.Second
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+
+	// refreshing lets one caller at a time refresh the access token
+	refreshing sync.Mutex
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient. Note
+// that a client-wide timeout also cuts off Events.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithTokens starts the client signed in, with tokens saved from an earlier
+// Login or Tokens.
+func WithTokens(accessToken, refreshToken string) Option {
+	return func(c *Client) {
+		c.accessToken = accessToken
+		c.refreshToken = refreshToken
+	}
+}
+
+// New returns a client for the server at baseURL, such as
+// "https://chirpy.example.com".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Tokens returns the current access and refresh tokens, for saving between
+// runs. The access token changes whenever it's refreshed.
+func (c *Client) Tokens() (accessToken, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.accessToken, c.refreshToken
+}
+
+func (c *Client) setTokens(accessToken, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accessToken = accessToken
+	c.refreshToken = refreshToken
+}
+
+// do sends a request with in as its JSON body, if it's non-nil, and decodes
+// the response into out, if it's non-nil.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	resp, err := c.request(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeBody(resp, out)
+}
+
+// decodeBody decodes a successful response into out, if it's non-nil.
+func decodeBody(resp *http.Response, out any) error {
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	err := json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("chirpy: decoding %s %s response: %w", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+	return nil
+}
+
+// request sends an authenticated request, refreshing the access token first
+// if it's about to expire, or afterwards if the server rejects it. A
+// response with an error status is returned as an *Error; otherwise the
+// caller must close the body.
+func (c *Client) request(ctx context.Context, method, path string, in any) (*http.Response, error) {
+	body, err := encode(method, path, in)
+	if err != nil {
+		return nil, err
+	}
+
+	access, refresh := c.Tokens()
+	if refresh != "" && expiresSoon(access) {
+		err := c.refreshAfter(ctx, access)
+		if err != nil {
+			return nil, err
+		}
+		access, _ = c.Tokens()
+	}
+
+	resp, err := c.send(ctx, method, path, bearer(access), body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && access != "" && refresh != "" {
+		resp.Body.Close()
+		err = c.refreshAfter(ctx, access)
+		if err != nil {
+			return nil, err
+		}
+		access, _ = c.Tokens()
+		resp, err = c.send(ctx, method, path, bearer(access), body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, readError(resp)
+	}
+	return resp, nil
+}
+
+// send sends one request, with authorization as its Authorization header if
+// it's set.
+func (c *Client) send(ctx context.Context, method, path, authorization string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("chirpy: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("chirpy: %s %s: %w", method, path, err)
+	}
+	return resp, nil
+}
+
+func encode(method, path string, in any) ([]byte, error) {
+	if in == nil {
+		return nil, nil
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("chirpy: encoding %s %s request: %w", method, path, err)
+	}
+	return body, nil
+}
+
+func bearer(token string) string {
+	if token == "" {
+		return ""
+	}
+	return "Bearer " + token
+}
+
+// refreshAfter refreshes the access token unless another caller already
+// replaced stale while this one waited.
+func (c *Client) refreshAfter(ctx context.Context, stale string) error {
+	c.refreshing.Lock()
+	defer c.refreshing.Unlock()
+	if access, _ := c.Tokens(); access != stale {
+		return nil
+	}
+	return c.Refresh(ctx)
+}
+
+// expiresSoon reports whether an access token is within refreshMargin of
+// expiring. The client can't check the signature, and doesn't need to: a
+// token it misjudges is caught by the server's 401.
+func expiresSoon(token string) bool {
+	if token == "" {
+		return false
+	}
+	var claims jwt.RegisteredClaims
+	_, _, err := jwt.NewParser().ParseUnverified(token, &claims)
+	if err != nil || claims.ExpiresAt == nil {
+		return false
+	}
+	return time.Until(claims.ExpiresAt.Time) < refreshMargin
+}
@@ -0,0 +1,102 @@
+package chirpyclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is a request the server turned down. Its fields come from the
+// RFC 9457 problem details in the response.
+type Error struct {
+	StatusCode int
+	// Code is stable and safe to branch on; Title and Detail are for people
+	Code      string
+	Title     string
+	Detail    string
+	RequestID string
+	// Errors says what's wrong with each field of a rejected body
+	Errors []FieldError
+	// RetryAfter is how long to wait before trying a rate limited request
+	// again
+	RetryAfter time.Duration
+}
+
+// FieldError is one problem with a request body. Pointer is a JSON Pointer
+// to the field, such as "/body".
+type FieldError struct {
+	Pointer string `json:"pointer"`
+	Code    string `json:"code"`
+	Detail  string `json:"detail"`
+}
+
+// Sentinels for errors.Is. An *Error matches the one with its code.
+var (
+	ErrMalformedBody      = &Error{Code: "malformed_body"}
+	ErrInvalidID          = &Error{Code: "invalid_id"}
+	ErrInvalidParameter   = &Error{Code: "invalid_parameter"}
+	ErrValidation         = &Error{Code: "validation_failed"}
+	ErrUnauthorized       = &Error{Code: "unauthorized"}
+	ErrInvalidCredentials = &Error{Code: "invalid_credentials"}
+	ErrForbidden          = &Error{Code: "forbidden"}
+	ErrNotFound           = &Error{Code: "not_found"}
+	ErrConflict           = &Error{Code: "conflict"}
+	ErrBodyTooLarge       = &Error{Code: "body_too_large"}
+	ErrRateLimited        = &Error{Code: "rate_limited"}
+	ErrInternal           = &Error{Code: "internal_error"}
+)
+
+func (e *Error) Error() string {
+	msg := e.Detail
+	if msg == "" {
+		msg = e.Title
+	}
+	if e.Code == "" {
+		return fmt.Sprintf("chirpy: %d: %s", e.StatusCode, msg)
+	}
+	return fmt.Sprintf("chirpy: %d %s: %s", e.StatusCode, e.Code, msg)
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != "" && t.Code == e.Code
+}
+
+// readError turns an error response into an *Error. Bodies that aren't
+// problem details, say from a proxy, end up in Detail.
+func readError(resp *http.Response) error {
+	e := &Error{StatusCode: resp.StatusCode}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		e.Detail = http.StatusText(resp.StatusCode)
+		return e
+	}
+	var problem struct {
+		Title     string       `json:"title"`
+		Detail    string       `json:"detail"`
+		Code      string       `json:"code"`
+		RequestID string       `json:"request_id"`
+		Errors    []FieldError `json:"errors"`
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/problem+json") && json.Unmarshal(body, &problem) == nil {
+		e.Code = problem.Code
+		e.Title = problem.Title
+		e.Detail = problem.Detail
+		e.RequestID = problem.RequestID
+		e.Errors = problem.Errors
+		return e
+	}
+	e.Detail = strings.TrimSpace(string(body))
+	if e.Detail == "" {
+		e.Detail = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
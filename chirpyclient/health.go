@@ -0,0 +1,33 @@
+package chirpyclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Readiness is the result of each of the server's readiness checks.
+type Readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Ready asks whether the server can take traffic. A server that can't
+// still reports which checks failed, alongside an *Error.
+func (c *Client) Ready(ctx context.Context) (*Readiness, error) {
+	resp, err := c.send(ctx, "GET", "/readyz", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var readiness Readiness
+	err = json.NewDecoder(resp.Body).Decode(&readiness)
+	if err != nil {
+		return nil, &Error{StatusCode: resp.StatusCode, Detail: http.StatusText(resp.StatusCode)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &readiness, &Error{StatusCode: resp.StatusCode, Detail: readiness.Status}
+	}
+	return &readiness, nil
+}
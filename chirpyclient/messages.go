@@ -0,0 +1,85 @@
+package chirpyclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Conversation struct {
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Participants []Participant `json:"participants"`
+	UnreadCount  int64         `json:"unread_count"`
+}
+
+type Participant struct {
+	UserID uuid.UUID `json:"user_id"`
+	// LastReadAt is nil until the participant reads the conversation
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+}
+
+// CreateConversation starts a conversation between the signed-in user and
+// the others, or returns the one they already have.
+func (c *Client) CreateConversation(ctx context.Context, participantIDs ...uuid.UUID) (*Conversation, error) {
+	body := struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+	}{ParticipantIDs: participantIDs}
+
+	var conversation Conversation
+	err := c.do(ctx, "POST", "/api/conversations", body, &conversation)
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+func (c *Client) Conversations(ctx context.Context) ([]Conversation, error) {
+	var conversations []Conversation
+	err := c.do(ctx, "GET", "/api/conversations", nil, &conversations)
+	return conversations, err
+}
+
+// DeleteConversation leaves a conversation. The others keep it.
+func (c *Client) DeleteConversation(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, "DELETE", "/api/conversations/"+id.String(), nil, nil)
+}
+
+func (c *Client) Messages(ctx context.Context, conversationID uuid.UUID) ([]Message, error) {
+	var messages []Message
+	err := c.do(ctx, "GET", "/api/conversations/"+conversationID.String()+"/messages", nil, &messages)
+	return messages, err
+}
+
+func (c *Client) SendMessage(ctx context.Context, conversationID uuid.UUID, body string) (*Message, error) {
+	in := struct {
+		Body string `json:"body"`
+	}{Body: body}
+
+	var message Message
+	err := c.do(ctx, "POST", "/api/conversations/"+conversationID.String()+"/messages", in, &message)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// MarkConversationRead marks the conversation read up to and including
+// messageID, the newest message the user has seen.
+func (c *Client) MarkConversationRead(ctx context.Context, conversationID, messageID uuid.UUID) error {
+	in := struct {
+		MessageID uuid.UUID `json:"message_id"`
+	}{MessageID: messageID}
+	return c.do(ctx, "POST", "/api/conversations/"+conversationID.String()+"/read", in, nil)
+}
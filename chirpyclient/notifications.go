@@ -0,0 +1,123 @@
+package chirpyclient
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Notification struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Type       string    `json:"type"`
+	SubjectID  uuid.UUID `json:"subject_id"`
+	ActorCount int64     `json:"actor_count"`
+	// RecentActors holds the latest few of ActorCount users
+	RecentActors []uuid.UUID `json:"recent_actors"`
+	Read         bool        `json:"read"`
+	Summary      string      `json:"summary"`
+}
+
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int64          `json:"unread_count"`
+	// NextCursor fetches the next page, and is empty on the last one
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type NotificationPreference struct {
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+type NotificationsOptions struct {
+	// Limit is the page size; zero leaves it to the server
+	Limit      int
+	UnreadOnly bool
+}
+
+// NotificationsPage fetches one page of the signed-in user's notifications,
+// newest first. Pass an empty cursor for the first page.
+func (c *Client) NotificationsPage(ctx context.Context, opts NotificationsOptions, cursor string) (*NotificationPage, error) {
+	query := url.Values{}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.UnreadOnly {
+		query.Set("unread", "true")
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	path := "/api/notifications"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var page NotificationPage
+	err := c.do(ctx, "GET", path, nil, &page)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Notifications iterates over all the signed-in user's notifications,
+// fetching pages as it goes. It stops after yielding an error.
+//
+//	for n, err := range client.Notifications(ctx, chirpyclient.NotificationsOptions{}) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(n.Summary)
+//	}
+func (c *Client) Notifications(ctx context.Context, opts NotificationsOptions) iter.Seq2[Notification, error] {
+	return func(yield func(Notification, error) bool) {
+		cursor := ""
+		for {
+			page, err := c.NotificationsPage(ctx, opts, cursor)
+			if err != nil {
+				yield(Notification{}, err)
+				return
+			}
+			for _, n := range page.Notifications {
+				if !yield(n, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			cursor = page.NextCursor
+		}
+	}
+}
+
+func (c *Client) MarkNotificationRead(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, "POST", "/api/notifications/"+id.String()+"/read", nil, nil)
+}
+
+func (c *Client) MarkAllNotificationsRead(ctx context.Context) error {
+	return c.do(ctx, "POST", "/api/notifications/read", nil, nil)
+}
+
+// NotificationPreferences lists every notification type and whether the
+// signed-in user gets it.
+func (c *Client) NotificationPreferences(ctx context.Context) ([]NotificationPreference, error) {
+	var prefs []NotificationPreference
+	err := c.do(ctx, "GET", "/api/notifications/preferences", nil, &prefs)
+	return prefs, err
+}
+
+// SetNotificationPreferences turns notification types on or off, leaving
+// types missing from enabled as they are, and returns the full list.
+func (c *Client) SetNotificationPreferences(ctx context.Context, enabled map[string]bool) ([]NotificationPreference, error) {
+	var prefs []NotificationPreference
+	err := c.do(ctx, "PUT", "/api/notifications/preferences", enabled, &prefs)
+	return prefs, err
+}
@@ -0,0 +1,71 @@
+package chirpyclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// Event is something that happened that the signed-in user can see, such
+// as a new chirp. Data is the JSON payload, whose shape depends on Type.
+type Event struct {
+	ID   int64
+	Type string
+	Data json.RawMessage
+}
+
+// Events streams live events until ctx is done or the connection drops,
+// after replaying any since lastEventID. Pass zero to skip the replay, and
+// the last ID seen to pick up where a dropped stream left off.
+func (c *Client) Events(ctx context.Context, lastEventID int64) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		path := "/api/stream"
+		if lastEventID > 0 {
+			path += "?last_event_id=" + strconv.FormatInt(lastEventID, 10)
+		}
+		resp, err := c.request(ctx, "GET", path, nil)
+		if err != nil {
+			yield(Event{}, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		var ev Event
+		var data []string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				// A blank line ends the event; ones without data, like the
+				// retry hint, aren't events
+				if data != nil {
+					ev.Data = json.RawMessage(strings.Join(data, "\n"))
+					if !yield(ev, nil) {
+						return
+					}
+				}
+				ev, data = Event{}, nil
+				continue
+			}
+			// Heartbeats are comments, which start with a colon and so
+			// match no field
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "id":
+				ev.ID, _ = strconv.ParseInt(value, 10, 64)
+			case "event":
+				ev.Type = value
+			case "data":
+				data = append(data, value)
+			}
+		}
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			yield(Event{}, fmt.Errorf("chirpy: reading events: %w", err))
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package chirpyclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type User struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Email     string    `json:"email"`
+	// Token and RefreshToken are only set by Login and UpdateUser
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	IsChirpyRed  bool   `json:"is_chirpy_red"`
+}
+
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// CreateUser signs up a new user. It doesn't log them in.
+func (c *Client) CreateUser(ctx context.Context, email, password string) (*User, error) {
+	var user User
+	err := c.do(ctx, "POST", "/api/users", credentials{Email: email, Password: password}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Login signs in, and the client uses the user's tokens from then on.
+func (c *Client) Login(ctx context.Context, email, password string) (*User, error) {
+	var user User
+	err := c.do(ctx, "POST", "/api/login", credentials{Email: email, Password: password}, &user)
+	if err != nil {
+		return nil, err
+	}
+	c.setTokens(user.Token, user.RefreshToken)
+	return &user, nil
+}
+
+// UserUpdate changes the signed-in user. Empty fields are left as they are.
+type UserUpdate struct {
+	Email    string `json:"email,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+func (c *Client) UpdateUser(ctx context.Context, update UserUpdate) (*User, error) {
+	var user User
+	err := c.do(ctx, "PUT", "/api/users", update, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Refresh swaps the refresh token for a new access token. Requests do this
+// on their own when they need to, so there's rarely a reason to call it.
+func (c *Client) Refresh(ctx context.Context) error {
+	_, refresh := c.Tokens()
+	resp, err := c.send(ctx, "POST", "/api/refresh", bearer(refresh), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return readError(resp)
+	}
+	var body struct {
+		Token string `json:"token"`
+	}
+	err = decodeBody(resp, &body)
+	if err != nil {
+		return err
+	}
+	c.setTokens(body.Token, refresh)
+	return nil
+}
+
+// Logout revokes the refresh token and forgets both tokens.
+func (c *Client) Logout(ctx context.Context) error {
+	_, refresh := c.Tokens()
+	resp, err := c.send(ctx, "POST", "/api/revoke", bearer(refresh), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return readError(resp)
+	}
+	c.setTokens("", "")
+	return nil
+}
+
+// SendPolkaWebhook delivers a Polka payment event, authenticated with
+// Polka's API key rather than a user's tokens. "user.upgraded" makes the
+// user a Chirpy Red member; other events are ignored.
+func (c *Client) SendPolkaWebhook(ctx context.Context, apiKey, event string, userID uuid.UUID) error {
+	type data struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+	body := struct {
+		Event string `json:"event"`
+		Data  data   `json:"data"`
+	}{Event: event, Data: data{UserID: userID}}
+
+	payload, err := encode("POST", "/api/polka/webhooks", body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(ctx, "POST", "/api/polka/webhooks", "ApiKey "+apiKey, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return readError(resp)
+	}
+	return nil
+}
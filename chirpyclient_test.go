@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/chirpyclient"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/notifications"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// newClient signs up a user and returns a client logged in as them.
+func (ts *testServer) newClient(email string) (*chirpyclient.Client, *chirpyclient.User) {
+	ts.t.Helper()
+	ctx := ts.t.Context()
+	client := chirpyclient.New(ts.URL, chirpyclient.WithHTTPClient(ts.Client()))
+	_, err := client.CreateUser(ctx, email, testPassword)
+	if err != nil {
+		ts.t.Fatalf("CreateUser: %v", err)
+	}
+	user, err := client.Login(ctx, email, testPassword)
+	if err != nil {
+		ts.t.Fatalf("Login: %v", err)
+	}
+	return client, user
+}
+
+func TestClientChirps(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := t.Context()
+	client, user := ts.newClient("walt@breakingbad.com")
+
+	chirp, err := client.CreateChirp(ctx, chirpyclient.ChirpParams{Body: "Say my name"})
+	if err != nil {
+		t.Fatalf("CreateChirp: %v", err)
+	}
+	if chirp.UserID != user.ID || chirp.Status != "published" {
+		t.Errorf("got chirp %+v", chirp)
+	}
+
+	got, err := client.GetChirp(ctx, chirp.ID)
+	if err != nil {
+		t.Fatalf("GetChirp: %v", err)
+	}
+	if got.Body != "Say my name" {
+		t.Errorf("got body %q, want %q", got.Body, "Say my name")
+	}
+
+	draft, err := client.CreateChirp(ctx, chirpyclient.ChirpParams{Body: "Tread lightly", Draft: true})
+	if err != nil {
+		t.Fatalf("CreateChirp draft: %v", err)
+	}
+	draft, err = client.UpdateDraft(ctx, draft.ID, chirpyclient.ChirpParams{Body: "Tread lightly.", Draft: true})
+	if err != nil {
+		t.Fatalf("UpdateDraft: %v", err)
+	}
+	drafts, err := client.Drafts(ctx)
+	if err != nil {
+		t.Fatalf("Drafts: %v", err)
+	}
+	if len(drafts) != 1 || drafts[0].Body != "Tread lightly." {
+		t.Errorf("got drafts %+v", drafts)
+	}
+
+	chirps, err := client.ListChirps(ctx, chirpyclient.ListChirpsOptions{AuthorID: user.ID, Descending: true})
+	if err != nil {
+		t.Fatalf("ListChirps: %v", err)
+	}
+	if len(chirps) != 1 || chirps[0].ID != chirp.ID {
+		t.Errorf("got chirps %+v, want only %s", chirps, chirp.ID)
+	}
+
+	err = client.DeleteChirp(ctx, chirp.ID)
+	if err != nil {
+		t.Fatalf("DeleteChirp: %v", err)
+	}
+	_, err = client.GetChirp(ctx, chirp.ID)
+	if !errors.Is(err, chirpyclient.ErrNotFound) {
+		t.Errorf("got %v after delete, want ErrNotFound", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := t.Context()
+	client, _ := ts.newClient("walt@breakingbad.com")
+
+	_, err := client.CreateChirp(ctx, chirpyclient.ChirpParams{})
+	if !errors.Is(err, chirpyclient.ErrValidation) {
+		t.Fatalf("got %v, want ErrValidation", err)
+	}
+	var apiErr *chirpyclient.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %T, want *chirpyclient.Error", err)
+	}
+	want := chirpyclient.FieldError{Pointer: "/body", Code: "required", Detail: "body is required"}
+	if apiErr.StatusCode != 400 || len(apiErr.Errors) != 1 || apiErr.Errors[0] != want {
+		t.Errorf("got %+v, want a 400 with %+v", apiErr, want)
+	}
+	if apiErr.RequestID == "" {
+		t.Error("got no request ID")
+	}
+
+	anonymous := chirpyclient.New(ts.URL, chirpyclient.WithHTTPClient(ts.Client()))
+	_, err = anonymous.Login(ctx, "walt@breakingbad.com", "wrong")
+	if !errors.Is(err, chirpyclient.ErrInvalidCredentials) {
+		t.Errorf("got %v, want ErrInvalidCredentials", err)
+	}
+	_, err = anonymous.Drafts(ctx)
+	if !errors.Is(err, chirpyclient.ErrUnauthorized) {
+		t.Errorf("got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestClientRefresh(t *testing.T) {
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    "chirpy-access",
+		Subject:   uuid.NewString(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		access string
+	}{
+		// Refreshed up front
+		{name: "Expired", access: expired},
+		// Refreshed after the server turns it down
+		{name: "Rejected", access: "garbage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			_, user := ts.newClient("walt@breakingbad.com")
+			client := chirpyclient.New(ts.URL,
+				chirpyclient.WithHTTPClient(ts.Client()),
+				chirpyclient.WithTokens(tt.access, user.RefreshToken),
+			)
+
+			_, err := client.CreateChirp(t.Context(), chirpyclient.ChirpParams{Body: "I am the one who knocks"})
+			if err != nil {
+				t.Fatalf("CreateChirp: %v", err)
+			}
+			access, refresh := client.Tokens()
+			if access == tt.access || refresh != user.RefreshToken {
+				t.Errorf("got tokens %q, %q; want a new access token", access, refresh)
+			}
+
+			err = client.Logout(t.Context())
+			if err != nil {
+				t.Fatalf("Logout: %v", err)
+			}
+			stale := chirpyclient.New(ts.URL,
+				chirpyclient.WithHTTPClient(ts.Client()),
+				chirpyclient.WithTokens("garbage", user.RefreshToken),
+			)
+			_, err = stale.Drafts(t.Context())
+			if !errors.Is(err, chirpyclient.ErrUnauthorized) {
+				t.Errorf("got %v with a revoked refresh token, want ErrUnauthorized", err)
+			}
+		})
+	}
+}
+
+func TestClientNotifications(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := t.Context()
+	client, user := ts.newClient("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+
+	var want []uuid.UUID
+	for range 5 {
+		subject := uuid.New()
+		err := ts.cfg.notifications.Notify(ctx, user.ID, jesse.ID, notifications.TypeLike, subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, subject)
+	}
+
+	var got []uuid.UUID
+	for n, err := range client.Notifications(ctx, chirpyclient.NotificationsOptions{Limit: 2}) {
+		if err != nil {
+			t.Fatalf("Notifications: %v", err)
+		}
+		got = append(got, n.SubjectID)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d notifications, want %d", len(got), len(want))
+	}
+	// Newest first
+	for i, subject := range got {
+		if subject != want[len(want)-1-i] {
+			t.Errorf("notification %d is about %s, want %s", i, subject, want[len(want)-1-i])
+		}
+	}
+
+	err := client.MarkAllNotificationsRead(ctx)
+	if err != nil {
+		t.Fatalf("MarkAllNotificationsRead: %v", err)
+	}
+	page, err := client.NotificationsPage(ctx, chirpyclient.NotificationsOptions{UnreadOnly: true}, "")
+	if err != nil {
+		t.Fatalf("NotificationsPage: %v", err)
+	}
+	if len(page.Notifications) != 0 || page.UnreadCount != 0 {
+		t.Errorf("got %+v after marking all read", page)
+	}
+
+	prefs, err := client.SetNotificationPreferences(ctx, map[string]bool{"like": false})
+	if err != nil {
+		t.Fatalf("SetNotificationPreferences: %v", err)
+	}
+	for _, pref := range prefs {
+		if pref.Enabled != (pref.Type != "like") {
+			t.Errorf("got %+v", pref)
+		}
+	}
+}
+
+func TestClientMessages(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := t.Context()
+	walt, waltUser := ts.newClient("walt@breakingbad.com")
+	jesse, jesseUser := ts.newClient("jesse@breakingbad.com")
+
+	conversation, err := walt.CreateConversation(ctx, jesseUser.ID)
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	_, err = walt.SendMessage(ctx, conversation.ID, "We need to cook")
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	messages, err := jesse.Messages(ctx, conversation.ID)
+	if err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Body != "We need to cook" {
+		t.Fatalf("got messages %+v", messages)
+	}
+	err = jesse.MarkConversationRead(ctx, conversation.ID, messages[0].ID)
+	if err != nil {
+		t.Fatalf("MarkConversationRead: %v", err)
+	}
+	conversations, err := jesse.Conversations(ctx)
+	if err != nil {
+		t.Fatalf("Conversations: %v", err)
+	}
+	if len(conversations) != 1 || conversations[0].UnreadCount != 0 {
+		t.Errorf("got conversations %+v after reading, want nothing unread", conversations)
+	}
+
+	err = jesse.Block(ctx, waltUser.ID)
+	if err != nil {
+		t.Fatalf("Block: %v", err)
+	}
+	_, err = walt.SendMessage(ctx, conversation.ID, "Jesse?")
+	if !errors.Is(err, chirpyclient.ErrForbidden) {
+		t.Errorf("got %v messaging a user who blocked you, want ErrForbidden", err)
+	}
+}
+
+func TestClientEvents(t *testing.T) {
+	ts := newTestServer(t)
+	walt, _ := ts.newClient("walt@breakingbad.com")
+	jesse, _ := ts.newClient("jesse@breakingbad.com")
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	_, err := walt.CreateChirp(ctx, chirpyclient.ChirpParams{Body: "Yo"})
+	if err != nil {
+		t.Fatalf("CreateChirp: %v", err)
+	}
+	// Replay what comes after this, so there's no race with subscribing
+	lastEventID, err := ts.store.GetLatestEventID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirp, err := walt.CreateChirp(ctx, chirpyclient.ChirpParams{Body: "Yo, Mr. White"})
+	if err != nil {
+		t.Fatalf("CreateChirp: %v", err)
+	}
+
+	for ev, err := range jesse.Events(ctx, lastEventID) {
+		if err != nil {
+			t.Fatalf("Events: %v", err)
+		}
+		if ev.Type != "chirp.created" || ev.ID <= lastEventID || !strings.Contains(string(ev.Data), chirp.ID.String()) {
+			t.Errorf("got event %d %s %s, want chirp.created for %s", ev.ID, ev.Type, ev.Data, chirp.ID)
+		}
+		break
+	}
+}
+
+func TestClientPolkaWebhook(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := t.Context()
+	client, user := ts.newClient("walt@breakingbad.com")
+
+	err := client.SendPolkaWebhook(ctx, "wrong-key", "user.upgraded", user.ID)
+	if !errors.Is(err, chirpyclient.ErrUnauthorized) {
+		t.Errorf("got %v with the wrong key, want ErrUnauthorized", err)
+	}
+	err = client.SendPolkaWebhook(ctx, testPolkaKey, "user.upgraded", user.ID)
+	if err != nil {
+		t.Fatalf("SendPolkaWebhook: %v", err)
+	}
+	updated, err := client.UpdateUser(ctx, chirpyclient.UserUpdate{Email: "heisenberg@breakingbad.com"})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if !updated.IsChirpyRed || updated.Email != "heisenberg@breakingbad.com" {
+		t.Errorf("got %+v, want a Chirpy Red heisenberg", updated)
+	}
+}
@@ -0,0 +1,34 @@
+// Command chirpy-cli posts and reads chirps from the command line.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/cli"
+)
+
+func main() {
+	// Ctrl-C stops timeline -f cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &cli.CLI{
+		Server: os.Getenv(cli.ServerEnv),
+		In:     os.Stdin,
+		Out:    os.Stdout,
+		ErrOut: os.Stderr,
+	}
+	err := c.Run(ctx, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "chirpy-cli:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/dataloader"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+const (
+	// maxGraphQLDepth is how deeply fields may nest, e.g. chirp { author {
+	// chirps { author { id } } } } is 4 deep. Introspection doesn't count.
+	maxGraphQLDepth = 8
+	// maxGraphQLComplexity bounds the rows a query can ask for: each field
+	// costs 1, and a list's fields cost once per item it may return.
+	maxGraphQLComplexity = 2000
+
+	defaultGraphQLPage = 20
+	maxGraphQLPage     = 100
+)
+
+// graphQLError is a resolver error with a code in its extensions, like the
+// codes of problem details.
+type graphQLError struct {
+	code    string
+	message string
+}
+
+func (e graphQLError) Error() string { return e.message }
+
+func (e graphQLError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+var errGraphQLUnauthorized = graphQLError{code: "unauthorized", message: "Sign in to see this"}
+
+// graphQLContext is what resolvers need from the request. The loaders batch
+// the lookups that would otherwise run once per parent, and are only valid
+// for this request.
+type graphQLContext struct {
+	db     database.Querier
+	viewer uuid.UUID
+	users  *dataloader.Loader[uuid.UUID, database.User]
+	// chirps loads a page of the chirps each author's timeline shows the
+	// viewer
+	chirps *dataloader.Loader[authorPage, []database.Chirp]
+}
+
+// authorPage is the first chirps of an author's timeline, from the oldest
+// or the newest end.
+type authorPage struct {
+	author uuid.UUID
+	first  int
+	newest bool
+}
+
+type graphQLContextKey struct{}
+
+func newGraphQLContext(db database.Querier, viewer uuid.UUID) *graphQLContext {
+	return &graphQLContext{
+		db:     db,
+		viewer: viewer,
+		users: dataloader.New(func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]database.User, error) {
+			users, err := db.GetUsersFromIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := map[uuid.UUID]database.User{}
+			for _, user := range users {
+				byID[user.ID] = user
+			}
+			return byID, nil
+		}),
+		chirps: dataloader.New(func(ctx context.Context, pages []authorPage) (map[authorPage][]database.Chirp, error) {
+			// Authors whose pages are the same size and sort share a query,
+			// which is usually all of them
+			authors := map[authorPage][]uuid.UUID{}
+			for _, page := range pages {
+				shape := authorPage{first: page.first, newest: page.newest}
+				authors[shape] = append(authors[shape], page.author)
+			}
+			byPage := map[authorPage][]database.Chirp{}
+			for shape, ids := range authors {
+				chirps, err := db.GetAuthorsChirps(ctx, database.GetAuthorsChirpsParams{
+					ViewerID:    viewer,
+					UserIds:     ids,
+					NewestFirst: shape.newest,
+					PerAuthor:   int32(shape.first),
+				})
+				if err != nil {
+					return nil, err
+				}
+				for _, chirp := range chirps {
+					page := authorPage{author: chirp.UserID, first: shape.first, newest: shape.newest}
+					byPage[page] = append(byPage[page], chirp)
+				}
+			}
+			return byPage, nil
+		}),
+	}
+}
+
+func graphQLContextFrom(ctx context.Context) *graphQLContext {
+	return ctx.Value(graphQLContextKey{}).(*graphQLContext)
+}
+
+// loadUser resolves to the user with the given ID, or null.
+func loadUser(p graphql.ResolveParams, id uuid.UUID) (any, error) {
+	thunk := graphQLContextFrom(p.Context).users.Load(p.Context, id)
+	return func() (any, error) {
+		user, ok, err := thunk()
+		if err != nil || !ok {
+			return nil, err
+		}
+		return user, nil
+	}, nil
+}
+
+var sortOrderEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "SortOrder",
+	Values: graphql.EnumValueConfigMap{
+		"ASC":  &graphql.EnumValueConfig{Value: "asc", Description: "Oldest first"},
+		"DESC": &graphql.EnumValueConfig{Value: "desc", Description: "Newest first"},
+	},
+})
+
+// pageArgs are the arguments of every list field.
+func pageArgs() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"sort": &graphql.ArgumentConfig{Type: sortOrderEnum, DefaultValue: "asc"},
+		"first": &graphql.ArgumentConfig{
+			Type:         graphql.Int,
+			DefaultValue: defaultGraphQLPage,
+			Description:  fmt.Sprintf("How many chirps to return, at most %d", maxGraphQLPage),
+		},
+	}
+}
+
+// pageSize reads the page arguments: how many chirps, and whether they're
+// newest first.
+func pageSize(p graphql.ResolveParams) (first int, newest bool, err error) {
+	first, _ = p.Args["first"].(int)
+	if first < 1 || first > maxGraphQLPage {
+		return 0, false, graphQLError{code: "invalid_argument", message: fmt.Sprintf("first must be between 1 and %d", maxGraphQLPage)}
+	}
+	return first, p.Args["sort"] == "desc", nil
+}
+
+// page sorts chirps, which are oldest first, and cuts them to the page size.
+func page(p graphql.ResolveParams, chirps []database.Chirp) ([]database.Chirp, error) {
+	first, newest, err := pageSize(p)
+	if err != nil {
+		return nil, err
+	}
+	chirps = slices.Clone(chirps)
+	if newest {
+		slices.Reverse(chirps)
+	}
+	return chirps[:min(first, len(chirps))], nil
+}
+
+// idArg parses an ID argument as a UUID.
+func idArg(p graphql.ResolveParams, name string) (uuid.UUID, error) {
+	raw, _ := p.Args[name].(string)
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, graphQLError{code: "invalid_id", message: name + " isn't a valid UUID"}
+	}
+	return id, nil
+}
+
+var graphQLSchema = newGraphQLSchema()
+
+func newGraphQLSchema() graphql.Schema {
+	// User and Chirp refer to each other, so User's fields are a thunk
+	var userType, chirpType *graphql.Object
+	userType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.ID),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source.(database.User).ID.String(), nil
+					},
+				},
+				"email": &graphql.Field{
+					Type:        graphql.String,
+					Description: "Only shown to the user themselves",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						user := p.Source.(database.User)
+						if user.ID != graphQLContextFrom(p.Context).viewer {
+							return nil, nil
+						}
+						return user.Email, nil
+					},
+				},
+				"createdAt": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.DateTime),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source.(database.User).CreatedAt, nil
+					},
+				},
+				"isChirpyRed": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.Boolean),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source.(database.User).IsChirpyRed, nil
+					},
+				},
+				"chirps": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(chirpType))),
+					Description: "Published chirps, leaving out those the viewer has blocked or muted",
+					Args:        pageArgs(),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						first, newest, err := pageSize(p)
+						if err != nil {
+							return nil, err
+						}
+						thunk := graphQLContextFrom(p.Context).chirps.Load(p.Context, authorPage{
+							author: p.Source.(database.User).ID,
+							first:  first,
+							newest: newest,
+						})
+						return func() (any, error) {
+							chirps, _, err := thunk()
+							if err != nil {
+								return nil, err
+							}
+							// The query already cut the page; this only sorts it
+							return page(p, chirps)
+						}, nil
+					},
+				},
+			}
+		}),
+	})
+
+	chirpType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Chirp",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.Chirp).ID.String(), nil
+				},
+			},
+			"body": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.Chirp).Body, nil
+				},
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.DateTime),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.Chirp).CreatedAt, nil
+				},
+			},
+			"updatedAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.DateTime),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.Chirp).UpdatedAt, nil
+				},
+			},
+			"author": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadUser(p, p.Source.(database.Chirp).UserID)
+				},
+			},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{
+				Type:        userType,
+				Description: "The signed-in user",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					viewer := graphQLContextFrom(p.Context).viewer
+					if viewer == uuid.Nil {
+						return nil, errGraphQLUnauthorized
+					}
+					return loadUser(p, viewer)
+				},
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id, err := idArg(p, "id")
+					if err != nil {
+						return nil, err
+					}
+					return loadUser(p, id)
+				},
+			},
+			"chirp": &graphql.Field{
+				Type:        chirpType,
+				Description: "A published chirp, unless the viewer and its author have blocked each other",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id, err := idArg(p, "id")
+					if err != nil {
+						return nil, err
+					}
+					gc := graphQLContextFrom(p.Context)
+					chirp, err := gc.db.GetVisibleChirp(p.Context, database.GetVisibleChirpParams{
+						ID:       id,
+						ViewerID: gc.viewer,
+					})
+					if errors.Is(err, sql.ErrNoRows) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, err
+					}
+					return chirp, nil
+				},
+			},
+			"chirps": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(chirpType))),
+				Description: "Published chirps, leaving out those the viewer has blocked or muted",
+				Args:        pageArgs(),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					gc := graphQLContextFrom(p.Context)
+					chirps, err := gc.db.GetChirps(p.Context, gc.viewer)
+					if err != nil {
+						return nil, err
+					}
+					return page(p, chirps)
+				},
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query})
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+type graphQLRequest struct {
+	Query         string         `json:"query" validate:"required"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// graphql serves queries over the users and chirps. Errors in the query
+// itself are reported GraphQL's way, in a 200 response's errors; only a
+// request that isn't a GraphQL request at all gets problem details.
+func (cfg *apiConfig) graphql(w http.ResponseWriter, r *http.Request) {
+	viewer, err := cfg.optionalViewer(r)
+	if err != nil {
+		respondWithError(w, r, errUnauthorized, "JWT validation failed", err)
+		return
+	}
+	params, ok := decode[graphQLRequest](w, r)
+	if !ok {
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(params.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		respondWithJSON(w, http.StatusOK, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+	validation := graphql.ValidateDocument(&graphQLSchema, doc, nil)
+	if !validation.IsValid {
+		respondWithJSON(w, http.StatusOK, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+	if errs := checkGraphQLLimits(doc, params.OperationName, params.Variables); len(errs) > 0 {
+		respondWithJSON(w, http.StatusOK, &graphql.Result{Errors: errs})
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), graphQLContextKey{}, newGraphQLContext(cfg.database, viewer))
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        graphQLSchema,
+		AST:           doc,
+		OperationName: params.OperationName,
+		Args:          params.Variables,
+		Context:       ctx,
+	})
+	for _, e := range result.Errors {
+		// Errors resolvers chose to show are expected; anything else, like a
+		// failed query, is the server's fault
+		err := e.OriginalError()
+		if located, ok := err.(*gqlerrors.Error); ok {
+			err = located.OriginalError
+		}
+		if _, ok := err.(graphQLError); !ok {
+			logging.FromContext(r.Context()).Error("GraphQL resolver failed", "path", e.Path, "err", err)
+		}
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// checkGraphQLLimits rejects operations that nest too deeply or could
+// return too many rows, before any of them run.
+func checkGraphQLLimits(doc *ast.Document, operationName string, variables map[string]any) []gqlerrors.FormattedError {
+	fragments := map[string]*ast.FragmentDefinition{}
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		}
+	}
+	if operation == nil {
+		// Execute reports the unknown operation
+		return nil
+	}
+
+	m := graphQLMeasure{fragments: fragments, variables: variables}
+	depth, complexity := m.selectionSet(operation.SelectionSet)
+	var errs []gqlerrors.FormattedError
+	if depth > maxGraphQLDepth {
+		errs = append(errs, formatGraphQLError(graphQLError{
+			code:    "query_too_deep",
+			message: fmt.Sprintf("Query is %d fields deep, more than the limit of %d", depth, maxGraphQLDepth),
+		}))
+	}
+	if complexity > maxGraphQLComplexity {
+		errs = append(errs, formatGraphQLError(graphQLError{
+			code:    "query_too_complex",
+			message: fmt.Sprintf("Query has a complexity of %d, more than the limit of %d", complexity, maxGraphQLComplexity),
+		}))
+	}
+	return errs
+}
+
+// formatGraphQLError formats err with its code in its extensions, the way
+// Execute does for errors resolvers return.
+func formatGraphQLError(err graphQLError) gqlerrors.FormattedError {
+	return gqlerrors.FormatError(&gqlerrors.Error{Message: err.message, OriginalError: err})
+}
+
+// graphQLMeasure works out the depth and complexity of a selection set.
+// Validation has already ruled out fragment cycles.
+type graphQLMeasure struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+}
+
+func (m graphQLMeasure) selectionSet(set *ast.SelectionSet) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, selection := range set.Selections {
+		var d, c int
+		switch s := selection.(type) {
+		case *ast.Field:
+			// Introspection is bounded by the schema, and clients send deep
+			// introspection queries
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+			d, c = m.selectionSet(s.SelectionSet)
+			d++
+			c = 1 + m.listSize(s)*c
+		case *ast.InlineFragment:
+			d, c = m.selectionSet(s.SelectionSet)
+		case *ast.FragmentSpread:
+			if fragment, ok := m.fragments[s.Name.Value]; ok {
+				d, c = m.selectionSet(fragment.SelectionSet)
+			}
+		}
+		depth = max(depth, d)
+		complexity += c
+	}
+	return depth, complexity
+}
+
+// listSize is how many items a field may return: its first argument for
+// lists, and 1 for everything else.
+func (m graphQLMeasure) listSize(field *ast.Field) int {
+	if field.SelectionSet == nil || (field.Name.Value != "chirps") {
+		return 1
+	}
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				return max(n, 1)
+			}
+		case *ast.Variable:
+			// JSON numbers decode as float64
+			if n, ok := m.variables[v.Name.Value].(float64); ok {
+				return max(int(n), 1)
+			}
+		}
+		// Out of range values fail in the resolver anyway
+		return maxGraphQLPage
+	}
+	return defaultGraphQLPage
+}
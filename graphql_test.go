@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store/memory"
+	"github.com/google/uuid"
+)
+
+type graphQLResult struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Path       []any          `json:"path"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func (ts *testServer) graphql(token, query string, variables map[string]any) graphQLResult {
+	ts.t.Helper()
+	resp := ts.do("POST", "/api/graphql", token, graphQLRequest{Query: query, Variables: variables})
+	return expect[graphQLResult](ts.t, resp, http.StatusOK)
+}
+
+// countingStore counts the batched lookups, to catch resolvers that query
+// once per parent, and records how many chirps per author were asked for.
+type countingStore struct {
+	*memory.Store
+	userLookups  atomic.Int32
+	chirpLookups atomic.Int32
+	perAuthor    atomic.Int32
+}
+
+func (s *countingStore) GetUsersFromIDs(ctx context.Context, ids []uuid.UUID) ([]database.User, error) {
+	s.userLookups.Add(1)
+	return s.Store.GetUsersFromIDs(ctx, ids)
+}
+
+func (s *countingStore) GetAuthorsChirps(ctx context.Context, arg database.GetAuthorsChirpsParams) ([]database.Chirp, error) {
+	s.chirpLookups.Add(1)
+	s.perAuthor.Store(arg.PerAuthor)
+	return s.Store.GetAuthorsChirps(ctx, arg)
+}
+
+func TestGraphQLBatching(t *testing.T) {
+	ts := newTestServer(t)
+	counting := &countingStore{Store: ts.store}
+	ts.cfg.database = counting
+	for _, email := range []string{"walt@breakingbad.com", "jesse@breakingbad.com", "skyler@breakingbad.com"} {
+		user := ts.signUp(email)
+		ts.chirp(user.Token, "Hello from "+email)
+		ts.chirp(user.Token, "Goodbye from "+email)
+	}
+
+	result := ts.graphql("", `{
+		chirps(sort: DESC) {
+			body
+			author { id chirps(first: 1) { body author { id } } }
+		}
+	}`, nil)
+	if len(result.Errors) > 0 {
+		t.Fatalf("got errors %+v", result.Errors)
+	}
+	var data struct {
+		Chirps []struct {
+			Body   string `json:"body"`
+			Author struct {
+				ID     string `json:"id"`
+				Chirps []struct {
+					Body string `json:"body"`
+				} `json:"chirps"`
+			} `json:"author"`
+		} `json:"chirps"`
+	}
+	err := json.Unmarshal(result.Data, &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Chirps) != 6 || data.Chirps[0].Body != "Goodbye from skyler@breakingbad.com" {
+		t.Fatalf("got %+v", data.Chirps)
+	}
+	for _, chirp := range data.Chirps {
+		if len(chirp.Author.Chirps) != 1 || !strings.HasPrefix(chirp.Author.Chirps[0].Body, "Hello") {
+			t.Errorf("author's first chirp is %+v", chirp.Author.Chirps)
+		}
+	}
+	// One lookup for all six chirps' authors, which the nested authors reuse
+	if got := counting.userLookups.Load(); got != 1 {
+		t.Errorf("looked up users %d times, want 1", got)
+	}
+	if got := counting.chirpLookups.Load(); got != 1 {
+		t.Errorf("looked up authors' chirps %d times, want 1", got)
+	}
+	// The page size is cut in the query, not after fetching every chirp
+	if got := counting.perAuthor.Load(); got != 1 {
+		t.Errorf("asked for %d chirps per author, want 1", got)
+	}
+}
+
+func TestGraphQLViewer(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+	ts.chirp(jesse.Token, "Yeah, science!")
+
+	result := ts.graphql("", `{ me { id } }`, nil)
+	if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != "unauthorized" {
+		t.Errorf("got errors %+v for me without a token, want unauthorized", result.Errors)
+	}
+
+	query := `query($id: ID!) { user(id: $id) { email chirps { body } } }`
+	tests := []struct {
+		name      string
+		token     string
+		wantEmail string
+	}{
+		{name: "Anonymous", token: "", wantEmail: "null"},
+		{name: "Self", token: jesse.Token, wantEmail: `"jesse@breakingbad.com"`},
+		{name: "Other", token: walt.Token, wantEmail: "null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ts.graphql(tt.token, query, map[string]any{"id": jesse.ID.String()})
+			if len(result.Errors) > 0 {
+				t.Fatalf("got errors %+v", result.Errors)
+			}
+			var data struct {
+				User struct {
+					Email  json.RawMessage `json:"email"`
+					Chirps []struct {
+						Body string `json:"body"`
+					} `json:"chirps"`
+				} `json:"user"`
+			}
+			json.Unmarshal(result.Data, &data)
+			if string(data.User.Email) != tt.wantEmail {
+				t.Errorf("got email %s, want %s", data.User.Email, tt.wantEmail)
+			}
+			if len(data.User.Chirps) != 1 {
+				t.Errorf("got chirps %+v", data.User.Chirps)
+			}
+		})
+	}
+
+	resp := ts.do("POST", "/api/graphql", "garbage", graphQLRequest{Query: "{ me { id } }"})
+	if got := expect[problem](t, resp, http.StatusUnauthorized); got.Code != "unauthorized" {
+		t.Errorf("got %+v with a bad token, want an unauthorized problem", got)
+	}
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	ts := newTestServer(t)
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]any
+		wantCode  string
+	}{
+		{
+			name:  "Shallow",
+			query: `{ chirps { body author { chirps { id } } } }`,
+		},
+		{
+			name:     "TooDeep",
+			query:    `{ chirps(first: 1) { author { chirps(first: 1) { author { chirps(first: 1) { author { chirps(first: 1) { author { id } } } } } } } } }`,
+			wantCode: "query_too_deep",
+		},
+		{
+			name:     "TooComplex",
+			query:    `{ chirps(first: 100) { author { chirps(first: 100) { id } } } }`,
+			wantCode: "query_too_complex",
+		},
+		{
+			name:      "TooComplexVariable",
+			query:     `query($n: Int) { chirps(first: $n) { author { chirps(first: $n) { id } } } }`,
+			variables: map[string]any{"n": 100},
+			wantCode:  "query_too_complex",
+		},
+		{
+			name: "TooComplexFragment",
+			query: `{ chirps(first: 100) { ...author } }
+				fragment author on Chirp { author { chirps(first: 100) { id } } }`,
+			wantCode: "query_too_complex",
+		},
+		{
+			name:     "PageTooBig",
+			query:    `{ chirps(first: 1000) { id } }`,
+			wantCode: "invalid_argument",
+		},
+		{
+			name:  "Introspection",
+			query: `{ __schema { types { name fields { name type { name ofType { name ofType { name ofType { name } } } } } } } }`,
+		},
+		{
+			name:     "InvalidID",
+			query:    `{ user(id: "heisenberg") { id } }`,
+			wantCode: "invalid_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ts.graphql("", tt.query, tt.variables)
+			if tt.wantCode == "" {
+				if len(result.Errors) > 0 {
+					t.Errorf("got errors %+v", result.Errors)
+				}
+				return
+			}
+			if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != tt.wantCode {
+				t.Errorf("got errors %+v, want %s", result.Errors, tt.wantCode)
+			}
+		})
+	}
+
+	// Errors in the query are GraphQL errors, not problems
+	result := ts.graphql("", `{ chirps { nope } }`, nil)
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "nope") {
+		t.Errorf("got errors %+v for an unknown field", result.Errors)
+	}
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/auth"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/tracing"
+	chirpyv1 "github.com/Brandon-Butterbaugh/Chirbooty.git/proto/chirpy/v1"
+	"github.com/google/uuid"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer serves the gRPC API in proto/chirpy/v1. Each RPC does what its
+// HTTP handler does, by calling the same methods.
+func (cfg *apiConfig) grpcServer() *grpc.Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(cfg.logUnary, cfg.rateLimitUnary),
+		grpc.ChainStreamInterceptor(cfg.logStream, cfg.rateLimitStream),
+	)
+	chirpyv1.RegisterUserServiceServer(s, userService{cfg: cfg})
+	chirpyv1.RegisterAuthServiceServer(s, authService{cfg: cfg})
+	chirpyv1.RegisterChirpServiceServer(s, chirpService{cfg: cfg})
+	return s
+}
+
+// logUnary is logRequests for unary RPCs.
+func (cfg *apiConfig) logUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, done := startRPC(ctx, info.FullMethod)
+	resp, err := handler(ctx, req)
+	done(err)
+	return resp, err
+}
+
+// logStream is logRequests for streaming RPCs.
+func (cfg *apiConfig) logStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, done := startRPC(ss.Context(), info.FullMethod)
+	err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	done(err)
+	return err
+}
+
+// rpcPolicies are the rate limits on RPCs, the same ones their routes have.
+var rpcPolicies = map[string]rateLimitPolicy{
+	chirpyv1.UserService_CreateUser_FullMethodName:   signupPolicy,
+	chirpyv1.AuthService_Login_FullMethodName:        loginPolicy,
+	chirpyv1.ChirpService_CreateChirp_FullMethodName: chirpPolicy,
+	chirpyv1.ChirpService_UpdateDraft_FullMethodName: chirpPolicy,
+}
+
+// rateLimitUnary is rateLimit for unary RPCs.
+func (cfg *apiConfig) rateLimitUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := cfg.allowRPC(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// rateLimitStream is rateLimit for streaming RPCs.
+func (cfg *apiConfig) rateLimitStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := cfg.allowRPC(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// allowRPC spends one call from the method's rate limit, if it has one,
+// taking it out of the same bucket the HTTP API uses. A refusal is
+// ResourceExhausted, with a RetryInfo and a retry-after header saying how
+// long to wait.
+func (cfg *apiConfig) allowRPC(ctx context.Context, method string) error {
+	policy, ok := rpcPolicies[method]
+	if !ok || cfg.limiter == nil {
+		return nil
+	}
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+	key, limit := cfg.rateLimitKey(ctx, incomingHeader(ctx), remoteAddr, policy)
+	res, err := cfg.limiter.Allow(ctx, policy.name+":"+key, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("Couldn't check rate limit", "policy", policy.name, "err", err)
+		return nil
+	}
+	if res.Allowed {
+		return nil
+	}
+
+	cfg.metrics.RateLimited.WithLabelValues(policy.name).Inc()
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", seconds(res.RetryAfter)))
+	st := status.Convert(rpcError(ctx, failure(errRateLimited, "Too many requests, try again later", nil)))
+	withRetry, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(res.RetryAfter)})
+	if err != nil {
+		return st.Err()
+	}
+	return withRetry.Err()
+}
+
+// startRPC gives a call a request ID, a logger that carries it and a server
+// span, like logRequests does for HTTP requests. done writes the access log
+// line once the call returns.
+func startRPC(ctx context.Context, method string) (_ context.Context, done func(error)) {
+	start := time.Now()
+	header := incomingHeader(ctx)
+	id := header.Get(requestIDHeader)
+	if !validRequestID(id) {
+		id = uuid.NewString()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, id))
+
+	ctx, span := tracing.Tracer().Start(tracing.Extract(ctx, header), strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.RPCSystemNameGRPC, semconv.RPCMethod(method)),
+	)
+	logger := slog.Default().With("request_id", id)
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	ctx = logging.NewContext(ctx, logger)
+
+	return ctx, func(err error) {
+		defer span.End()
+		code := status.Code(err)
+		level := slog.LevelInfo
+		if code == codes.Internal || code == codes.Unknown {
+			level = slog.LevelError
+			span.SetStatus(otelcodes.Error, code.String())
+		}
+		span.SetAttributes(semconv.RPCResponseStatusCode(code.String()))
+		logging.FromContext(ctx).LogAttrs(ctx, level, "rpc",
+			slog.String("method", method),
+			slog.String("code", code.String()),
+			slog.Duration("latency", time.Since(start)),
+		)
+	}
+}
+
+// serverStream is a grpc.ServerStream with the context startRPC made.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
+
+// incomingHeader returns the call's metadata as an HTTP header, for the code
+// that reads tokens and trace context from one.
+func incomingHeader(ctx context.Context) http.Header {
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := http.Header{}
+	for key, values := range md {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	return header
+}
+
+// rpcUser validates the access JWT in the call's "authorization" metadata.
+func (cfg *apiConfig) rpcUser(ctx context.Context) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(incomingHeader(ctx))
+	if err != nil {
+		return uuid.Nil, rpcError(ctx, failure(errUnauthorized, "Authorization metadata failed", err))
+	}
+	id, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return uuid.Nil, rpcError(ctx, failure(errUnauthorized, "JWT validation failed", err))
+	}
+	setRequestUser(ctx, id)
+	return id, nil
+}
+
+// rpcViewer is rpcUser for calls that may be anonymous, like optionalViewer.
+func (cfg *apiConfig) rpcViewer(ctx context.Context) (uuid.UUID, error) {
+	if len(metadata.ValueFromIncomingContext(ctx, "authorization")) == 0 {
+		return uuid.Nil, nil
+	}
+	return cfg.rpcUser(ctx)
+}
+
+// rpcError turns an error from the shared logic into a status, logging it
+// the way respondWithError does. The problem code goes in an ErrorInfo, and
+// what's wrong with each field in a BadRequest.
+func rpcError(ctx context.Context, err error) error {
+	var reqErr *requestError
+	if !errors.As(err, &reqErr) {
+		reqErr = &requestError{kind: errInternal, detail: "Unexpected error", err: err}
+	}
+	logger := logging.FromContext(ctx)
+	if reqErr.kind.status > 499 {
+		logger.Error("Responding with internal error", "msg", reqErr.detail, "err", reqErr.err)
+	} else if reqErr.err != nil {
+		logger.Info(reqErr.detail, "err", reqErr.err)
+	}
+
+	st := status.New(rpcCode(reqErr.kind), reqErr.detail)
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: reqErr.kind.code, Domain: "chirpy"}}
+	if len(reqErr.fields) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, f := range reqErr.fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				// "/publish_at" becomes "publish_at"; the messages use the
+				// same names as the JSON bodies
+				Field:       strings.ReplaceAll(strings.TrimPrefix(f.Pointer, "/"), "/", "."),
+				Reason:      f.Code,
+				Description: f.Detail,
+			})
+		}
+		details = append(details, badRequest)
+	}
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// rpcCode is the gRPC code closest to kind's HTTP status.
+func rpcCode(kind apiError) codes.Code {
+	switch kind.status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	}
+	return codes.Internal
+}
+
+// parseID parses a UUID field of a request message.
+func parseID(field, id string) (uuid.UUID, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, invalid(fieldError{Pointer: pointer(field), Code: "invalid_id", Detail: field + " isn't a valid UUID"})
+	}
+	return parsed, nil
+}
+
+// check validates a request message's parameters the way decode does.
+func check(params any) error {
+	if errs := validateParams(params); len(errs) > 0 {
+		return invalid(errs...)
+	}
+	return nil
+}
+
+func userMessage(user User) *chirpyv1.User {
+	return &chirpyv1.User{
+		Id:          user.ID.String(),
+		CreatedAt:   timestamppb.New(user.CreatedAt),
+		UpdatedAt:   timestamppb.New(user.UpdatedAt),
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+}
+
+var chirpStatuses = map[string]chirpyv1.ChirpStatus{
+	chirpDraft:     chirpyv1.ChirpStatus_CHIRP_STATUS_DRAFT,
+	chirpScheduled: chirpyv1.ChirpStatus_CHIRP_STATUS_SCHEDULED,
+	chirpPublished: chirpyv1.ChirpStatus_CHIRP_STATUS_PUBLISHED,
+}
+
+func chirpMessage(chirp Chirp) *chirpyv1.Chirp {
+	msg := &chirpyv1.Chirp{
+		Id:        chirp.ID.String(),
+		CreatedAt: timestamppb.New(chirp.CreatedAt),
+		UpdatedAt: timestamppb.New(chirp.UpdatedAt),
+		Body:      chirp.Body,
+		UserId:    chirp.UserID.String(),
+		Status:    chirpStatuses[chirp.Status],
+	}
+	if chirp.PublishAt != nil {
+		msg.PublishAt = timestamppb.New(*chirp.PublishAt)
+	}
+	return msg
+}
+
+func chirpMessages(chirps []Chirp) []*chirpyv1.Chirp {
+	msgs := make([]*chirpyv1.Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		msgs = append(msgs, chirpMessage(chirp))
+	}
+	return msgs
+}
+
+type userService struct {
+	chirpyv1.UnimplementedUserServiceServer
+	cfg *apiConfig
+}
+
+func (s userService) CreateUser(ctx context.Context, req *chirpyv1.CreateUserRequest) (*chirpyv1.CreateUserResponse, error) {
+	params := newUserParameters{Email: req.Email, Password: req.Password}
+	if err := check(params); err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	user, err := s.cfg.createUser(ctx, params)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	return &chirpyv1.CreateUserResponse{User: userMessage(user)}, nil
+}
+
+func (s userService) UpdateUser(ctx context.Context, req *chirpyv1.UpdateUserRequest) (*chirpyv1.UpdateUserResponse, error) {
+	id, err := s.cfg.rpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params := userUpdateParameters{Email: req.Email, Password: req.Password}
+	if err := check(params); err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	user, err := s.cfg.editUser(ctx, id, params)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	return &chirpyv1.UpdateUserResponse{User: userMessage(user)}, nil
+}
+
+type authService struct {
+	chirpyv1.UnimplementedAuthServiceServer
+	cfg *apiConfig
+}
+
+func (s authService) Login(ctx context.Context, req *chirpyv1.LoginRequest) (*chirpyv1.LoginResponse, error) {
+	params := loginParameters{Email: req.Email, Password: req.Password}
+	if err := check(params); err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	user, err := s.cfg.logIn(ctx, params)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	return &chirpyv1.LoginResponse{
+		User:         userMessage(user),
+		AccessToken:  user.Token,
+		RefreshToken: user.RefreshToken,
+	}, nil
+}
+
+func (s authService) Refresh(ctx context.Context, req *chirpyv1.RefreshRequest) (*chirpyv1.RefreshResponse, error) {
+	token, err := s.cfg.refreshAccess(ctx, req.RefreshToken)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	return &chirpyv1.RefreshResponse{AccessToken: token}, nil
+}
+
+func (s authService) Revoke(ctx context.Context, req *chirpyv1.RevokeRequest) (*chirpyv1.RevokeResponse, error) {
+	err := s.cfg.revokeRefresh(ctx, req.RefreshToken)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	return &chirpyv1.RevokeResponse{}, nil
+}
+
+type chirpService struct {
+	chirpyv1.UnimplementedChirpServiceServer
+	cfg *apiConfig
+}
+
+// chirpParams reads the chirp fields that CreateChirpRequest and
+// UpdateDraftRequest share.
+func chirpParams(body string, draft bool, publishAt *timestamppb.Timestamp) (chirpParameters, error) {
+	params := chirpParameters{Body: body, Draft: draft}
+	if publishAt != nil {
+		t := publishAt.AsTime()
+		params.PublishAt = &t
+	}
+	return params, check(params)
+}
+
+func (s chirpService) CreateChirp(ctx context.Context, req *chirpyv1.CreateChirpRequest) (*chirpyv1.CreateChirpResponse, error) {
+	id, err := s.cfg.rpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params, err := chirpParams(req.Body, req.Draft, req.PublishAt)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	chirp, err := s.cfg.createChirp(ctx, id, params)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	return &chirpyv1.CreateChirpResponse{Chirp: chirpMessage(chirp)}, nil
+}
+
+func (s chirpService) GetChirp(ctx context.Context, req *chirpyv1.GetChirpRequest) (*chirpyv1.GetChirpResponse, error) {
+	viewer, err := s.cfg.rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseID("id", req.Id)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	chirp, err := s.cfg.visibleChirp(ctx, viewer, id)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	return &chirpyv1.GetChirpResponse{Chirp: chirpMessage(chirp)}, nil
+}
+
+func (s chirpService) ListChirps(ctx context.Context, req *chirpyv1.ListChirpsRequest) (*chirpyv1.ListChirpsResponse, error) {
+	viewer, err := s.cfg.rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var authorID uuid.UUID
+	if req.AuthorId != "" {
+		authorID, err = parseID("author_id", req.AuthorId)
+		if err != nil {
+			return nil, rpcError(ctx, err)
+		}
+	}
+	chirps, err := s.cfg.listChirps(ctx, viewer, authorID, req.Descending)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	return &chirpyv1.ListChirpsResponse{Chirps: chirpMessages(chirps)}, nil
+}
+
+func (s chirpService) ListDrafts(ctx context.Context, req *chirpyv1.ListDraftsRequest) (*chirpyv1.ListDraftsResponse, error) {
+	id, err := s.cfg.rpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	drafts, err := s.cfg.drafts(ctx, id)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	return &chirpyv1.ListDraftsResponse{Chirps: chirpMessages(drafts)}, nil
+}
+
+func (s chirpService) UpdateDraft(ctx context.Context, req *chirpyv1.UpdateDraftRequest) (*chirpyv1.UpdateDraftResponse, error) {
+	id, err := s.cfg.rpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	chirpID, err := parseID("id", req.Id)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	params, err := chirpParams(req.Body, req.Draft, req.PublishAt)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	chirp, err := s.cfg.editDraft(ctx, id, chirpID, params)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	return &chirpyv1.UpdateDraftResponse{Chirp: chirpMessage(chirp)}, nil
+}
+
+func (s chirpService) DeleteChirp(ctx context.Context, req *chirpyv1.DeleteChirpRequest) (*chirpyv1.DeleteChirpResponse, error) {
+	id, err := s.cfg.rpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	chirpID, err := parseID("id", req.Id)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	err = s.cfg.removeChirp(ctx, id, chirpID)
+	if err != nil {
+		return nil, rpcError(ctx, err)
+	}
+	return &chirpyv1.DeleteChirpResponse{}, nil
+}
+
+// WatchChirps is /api/stream narrowed to chirps. A caller that falls too far
+// behind gets Unavailable, and resumes with the last event ID it saw.
+func (s chirpService) WatchChirps(req *chirpyv1.WatchChirpsRequest, stream grpc.ServerStreamingServer[chirpyv1.WatchChirpsResponse]) error {
+	ctx := stream.Context()
+	id, err := s.cfg.rpcUser(ctx)
+	if err != nil {
+		return err
+	}
+	feed, err := s.cfg.followEvents(ctx, id, req.LastEventId)
+	if err != nil {
+		return rpcError(ctx, failure(errInternal, "Couldn't load blocks", err))
+	}
+	defer feed.Close()
+
+	err = feed.run(ctx, func(ev database.Event) error {
+		resp := &chirpyv1.WatchChirpsResponse{EventId: ev.ID}
+		switch ev.Type {
+		case events.ChirpCreated:
+			var chirp Chirp
+			if err := json.Unmarshal(ev.Payload, &chirp); err != nil {
+				return err
+			}
+			resp.Event = &chirpyv1.WatchChirpsResponse_Created{Created: chirpMessage(chirp)}
+		case events.ChirpDeleted:
+			var deleted struct {
+				ID uuid.UUID `json:"id"`
+			}
+			if err := json.Unmarshal(ev.Payload, &deleted); err != nil {
+				return err
+			}
+			resp.Event = &chirpyv1.WatchChirpsResponse_DeletedId{DeletedId: deleted.ID.String()}
+		default:
+			return nil
+		}
+		return stream.Send(resp)
+	}, func() error {
+		// gRPC keeps the connection alive itself
+		return nil
+	})
+	switch {
+	case errors.Is(err, errFeedDropped):
+		return status.Error(codes.Unavailable, "Fell behind; resume from the last event ID")
+	case ctx.Err() != nil:
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return rpcError(ctx, err)
+}
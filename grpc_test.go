@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/ratelimit"
+	chirpyv1 "github.com/Brandon-Butterbaugh/Chirbooty.git/proto/chirpy/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// grpcClients are clients for the test server's gRPC API.
+type grpcClients struct {
+	users  chirpyv1.UserServiceClient
+	auth   chirpyv1.AuthServiceClient
+	chirps chirpyv1.ChirpServiceClient
+}
+
+// grpc serves the gRPC API over an in-memory connection, sharing the test
+// server's config and store.
+func (ts *testServer) grpc() grpcClients {
+	ts.t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	srv := ts.cfg.grpcServer()
+	go srv.Serve(listener)
+	ts.t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///chirpy",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	ts.t.Cleanup(func() { conn.Close() })
+	return grpcClients{
+		users:  chirpyv1.NewUserServiceClient(conn),
+		auth:   chirpyv1.NewAuthServiceClient(conn),
+		chirps: chirpyv1.NewChirpServiceClient(conn),
+	}
+}
+
+// withToken authenticates calls made with the returned context.
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// expectCode fails the test unless err is a status with code, and returns
+// the reason from its ErrorInfo.
+func expectCode(t *testing.T, err error, code codes.Code) string {
+	t.Helper()
+	st := status.Convert(err)
+	if st.Code() != code {
+		t.Fatalf("got %v, want %v", err, code)
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	t.Fatalf("%v has no ErrorInfo", err)
+	return ""
+}
+
+func TestGRPCUsers(t *testing.T) {
+	ts := newTestServer(t)
+	clients := ts.grpc()
+	ctx := t.Context()
+
+	_, err := clients.users.CreateUser(ctx, &chirpyv1.CreateUserRequest{Email: "walt", Password: testPassword})
+	expectCode(t, err, codes.InvalidArgument)
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = badRequest.FieldViolations
+		}
+	}
+	if len(violations) != 1 || violations[0].Field != "email" || violations[0].Reason != "invalid_email" {
+		t.Errorf("got field violations %v", violations)
+	}
+
+	created, err := clients.users.CreateUser(ctx, &chirpyv1.CreateUserRequest{Email: "walt@breakingbad.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	_, err = clients.auth.Login(ctx, &chirpyv1.LoginRequest{Email: "walt@breakingbad.com", Password: "wrong"})
+	if reason := expectCode(t, err, codes.Unauthenticated); reason != "invalid_credentials" {
+		t.Errorf("got reason %q for the wrong password", reason)
+	}
+	login, err := clients.auth.Login(ctx, &chirpyv1.LoginRequest{Email: "walt@breakingbad.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if login.User.Id != created.User.Id {
+		t.Errorf("logged in as %s, want %s", login.User.Id, created.User.Id)
+	}
+
+	_, err = clients.users.UpdateUser(ctx, &chirpyv1.UpdateUserRequest{Email: "heisenberg@breakingbad.com"})
+	expectCode(t, err, codes.Unauthenticated)
+	updated, err := clients.users.UpdateUser(withToken(ctx, login.AccessToken), &chirpyv1.UpdateUserRequest{Email: "heisenberg@breakingbad.com"})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if updated.User.Email != "heisenberg@breakingbad.com" {
+		t.Errorf("got email %s after updating it", updated.User.Email)
+	}
+
+	refreshed, err := clients.auth.Refresh(ctx, &chirpyv1.RefreshRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if refreshed.AccessToken == "" {
+		t.Error("Refresh returned no access token")
+	}
+	_, err = clients.auth.Revoke(ctx, &chirpyv1.RevokeRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	_, err = clients.auth.Refresh(ctx, &chirpyv1.RefreshRequest{RefreshToken: login.RefreshToken})
+	expectCode(t, err, codes.Unauthenticated)
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	ts := newTestServer(t)
+	ts.cfg.limiter = ratelimit.New(ratelimit.NewMemoryStore())
+	clients := ts.grpc()
+	ts.signUp("walt@breakingbad.com")
+	guess := &chirpyv1.LoginRequest{Email: "walt@breakingbad.com", Password: "wrong"}
+
+	for i := range loginPolicy.limit.Burst {
+		_, err := clients.auth.Login(t.Context(), guess)
+		if code := status.Code(err); code != codes.Unauthenticated {
+			t.Fatalf("login %d: got %v, want Unauthenticated", i+1, err)
+		}
+	}
+
+	var header metadata.MD
+	_, err := clients.auth.Login(t.Context(), guess, grpc.Header(&header))
+	if reason := expectCode(t, err, codes.ResourceExhausted); reason != "rate_limited" {
+		t.Errorf("got reason %q, want rate_limited", reason)
+	}
+	var retry *errdetails.RetryInfo
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retry = info
+		}
+	}
+	if retry == nil || retry.RetryDelay.AsDuration() <= 0 {
+		t.Errorf("got retry info %v, want a positive delay", retry)
+	}
+	if got := header.Get("retry-after"); len(got) != 1 || got[0] == "0" {
+		t.Errorf("got retry-after %v", got)
+	}
+}
+
+func TestGRPCChirps(t *testing.T) {
+	ts := newTestServer(t)
+	clients := ts.grpc()
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+	ctx := withToken(t.Context(), walt.Token)
+
+	created, err := clients.chirps.CreateChirp(ctx, &chirpyv1.CreateChirpRequest{Body: "I am the one who knocks"})
+	if err != nil {
+		t.Fatalf("CreateChirp: %v", err)
+	}
+	if created.Chirp.Status != chirpyv1.ChirpStatus_CHIRP_STATUS_PUBLISHED || created.Chirp.UserId != walt.ID.String() {
+		t.Errorf("created %v", created.Chirp)
+	}
+	// The same chirp the HTTP API sees
+	got := expect[Chirp](t, ts.do("GET", "/api/chirps/"+created.Chirp.Id, "", nil), http.StatusOK)
+	if got.Body != "I am the one who knocks" {
+		t.Errorf("GET /api/chirps/%s has body %q", created.Chirp.Id, got.Body)
+	}
+
+	_, err = clients.chirps.CreateChirp(ctx, &chirpyv1.CreateChirpRequest{})
+	expectCode(t, err, codes.InvalidArgument)
+	_, err = clients.chirps.CreateChirp(t.Context(), &chirpyv1.CreateChirpRequest{Body: "Anonymous"})
+	expectCode(t, err, codes.Unauthenticated)
+
+	draft, err := clients.chirps.CreateChirp(ctx, &chirpyv1.CreateChirpRequest{Body: "Say my name", Draft: true})
+	if err != nil {
+		t.Fatalf("CreateChirp draft: %v", err)
+	}
+	drafts, err := clients.chirps.ListDrafts(ctx, &chirpyv1.ListDraftsRequest{})
+	if err != nil {
+		t.Fatalf("ListDrafts: %v", err)
+	}
+	if len(drafts.Chirps) != 1 || drafts.Chirps[0].Id != draft.Chirp.Id {
+		t.Errorf("got drafts %v", drafts.Chirps)
+	}
+	_, err = clients.chirps.UpdateDraft(withToken(t.Context(), jesse.Token), &chirpyv1.UpdateDraftRequest{Id: draft.Chirp.Id, Body: "Yo"})
+	expectCode(t, err, codes.PermissionDenied)
+	published, err := clients.chirps.UpdateDraft(ctx, &chirpyv1.UpdateDraftRequest{Id: draft.Chirp.Id, Body: "Say my name."})
+	if err != nil {
+		t.Fatalf("UpdateDraft: %v", err)
+	}
+	if published.Chirp.Status != chirpyv1.ChirpStatus_CHIRP_STATUS_PUBLISHED {
+		t.Errorf("got status %v after publishing the draft", published.Chirp.Status)
+	}
+	_, err = clients.chirps.UpdateDraft(ctx, &chirpyv1.UpdateDraftRequest{Id: draft.Chirp.Id, Body: "Too late"})
+	expectCode(t, err, codes.FailedPrecondition)
+
+	list, err := clients.chirps.ListChirps(t.Context(), &chirpyv1.ListChirpsRequest{AuthorId: walt.ID.String(), Descending: true})
+	if err != nil {
+		t.Fatalf("ListChirps: %v", err)
+	}
+	if len(list.Chirps) != 2 || list.Chirps[0].Id != draft.Chirp.Id {
+		t.Errorf("got chirps %v, want the published draft first", list.Chirps)
+	}
+
+	_, err = clients.chirps.GetChirp(t.Context(), &chirpyv1.GetChirpRequest{Id: "heisenberg"})
+	if reason := expectCode(t, err, codes.InvalidArgument); reason != "validation_failed" {
+		t.Errorf("got reason %q for an invalid ID", reason)
+	}
+	_, err = clients.chirps.DeleteChirp(ctx, &chirpyv1.DeleteChirpRequest{Id: created.Chirp.Id})
+	if err != nil {
+		t.Fatalf("DeleteChirp: %v", err)
+	}
+	_, err = clients.chirps.GetChirp(t.Context(), &chirpyv1.GetChirpRequest{Id: created.Chirp.Id})
+	expectCode(t, err, codes.NotFound)
+}
+
+func TestGRPCWatchChirps(t *testing.T) {
+	ts := newTestServer(t)
+	clients := ts.grpc()
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+	ctx, cancel := context.WithTimeout(withToken(t.Context(), jesse.Token), 5*time.Second)
+	defer cancel()
+
+	// Replay what comes after this, so there's no race with subscribing
+	ts.chirp(walt.Token, "Yo")
+	lastEventID, err := ts.store.GetLatestEventID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirp := ts.chirp(walt.Token, "Jesse, we need to cook")
+	expect[any](t, ts.do("DELETE", "/api/chirps/"+chirp.ID.String(), walt.Token, nil), http.StatusNoContent)
+
+	stream, err := clients.chirps.WatchChirps(ctx, &chirpyv1.WatchChirpsRequest{LastEventId: lastEventID})
+	if err != nil {
+		t.Fatalf("WatchChirps: %v", err)
+	}
+	created, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if created.GetCreated().GetId() != chirp.ID.String() || created.EventId <= lastEventID {
+		t.Errorf("got %v, want chirp %s created", created, chirp.ID)
+	}
+	deleted, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if deleted.GetDeletedId() != chirp.ID.String() {
+		t.Errorf("got %v, want chirp %s deleted", deleted, chirp.ID)
+	}
+
+	cancel()
+	_, err = stream.Recv()
+	if status.Code(err) != codes.Canceled && !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v after cancelling", err)
+	}
+
+	unauthenticated, err := clients.chirps.WatchChirps(t.Context(), &chirpyv1.WatchChirpsRequest{})
+	if err == nil {
+		_, err = unauthenticated.Recv()
+	}
+	expectCode(t, err, codes.Unauthenticated)
+}
@@ -1,10 +1,25 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"net/http"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
 )
 
+// readyTimeout bounds each readiness check, so a hung database fails the
+// probe rather than stalling it.
+const readyTimeout = 2 * time.Second
+
+// readyCheck is one thing the server needs before it can take traffic.
+type readyCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// health reports that the process is up and serving. It never looks at
+// dependencies: restarting the server won't fix a database outage.
 func health(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 
@@ -12,6 +27,38 @@ func health(w http.ResponseWriter, req *http.Request) {
 
 	_, err := w.Write([]byte("OK"))
 	if err != nil {
-		fmt.Printf("Error writing response: %v\n", err)
+		logging.FromContext(req.Context()).Error("Error writing response", "err", err)
+	}
+}
+
+// ready reports whether this instance should get traffic: every check
+// passes and it isn't shutting down. The body names each check's result;
+// why one failed is only logged, since probes are often reachable from
+// outside.
+func (cfg *apiConfig) ready(w http.ResponseWriter, req *http.Request) {
+	type response struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+
+	resp := response{Status: "ok", Checks: map[string]string{}}
+	code := http.StatusOK
+	if cfg.shuttingDown.Load() {
+		resp.Status = "shutting down"
+		code = http.StatusServiceUnavailable
+	}
+	for _, c := range cfg.readyChecks {
+		ctx, cancel := context.WithTimeout(req.Context(), readyTimeout)
+		err := c.check(ctx)
+		cancel()
+		if err != nil {
+			logging.FromContext(req.Context()).Warn("Readiness check failed", "check", c.name, "err", err)
+			resp.Checks[c.name] = "failed"
+			resp.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[c.name] = "ok"
 	}
+	respondWithJSON(w, code, resp)
 }
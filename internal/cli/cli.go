@@ -0,0 +1,222 @@
+// Package cli implements chirpy-cli, a command line client for Chirpy built
+// on chirpyclient.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/chirpyclient"
+	"github.com/google/uuid"
+)
+
+// ServerEnv names the server when -server isn't given.
+const ServerEnv = "CHIRPY_SERVER"
+
+const defaultServer = "http://localhost:8080"
+
+const usage = `usage: chirpy-cli [-server URL] <command> [flags]
+
+Commands:
+  login     -email EMAIL                     password is read from stdin
+  logout
+  post      [-draft] [-at TIME] [TEXT]       TEXT defaults to stdin; TIME is RFC 3339
+  timeline  [-n N] [-author USER_ID] [-f]    -f keeps printing new chirps
+  delete    CHIRP_ID...
+  profile   [-email EMAIL] [-password]       -password reads the new one from stdin
+
+The server defaults to $` + ServerEnv + `, then the one logged in to, then
+` + defaultServer + `. Every command also takes -o table|json.`
+
+// CLI runs chirpy-cli commands. The login is saved in ConfigDir, so it
+// carries over between runs.
+type CLI struct {
+	// Server is used when -server isn't given, usually from $CHIRPY_SERVER
+	Server string
+	// ConfigDir holds the saved login; empty means chirpy in the user's
+	// config directory
+	ConfigDir  string
+	HTTPClient *http.Client
+
+	In     io.Reader
+	Out    io.Writer
+	ErrOut io.Writer
+}
+
+// session is one command's view of the server.
+type session struct {
+	*CLI
+	client *chirpyclient.Client
+	server string
+	// creds is nil when the user isn't logged in to server
+	creds  *credentials
+	output string
+}
+
+// Run runs the command line args, which exclude the program name.
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	global := flag.NewFlagSet("chirpy-cli", flag.ContinueOnError)
+	global.SetOutput(c.ErrOut)
+	global.Usage = func() { fmt.Fprintln(c.ErrOut, usage) }
+	server := global.String("server", "", "URL of the Chirpy server")
+	err := global.Parse(args)
+	if err != nil {
+		return err
+	}
+	args = global.Args()
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	command := args[0]
+
+	flags := flag.NewFlagSet("chirpy-cli "+command, flag.ContinueOnError)
+	flags.SetOutput(c.ErrOut)
+	output := flags.String("o", "table", "output format: table or json")
+	email := flags.String("email", "", "email address")
+	password := flags.Bool("password", false, "read a new password from stdin")
+	draft := flags.Bool("draft", false, "save the chirp without publishing it")
+	at := flags.String("at", "", "publish the chirp at this RFC 3339 time")
+	limit := flags.Int("n", 20, "number of chirps to show")
+	author := flags.String("author", "", "only show chirps by this user ID")
+	follow := flags.Bool("f", false, "keep printing new chirps as they're posted")
+	err = flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+
+	s, err := c.newSession(*server, *output)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "login":
+		err = s.login(ctx, *email)
+	case "logout":
+		err = s.logout(ctx)
+	case "post":
+		err = s.post(ctx, flags.Args(), *draft, *at)
+	case "timeline":
+		err = s.timeline(ctx, *limit, *author, *follow)
+	case "delete":
+		err = s.delete(ctx, flags.Args())
+	case "profile":
+		err = s.profile(ctx, *email, *password)
+	default:
+		err = fmt.Errorf("unknown command %q\n\n%s", command, usage)
+	}
+	// Keep the access token if it was refreshed, even when the command
+	// failed afterwards
+	if saveErr := s.saveTokens(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+func (c *CLI) newSession(server, output string) (*session, error) {
+	creds, err := c.loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if server == "" {
+		server = c.Server
+	}
+	if server == "" && creds != nil {
+		server = creds.Server
+	}
+	if server == "" {
+		server = defaultServer
+	}
+	server = strings.TrimSuffix(server, "/")
+
+	opts := []chirpyclient.Option{}
+	if c.HTTPClient != nil {
+		opts = append(opts, chirpyclient.WithHTTPClient(c.HTTPClient))
+	}
+	if creds != nil && creds.Server != server {
+		creds = nil
+	}
+	if creds != nil {
+		opts = append(opts, chirpyclient.WithTokens(creds.AccessToken, creds.RefreshToken))
+	}
+	return &session{
+		CLI:    c,
+		client: chirpyclient.New(server, opts...),
+		server: server,
+		creds:  creds,
+		output: output,
+	}, nil
+}
+
+// requireLogin fails commands that only make sense for a user.
+func (s *session) requireLogin() error {
+	if s.creds == nil {
+		return fmt.Errorf("not logged in to %s; run chirpy-cli login", s.server)
+	}
+	return nil
+}
+
+func (s *session) saveTokens() error {
+	if s.creds == nil {
+		return nil
+	}
+	access, refresh := s.client.Tokens()
+	if access == s.creds.AccessToken && refresh == s.creds.RefreshToken {
+		return nil
+	}
+	s.creds.AccessToken = access
+	s.creds.RefreshToken = refresh
+	return s.saveCredentials(s.creds)
+}
+
+func (s *session) print(result any) error {
+	if s.output == "json" {
+		enc := json.NewEncoder(s.Out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	tw := tabwriter.NewWriter(s.Out, 0, 0, 2, ' ', 0)
+	switch v := result.(type) {
+	case *chirpyclient.User:
+		fmt.Fprintln(tw, "ID\tEMAIL\tRED\tCREATED AT")
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%s\n", v.ID, v.Email, v.IsChirpyRed, v.CreatedAt.Local().Format(time.DateTime))
+	case *chirpyclient.Chirp:
+		fmt.Fprintln(tw, chirpHeader)
+		printChirp(tw, *v)
+	case []chirpyclient.Chirp:
+		fmt.Fprintln(tw, chirpHeader)
+		for _, chirp := range v {
+			printChirp(tw, chirp)
+		}
+	case deleted:
+		for _, id := range v.IDs {
+			fmt.Fprintf(tw, "Deleted %s\n", id)
+		}
+	}
+	return tw.Flush()
+}
+
+const chirpHeader = "ID\tAUTHOR\tCREATED AT\tSTATUS\tBODY"
+
+// printChirp writes a table row. Every column but the body has a fixed
+// width, so rows printed separately still line up.
+func printChirp(w io.Writer, chirp chirpyclient.Chirp) {
+	body := strings.ReplaceAll(chirp.Body, "\n", " ")
+	fmt.Fprintf(w, "%s\t%s\t%s\t%-9s\t%s\n", chirp.ID, chirp.UserID, chirp.CreatedAt.Local().Format(time.DateTime), chirp.Status, body)
+}
+
+type deleted struct {
+	IDs []uuid.UUID `json:"deleted"`
+}
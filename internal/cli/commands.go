@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/chirpyclient"
+	"github.com/google/uuid"
+)
+
+func (s *session) login(ctx context.Context, email string) error {
+	if email == "" {
+		return errors.New("-email is required")
+	}
+	fmt.Fprint(s.ErrOut, "Password: ")
+	password, err := s.readLine()
+	if err != nil {
+		return err
+	}
+
+	user, err := s.client.Login(ctx, email, password)
+	if err != nil {
+		return err
+	}
+	s.creds = &credentials{
+		Server:       s.server,
+		AccessToken:  user.Token,
+		RefreshToken: user.RefreshToken,
+		User:         withoutTokens(*user),
+	}
+	err = s.saveCredentials(s.creds)
+	if err != nil {
+		return err
+	}
+	return s.print(&s.creds.User)
+}
+
+// logout forgets the login even if the server can't be told, since the
+// refresh token may already be revoked.
+func (s *session) logout(ctx context.Context) error {
+	err := s.requireLogin()
+	if err != nil {
+		return err
+	}
+	err = s.client.Logout(ctx)
+	if err != nil && !errors.Is(err, chirpyclient.ErrUnauthorized) {
+		return err
+	}
+	s.creds = nil
+	return s.deleteCredentials()
+}
+
+// post chirps the arguments, or stdin if there are none or just "-".
+func (s *session) post(ctx context.Context, args []string, draft bool, at string) error {
+	err := s.requireLogin()
+	if err != nil {
+		return err
+	}
+	params := chirpyclient.ChirpParams{Body: strings.Join(args, " "), Draft: draft}
+	if len(args) == 0 || slices.Equal(args, []string{"-"}) {
+		dat, err := io.ReadAll(s.In)
+		if err != nil {
+			return err
+		}
+		params.Body = strings.TrimRight(string(dat), "\r\n")
+	}
+	if at != "" {
+		publishAt, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return fmt.Errorf("-at must be an RFC 3339 time, like 2026-01-02T15:04:05Z: %w", err)
+		}
+		params.PublishAt = &publishAt
+	}
+
+	chirp, err := s.client.CreateChirp(ctx, params)
+	if err != nil {
+		return err
+	}
+	return s.print(chirp)
+}
+
+// timeline prints the latest chirps, oldest first like tail, and with
+// follow keeps printing new ones until ctx is done.
+func (s *session) timeline(ctx context.Context, limit int, author string, follow bool) error {
+	if limit < 0 {
+		return errors.New("-n can't be negative")
+	}
+	var authorID uuid.UUID
+	if author != "" {
+		var err error
+		authorID, err = uuid.Parse(author)
+		if err != nil {
+			return fmt.Errorf("-author must be a user ID: %w", err)
+		}
+	}
+
+	chirps, err := s.client.ListChirps(ctx, chirpyclient.ListChirpsOptions{AuthorID: authorID, Descending: true})
+	if err != nil {
+		return err
+	}
+	chirps = chirps[:min(limit, len(chirps))]
+	slices.Reverse(chirps)
+	if chirps == nil {
+		chirps = []chirpyclient.Chirp{}
+	}
+	if !follow {
+		return s.print(chirps)
+	}
+
+	// Following prints chirps one at a time: as JSON lines, or as table
+	// rows that line up with the ones before
+	if s.output == "json" {
+		for _, chirp := range chirps {
+			err = json.NewEncoder(s.Out).Encode(chirp)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		err = s.print(chirps)
+		if err != nil {
+			return err
+		}
+	}
+	err = s.requireLogin()
+	if err != nil {
+		return err
+	}
+	for ev, err := range s.client.Events(ctx, 0) {
+		if err != nil {
+			return err
+		}
+		if ev.Type != "chirp.created" {
+			continue
+		}
+		var chirp chirpyclient.Chirp
+		err = json.Unmarshal(ev.Data, &chirp)
+		if err != nil {
+			return fmt.Errorf("couldn't decode event %d: %w", ev.ID, err)
+		}
+		if authorID != uuid.Nil && chirp.UserID != authorID {
+			continue
+		}
+		if s.output == "json" {
+			err = json.NewEncoder(s.Out).Encode(chirp)
+		} else {
+			tw := tabwriter.NewWriter(s.Out, 0, 0, 2, ' ', 0)
+			printChirp(tw, chirp)
+			err = tw.Flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func (s *session) delete(ctx context.Context, args []string) error {
+	err := s.requireLogin()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("give the IDs of the chirps to delete")
+	}
+	var ids []uuid.UUID
+	for _, arg := range args {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return fmt.Errorf("%q isn't a chirp ID", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	result := deleted{IDs: []uuid.UUID{}}
+	for _, id := range ids {
+		err = s.client.DeleteChirp(ctx, id)
+		if err != nil {
+			// Still report the ones that went
+			s.print(result)
+			return fmt.Errorf("couldn't delete %s: %w", id, err)
+		}
+		result.IDs = append(result.IDs, id)
+	}
+	return s.print(result)
+}
+
+// profile shows the logged in user, after changing their email or
+// password if asked to.
+func (s *session) profile(ctx context.Context, email string, password bool) error {
+	err := s.requireLogin()
+	if err != nil {
+		return err
+	}
+	if email == "" && !password {
+		return s.print(&s.creds.User)
+	}
+
+	update := chirpyclient.UserUpdate{Email: email}
+	if password {
+		fmt.Fprint(s.ErrOut, "New password: ")
+		update.Password, err = s.readLine()
+		if err != nil {
+			return err
+		}
+	}
+	user, err := s.client.UpdateUser(ctx, update)
+	if err != nil {
+		return err
+	}
+	s.creds.User = withoutTokens(*user)
+	err = s.saveCredentials(s.creds)
+	if err != nil {
+		return err
+	}
+	return s.print(&s.creds.User)
+}
+
+// readLine reads a single line from stdin, so passwords stay out of the
+// shell history and the process list.
+func (s *session) readLine() (string, error) {
+	line, err := bufio.NewReader(s.In).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("nothing on stdin")
+	}
+	return line, nil
+}
+
+// withoutTokens drops the tokens from a user, which are saved separately
+// and would only go stale in the copy.
+func withoutTokens(user chirpyclient.User) chirpyclient.User {
+	user.Token = ""
+	user.RefreshToken = ""
+	return user
+}
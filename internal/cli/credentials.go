@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/chirpyclient"
+)
+
+// credentialsFile is the name of the saved login in the config directory.
+const credentialsFile = "credentials.json"
+
+// credentials is the saved login. The tokens only work against the server
+// that issued them.
+type credentials struct {
+	Server       string            `json:"server"`
+	AccessToken  string            `json:"access_token"`
+	RefreshToken string            `json:"refresh_token"`
+	User         chirpyclient.User `json:"user"`
+}
+
+func (c *CLI) credentialsPath() (string, error) {
+	dir := c.ConfigDir
+	if dir == "" {
+		base, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(base, "chirpy")
+	}
+	return filepath.Join(dir, credentialsFile), nil
+}
+
+// loadCredentials returns the saved login, or nil if there isn't one.
+func (c *CLI) loadCredentials() (*credentials, error) {
+	path, err := c.credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	dat, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var creds credentials
+	err = json.Unmarshal(dat, &creds)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse %s: %w", path, err)
+	}
+	return &creds, nil
+}
+
+// saveCredentials writes the login where only the current user can read
+// it. The file is replaced in one step, so a crash never leaves half of it.
+func (c *CLI) saveCredentials(creds *credentials) error {
+	path, err := c.credentialsPath()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+	dat, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), credentialsFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	// CreateTemp already uses 0600, but be explicit about what matters
+	err = tmp.Chmod(0o600)
+	if err == nil {
+		_, err = tmp.Write(dat)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *CLI) deleteCredentials() error {
+	path, err := c.credentialsPath()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,282 @@
+// Package config loads Chirpy's settings from, in increasing order of
+// precedence: built-in defaults, a YAML file, environment variables and
+// command line flags.
+//
+// Every setting has one name used by all three sources: "polka_key" in the
+// file is POLKA_KEY in the environment and -polka-key on the command line.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the config file when -config isn't given.
+const FileEnv = "CHIRPY_CONFIG"
+
+// Where rate limits are kept.
+const (
+	// RateLimitMemory limits each instance separately
+	RateLimitMemory = "memory"
+	// RateLimitDatabase shares limits between every instance
+	RateLimitDatabase = "database"
+	RateLimitOff      = "off"
+)
+
+type Config struct {
+	// DBURL is a postgres:// or sqlite:// URL
+	DBURL string
+	// Platform is "dev" to enable development-only endpoints
+	Platform string
+	// Secret signs access tokens
+	Secret string
+	// PolkaKey authenticates Polka's webhooks
+	PolkaKey string
+
+	Port int
+	// GRPCPort is where the gRPC API listens, apart from the HTTP API
+	GRPCPort int
+	// FileRoot is the directory the web app is served from
+	FileRoot string
+	LogLevel slog.Level
+	// MigrateOnStart applies pending migrations before serving, instead of
+	// refusing to start
+	MigrateOnStart bool
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout is how long requests and jobs in flight get to finish
+	// once the server is told to stop
+	ShutdownTimeout time.Duration
+
+	// RateLimit is where rate limit buckets are kept: RateLimitMemory,
+	// RateLimitDatabase or RateLimitOff
+	RateLimit string
+	// TrustForwardedFor takes the client's IP from the X-Forwarded-For
+	// header, for running behind a proxy or load balancer
+	TrustForwardedFor bool
+
+	// ChirpyRedPeriod is how long each user.upgraded webhook from Polka
+	// keeps a user on Chirpy Red. Zero, the default, keeps them on Red
+	// until it's turned off by hand
+	ChirpyRedPeriod time.Duration
+
+	// Args are what's left of the command line after the flags: a command
+	// such as "migrate up", or nothing to serve
+	Args []string
+}
+
+// When a setting must be given.
+type requirement int
+
+const (
+	optional requirement = iota
+	// needed to serve, but not by commands such as migrate
+	forServer
+	// needed by everything
+	always
+)
+
+// setting binds one field of a Config to its name in every source.
+type setting struct {
+	// name is the file key; the environment variable and flag are derived
+	// from it
+	name     string
+	usage    string
+	value    flag.Value
+	required requirement
+	// redact hides the secret parts of the value when it's dumped
+	redact func(string) string
+}
+
+func (s setting) env() string  { return strings.ToUpper(s.name) }
+func (s setting) flag() string { return strings.ReplaceAll(s.name, "_", "-") }
+
+func defaults() *Config {
+	return &Config{
+		Port:              8080,
+		GRPCPort:          9090,
+		FileRoot:          ".",
+		LogLevel:          slog.LevelInfo,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   30 * time.Second,
+		RateLimit:         RateLimitMemory,
+	}
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{name: "db_url", usage: "database URL, postgres:// or sqlite://", value: stringValue{&c.DBURL}, required: always, redact: redactURL},
+		{name: "platform", usage: `"dev" enables development-only endpoints`, value: stringValue{&c.Platform}, required: forServer},
+		{name: "secret", usage: "key that signs access tokens", value: stringValue{&c.Secret}, required: forServer, redact: redactAll},
+		{name: "polka_key", usage: "API key Polka's webhooks authenticate with", value: stringValue{&c.PolkaKey}, required: forServer, redact: redactAll},
+		{name: "port", usage: "port to listen on", value: portValue{&c.Port}},
+		{name: "grpc_port", usage: "port the gRPC API listens on", value: portValue{&c.GRPCPort}},
+		{name: "file_root", usage: "directory the web app is served from", value: stringValue{&c.FileRoot}},
+		{name: "log_level", usage: "debug, info, warn or error; debug logs every query", value: levelValue{&c.LogLevel}},
+		{name: "migrate_on_start", usage: "apply pending migrations before serving", value: boolValue{&c.MigrateOnStart}},
+		{name: "http_read_header_timeout", usage: "time allowed to read request headers", value: durationValue{&c.ReadHeaderTimeout}},
+		{name: "http_read_timeout", usage: "time allowed to read a whole request", value: durationValue{&c.ReadTimeout}},
+		{name: "http_write_timeout", usage: "time allowed to write a response", value: durationValue{&c.WriteTimeout}},
+		{name: "http_idle_timeout", usage: "time a keep-alive connection waits for its next request", value: durationValue{&c.IdleTimeout}},
+		{name: "shutdown_timeout", usage: "time requests and jobs in flight get to finish on shutdown", value: durationValue{&c.ShutdownTimeout}},
+		{name: "rate_limit", usage: "where rate limits are kept: memory, database to share them between instances, or off", value: choiceValue{&c.RateLimit, []string{RateLimitMemory, RateLimitDatabase, RateLimitOff}}},
+		{name: "trust_forwarded_for", usage: "take client IPs from X-Forwarded-For, when behind a proxy", value: boolValue{&c.TrustForwardedFor}},
+		{name: "chirpy_red_period", usage: "how long each Polka upgrade keeps a user on Chirpy Red; unset never lapses", value: durationValue{&c.ChirpyRedPeriod}},
+	}
+}
+
+// Load reads the configuration for a command line. args excludes the
+// program name, and getenv is usually os.Getenv; environment variables set
+// to "" count as unset.
+//
+// Every problem is reported at once, joined into one error: unreadable
+// values from any source and every required setting that's missing. Flag
+// parsing errors, including flag.ErrHelp for -h, are returned straight away.
+func Load(args []string, getenv func(string) string, usage io.Writer) (*Config, error) {
+	c := defaults()
+	settings := c.settings()
+
+	// Flags are parsed first, to find the file, but applied last
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	fs.SetOutput(usage)
+	file := fs.String("config", "", "YAML config file; defaults to $"+FileEnv)
+	flags := map[string]string{}
+	for _, s := range settings {
+		record := func(v string) error {
+			flags[s.name] = v
+			return nil
+		}
+		if _, ok := s.value.(boolValue); ok {
+			fs.BoolFunc(s.flag(), s.usage, record)
+		} else {
+			fs.Func(s.flag(), s.usage, record)
+		}
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	c.Args = fs.Args()
+
+	var errs []error
+	path := *file
+	if path == "" {
+		path = getenv(FileEnv)
+	}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, s := range settings {
+			v, ok := values[s.name]
+			if !ok {
+				continue
+			}
+			delete(values, s.name)
+			if err := s.value.Set(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s in %s: %w", s.name, path, err))
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(values)) {
+			errs = append(errs, fmt.Errorf("unknown setting %s in %s", name, path))
+		}
+	}
+
+	for _, s := range settings {
+		v := getenv(s.env())
+		if v == "" {
+			continue
+		}
+		if err := s.value.Set(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env(), err))
+		}
+	}
+
+	for _, s := range settings {
+		v, ok := flags[s.name]
+		if !ok {
+			continue
+		}
+		if err := s.value.Set(v); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", s.flag(), err))
+		}
+	}
+
+	var missing []string
+	for _, s := range settings {
+		needed := s.required == always || (s.required == forServer && len(c.Args) == 0)
+		if needed && s.value.String() == "" {
+			missing = append(missing, s.env())
+		}
+	}
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("missing required settings: %s", strings.Join(missing, ", ")))
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return c, nil
+}
+
+// readFile reads a flat YAML mapping of setting names to values.
+func readFile(path string) (map[string]string, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]string
+	err = yaml.Unmarshal(dat, &values)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func (s setting) dump() string {
+	v := s.value.String()
+	if s.redact != nil && v != "" {
+		return s.redact(v)
+	}
+	return v
+}
+
+// LogValue logs the configuration with secrets redacted.
+func (c *Config) LogValue() slog.Value {
+	var attrs []slog.Attr
+	for _, s := range c.settings() {
+		attrs = append(attrs, slog.String(s.name, s.dump()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func redactAll(string) string {
+	return "REDACTED"
+}
+
+// redactURL keeps a URL's host and database but hides its password.
+func redactURL(v string) string {
+	u, err := url.Parse(v)
+	if err != nil {
+		return "REDACTED"
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Each value is a flag.Value that writes through to a Config field and
+// checks what it's given, so bad input is reported against the source it
+// came from.
+
+type stringValue struct{ p *string }
+
+func (v stringValue) String() string { return *v.p }
+
+func (v stringValue) Set(s string) error {
+	*v.p = s
+	return nil
+}
+
+type boolValue struct{ p *bool }
+
+func (v boolValue) String() string { return strconv.FormatBool(*v.p) }
+
+func (v boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return errors.New("must be true or false")
+	}
+	*v.p = b
+	return nil
+}
+
+type portValue struct{ p *int }
+
+func (v portValue) String() string { return strconv.Itoa(*v.p) }
+
+func (v portValue) Set(s string) error {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return errors.New("must be a port number from 1 to 65535")
+	}
+	*v.p = port
+	return nil
+}
+
+type durationValue struct{ p *time.Duration }
+
+func (v durationValue) String() string { return v.p.String() }
+
+func (v durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return errors.New("must be a positive duration such as 30s")
+	}
+	*v.p = d
+	return nil
+}
+
+type levelValue struct{ p *slog.Level }
+
+func (v levelValue) String() string { return strings.ToLower(v.p.String()) }
+
+func (v levelValue) Set(s string) error {
+	err := v.p.UnmarshalText([]byte(s))
+	if err != nil {
+		return errors.New("must be debug, info, warn or error")
+	}
+	return nil
+}
+
+type choiceValue struct {
+	p       *string
+	choices []string
+}
+
+func (v choiceValue) String() string { return *v.p }
+
+func (v choiceValue) Set(s string) error {
+	if !slices.Contains(v.choices, s) {
+		return fmt.Errorf("must be one of %s", strings.Join(v.choices, ", "))
+	}
+	*v.p = s
+	return nil
+}
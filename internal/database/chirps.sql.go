@@ -7,25 +7,34 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status, publish_at)
 VALUES (
-    gen_random_uuid(), NOW(), NOW(), $1, $2
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	Status    string
+	PublishAt sql.NullTime
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.Status,
+		arg.PublishAt,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -33,6 +42,8 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
 	)
 	return i, err
 }
@@ -47,15 +58,61 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteUserChirps = `-- name: DeleteUserChirps :many
+DELETE FROM chirps
+WHERE user_id = $1
+RETURNING id
+`
+
+func (q *Queries) DeleteUserChirps(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, deleteUserChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAuthorChirps = `-- name: GetAuthorChirps :many
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, status, publish_at
 FROM chirps
 WHERE user_id = $1
+AND status = 'published'
+AND NOT EXISTS (
+    SELECT 1
+    FROM blocks
+    WHERE (blocks.blocker_id = $2 AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2)
+)
+AND NOT EXISTS (
+    SELECT 1
+    FROM mutes
+    WHERE mutes.muter_id = $2 AND mutes.muted_id = chirps.user_id
+)
 ORDER BY created_at
 `
 
-func (q *Queries) GetAuthorChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAuthorChirps, userID)
+type GetAuthorChirpsParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetAuthorChirps(ctx context.Context, arg GetAuthorChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAuthorChirps, arg.UserID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +126,84 @@ func (q *Queries) GetAuthorChirps(ctx context.Context, userID uuid.UUID) ([]Chir
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAuthorsChirps = `-- name: GetAuthorsChirps :many
+SELECT id, created_at, updated_at, body, user_id, status, publish_at
+FROM chirps
+WHERE id IN (
+    SELECT ranked.id
+    FROM (
+        SELECT visible.id,
+            CASE WHEN $1::boolean
+                THEN ROW_NUMBER() OVER (PARTITION BY visible.user_id ORDER BY visible.created_at DESC, visible.id DESC)
+                ELSE ROW_NUMBER() OVER (PARTITION BY visible.user_id ORDER BY visible.created_at, visible.id)
+            END <= $2::int AS on_page
+        FROM chirps visible
+        WHERE visible.status = 'published'
+        AND NOT EXISTS (
+            SELECT 1
+            FROM blocks
+            WHERE (blocks.blocker_id = $3 AND blocks.blocked_id = visible.user_id)
+               OR (blocks.blocker_id = visible.user_id AND blocks.blocked_id = $3)
+        )
+        AND NOT EXISTS (
+            SELECT 1
+            FROM mutes
+            WHERE mutes.muter_id = $3 AND mutes.muted_id = visible.user_id
+        )
+        AND visible.user_id = ANY($4::uuid[])
+    ) ranked
+    WHERE ranked.on_page
+)
+ORDER BY created_at, id
+`
+
+type GetAuthorsChirpsParams struct {
+	NewestFirst bool
+	PerAuthor   int32
+	ViewerID    uuid.UUID
+	UserIds     []uuid.UUID
+}
+
+// GetAuthorChirps for several authors at once, so GraphQL can batch them.
+// Each author gets at most per_author chirps, the oldest or the newest.
+func (q *Queries) GetAuthorsChirps(ctx context.Context, arg GetAuthorsChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAuthorsChirps,
+		arg.NewestFirst,
+		arg.PerAuthor,
+		arg.ViewerID,
+		pq.Array(arg.UserIds),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
 		); err != nil {
 			return nil, err
 		}
@@ -84,7 +219,7 @@ func (q *Queries) GetAuthorChirps(ctx context.Context, userID uuid.UUID) ([]Chir
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, status, publish_at
 FROM chirps
 WHERE id = $1
 `
@@ -98,18 +233,71 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, status, publish_at
+FROM chirps
+WHERE status = 'published'
+AND NOT EXISTS (
+    SELECT 1
+    FROM blocks
+    WHERE (blocks.blocker_id = $1 AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1)
+)
+AND NOT EXISTS (
+    SELECT 1
+    FROM mutes
+    WHERE mutes.muter_id = $1 AND mutes.muted_id = chirps.user_id
+)
+ORDER BY created_at
+`
+
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDrafts = `-- name: GetDrafts :many
+SELECT id, created_at, updated_at, body, user_id, status, publish_at
 FROM chirps
+WHERE user_id = $1
+AND status <> 'published'
 ORDER BY created_at
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetDrafts(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getDrafts, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +311,8 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
 		); err != nil {
 			return nil, err
 		}
@@ -136,3 +326,104 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const getVisibleChirp = `-- name: GetVisibleChirp :one
+SELECT id, created_at, updated_at, body, user_id, status, publish_at
+FROM chirps
+WHERE id = $1
+AND status = 'published'
+AND NOT EXISTS (
+    SELECT 1
+    FROM blocks
+    WHERE (blocks.blocker_id = $2 AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2)
+)
+`
+
+type GetVisibleChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetVisibleChirp(ctx context.Context, arg GetVisibleChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirp, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const publishDueChirp = `-- name: PublishDueChirp :one
+UPDATE chirps
+SET status = 'published',
+    created_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+AND status = 'scheduled'
+AND publish_at <= NOW()
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at
+`
+
+// Only one caller gets the row back, however many race to publish it, and a
+// chirp that was edited, rescheduled or deleted since is left alone.
+func (q *Queries) PublishDueChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, publishDueChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const updateDraft = `-- name: UpdateDraft :one
+UPDATE chirps
+SET body = $1,
+    status = $2,
+    publish_at = $3,
+    created_at = CASE WHEN $2 = 'published' THEN NOW() ELSE created_at END,
+    updated_at = NOW()
+WHERE id = $4
+AND status <> 'published'
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at
+`
+
+type UpdateDraftParams struct {
+	Body      string
+	Status    string
+	PublishAt sql.NullTime
+	ID        uuid.UUID
+}
+
+// A draft enters the timeline when it's published, not when it was written.
+func (q *Queries) UpdateDraft(ctx context.Context, arg UpdateDraftParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateDraft,
+		arg.Body,
+		arg.Status,
+		arg.PublishAt,
+		arg.ID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+	)
+	return i, err
+}
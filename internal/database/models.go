@@ -6,17 +6,107 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	Status    string
+	PublishAt sql.NullTime
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+	ClearedAt      sql.NullTime
+}
+
+type Event struct {
+	ID        int64
+	CreatedAt time.Time
+	Type      string
+	UserID    uuid.NullUUID
+	ActorID   uuid.NullUUID
+	Payload   json.RawMessage
+}
+
+type Job struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Kind        string
+	Payload     json.RawMessage
+	State       string
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       time.Time
+	LockedUntil sql.NullTime
+	LastError   sql.NullString
+	UniqueKey   sql.NullString
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Type      string
+	SubjectID uuid.UUID
+	ReadAt    sql.NullTime
+}
+
+type NotificationActor struct {
+	NotificationID uuid.UUID
+	ActorID        uuid.UUID
+	CreatedAt      time.Time
+}
+
+type NotificationPreference struct {
+	UserID    uuid.UUID
+	Type      string
+	Enabled   bool
+	UpdatedAt time.Time
+}
+
+type RateLimit struct {
+	Key    string
+	FullAt int64
 }
 
 type RefreshToken struct {
@@ -29,10 +119,12 @@ type RefreshToken struct {
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	Email              string
+	HashedPassword     string
+	IsChirpyRed        bool
+	Role               string
+	ChirpyRedExpiresAt sql.NullTime
 }
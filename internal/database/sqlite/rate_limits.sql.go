@@ -0,0 +1,66 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: rate_limits.sql
+
+package sqlite
+
+import (
+	"context"
+)
+
+const deleteFullRateLimits = `-- name: DeleteFullRateLimits :execrows
+DELETE FROM rate_limits
+WHERE full_at <= ?1
+`
+
+func (q *Queries) DeleteFullRateLimits(ctx context.Context, fullAt int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFullRateLimits, fullAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getRateLimit = `-- name: GetRateLimit :one
+SELECT full_at FROM rate_limits
+WHERE key = ?1
+`
+
+func (q *Queries) GetRateLimit(ctx context.Context, key string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getRateLimit, key)
+	var full_at int64
+	err := row.Scan(&full_at)
+	return full_at, err
+}
+
+const takeRateLimit = `-- name: TakeRateLimit :one
+INSERT INTO rate_limits (key, full_at)
+SELECT ?1, MAX(COALESCE((SELECT full_at FROM rate_limits WHERE key = ?1), 0), CAST(?2 AS INTEGER)) + CAST(?3 AS INTEGER)
+WHERE MAX(COALESCE((SELECT full_at FROM rate_limits WHERE key = ?1), 0), CAST(?2 AS INTEGER)) + CAST(?3 AS INTEGER) <= CAST(?2 AS INTEGER) + CAST(?4 AS INTEGER)
+ON CONFLICT (key) DO UPDATE SET full_at = excluded.full_at
+RETURNING full_at
+`
+
+type TakeRateLimitParams struct {
+	Key      string
+	Now      int64
+	Cost     int64
+	Capacity int64
+}
+
+// Takes one token from the bucket for key, refilling it for the time since
+// it was last touched. No row comes back when the bucket is empty, and then
+// nothing changes. SQLite runs one write at a time, so unlike Postgres the
+// read and the write can be separate parts of the statement.
+func (q *Queries) TakeRateLimit(ctx context.Context, arg TakeRateLimitParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, takeRateLimit,
+		arg.Key,
+		arg.Now,
+		arg.Cost,
+		arg.Capacity,
+	)
+	var full_at int64
+	err := row.Scan(&full_at)
+	return full_at, err
+}
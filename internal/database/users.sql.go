@@ -7,8 +7,10 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -16,7 +18,7 @@ INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
     gen_random_uuid(), NOW(), NOW(), $1, $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, chirpy_red_expires_at
 `
 
 type CreateUserParams struct {
@@ -34,10 +36,27 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.ChirpyRedExpiresAt,
 	)
 	return i, err
 }
 
+const expireChirpyRed = `-- name: ExpireChirpyRed :execrows
+UPDATE users
+SET updated_at = NOW(), is_chirpy_red = FALSE, chirpy_red_expires_at = NULL
+WHERE is_chirpy_red
+  AND chirpy_red_expires_at <= NOW()
+`
+
+func (q *Queries) ExpireChirpyRed(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, expireChirpyRed)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getRefreshTokenFromUser = `-- name: GetRefreshTokenFromUser :one
 SELECT refresh_tokens.token, refresh_tokens.created_at, refresh_tokens.updated_at, refresh_tokens.user_id, refresh_tokens.expires_at, refresh_tokens.revoked_at
 FROM refresh_tokens
@@ -60,7 +79,7 @@ func (q *Queries) GetRefreshTokenFromUser(ctx context.Context, id uuid.UUID) (Re
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, chirpy_red_expires_at
 FROM users
 WHERE email = $1
 `
@@ -75,12 +94,14 @@ func (q *Queries) GetUser(ctx context.Context, email string) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.ChirpyRedExpiresAt,
 	)
 	return i, err
 }
 
 const getUserFromID = `-- name: GetUserFromID :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, chirpy_red_expires_at
 FROM users
 WHERE id = $1
 `
@@ -95,10 +116,50 @@ func (q *Queries) GetUserFromID(ctx context.Context, id uuid.UUID) (User, error)
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.ChirpyRedExpiresAt,
 	)
 	return i, err
 }
 
+const getUsersFromIDs = `-- name: GetUsersFromIDs :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, chirpy_red_expires_at
+FROM users
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetUsersFromIDs(ctx context.Context, ids []uuid.UUID) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersFromIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Role,
+			&i.ChirpyRedExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const resetUsers = `-- name: ResetUsers :exec
 DELETE FROM users
 `
@@ -108,11 +169,67 @@ func (q *Queries) ResetUsers(ctx context.Context) error {
 	return err
 }
 
+const setUserRed = `-- name: SetUserRed :one
+UPDATE users
+SET updated_at = NOW(), is_chirpy_red = $2, chirpy_red_expires_at = NULL
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, chirpy_red_expires_at
+`
+
+type SetUserRedParams struct {
+	ID          uuid.UUID
+	IsChirpyRed bool
+}
+
+func (q *Queries) SetUserRed(ctx context.Context, arg SetUserRedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRed, arg.ID, arg.IsChirpyRed)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.ChirpyRedExpiresAt,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET updated_at = NOW(), role = $2
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, chirpy_red_expires_at
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.ChirpyRedExpiresAt,
+	)
+	return i, err
+}
+
 const updateUserEmail = `-- name: UpdateUserEmail :one
 UPDATE users
 SET updated_at = NOW(), email = $2
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, chirpy_red_expires_at
 `
 
 type UpdateUserEmailParams struct {
@@ -130,6 +247,8 @@ func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.ChirpyRedExpiresAt,
 	)
 	return i, err
 }
@@ -138,7 +257,7 @@ const updateUserPassword = `-- name: UpdateUserPassword :one
 UPDATE users
 SET updated_at = NOW(), hashed_password = $2
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, chirpy_red_expires_at
 `
 
 type UpdateUserPasswordParams struct {
@@ -156,19 +275,26 @@ func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPassword
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.ChirpyRedExpiresAt,
 	)
 	return i, err
 }
 
 const updateUserRed = `-- name: UpdateUserRed :one
 UPDATE users
-SET updated_at = NOW(), is_chirpy_red = TRUE
+SET updated_at = NOW(), is_chirpy_red = TRUE, chirpy_red_expires_at = $2
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, chirpy_red_expires_at
 `
 
-func (q *Queries) UpdateUserRed(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUserRed, id)
+type UpdateUserRedParams struct {
+	ID                 uuid.UUID
+	ChirpyRedExpiresAt sql.NullTime
+}
+
+func (q *Queries) UpdateUserRed(ctx context.Context, arg UpdateUserRedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRed, arg.ID, arg.ChirpyRedExpiresAt)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -177,6 +303,8 @@ func (q *Queries) UpdateUserRed(ctx context.Context, id uuid.UUID) (User, error)
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.ChirpyRedExpiresAt,
 	)
 	return i, err
 }
@@ -0,0 +1,73 @@
+// Package dataloader batches lookups by key. Callers queue keys with Load
+// and get back a thunk; the first thunk called fetches every key queued so
+// far in one query. GraphQL resolvers return the thunks, and the executor
+// calls them only after resolving the whole level, so a list of chirps loads
+// all its authors at once instead of one query per chirp.
+package dataloader
+
+import (
+	"context"
+	"sync"
+)
+
+// Loader batches and caches lookups for the life of one request. It must
+// not outlive the request: cached values are never refreshed.
+type Loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending []K
+	results map[K]*result[V]
+}
+
+type result[V any] struct {
+	// done is set once the key's batch has run
+	done  bool
+	value V
+	ok    bool
+	err   error
+}
+
+// New returns a loader that looks keys up with fetch. Keys missing from the
+// map fetch returns weren't found.
+func New[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:   fetch,
+		results: map[K]*result[V]{},
+	}
+}
+
+// Load queues key for the next batch and returns a thunk for its value,
+// which reports false if the key wasn't found.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) func() (V, bool, error) {
+	l.mu.Lock()
+	r, ok := l.results[key]
+	if !ok {
+		r = &result[V]{}
+		l.results[key] = r
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, bool, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if !r.done {
+			l.dispatch(ctx)
+		}
+		return r.value, r.ok, r.err
+	}
+}
+
+// dispatch fetches every pending key. l.mu must be held.
+func (l *Loader[K, V]) dispatch(ctx context.Context) {
+	keys := l.pending
+	l.pending = nil
+	values, err := l.fetch(ctx, keys)
+	for _, key := range keys {
+		r := l.results[key]
+		r.value, r.ok = values[key]
+		r.err = err
+		r.done = true
+	}
+}
@@ -0,0 +1,65 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestLoader(t *testing.T) {
+	var batches [][]int
+	l := New(func(ctx context.Context, keys []int) (map[int]string, error) {
+		batches = append(batches, keys)
+		values := map[int]string{}
+		for _, k := range keys {
+			if k != 404 {
+				values[k] = "value"
+			}
+		}
+		return values, nil
+	})
+	ctx := context.Background()
+
+	thunks := []func() (string, bool, error){
+		l.Load(ctx, 1),
+		l.Load(ctx, 2),
+		l.Load(ctx, 1),
+		l.Load(ctx, 404),
+	}
+	for i, thunk := range thunks {
+		v, ok, err := thunk()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantOK := i != 3; ok != wantOK || (ok && v != "value") {
+			t.Errorf("thunk %d returned %q, %v", i, v, ok)
+		}
+	}
+
+	// Cached keys don't go into the next batch
+	v, _, _ := l.Load(ctx, 1)()
+	l.Load(ctx, 3)()
+	if v != "value" {
+		t.Errorf("cached key returned %q", v)
+	}
+	want := [][]int{{1, 2, 404}, {3}}
+	if !slices.EqualFunc(batches, want, slices.Equal) {
+		t.Errorf("got batches %v, want %v", batches, want)
+	}
+}
+
+func TestLoaderError(t *testing.T) {
+	failed := errors.New("database is down")
+	l := New(func(ctx context.Context, keys []string) (map[string]int, error) {
+		return nil, failed
+	})
+	a := l.Load(context.Background(), "a")
+	b := l.Load(context.Background(), "b")
+	for _, thunk := range []func() (int, bool, error){a, b} {
+		_, _, err := thunk()
+		if !errors.Is(err, failed) {
+			t.Errorf("got %v, want %v", err, failed)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres NOTIFY channel the events table trigger writes to.
+const Channel = "chirpy_events"
+
+const (
+	ChirpCreated   = "chirp.created"
+	ChirpDeleted   = "chirp.deleted"
+	MessageCreated = "message.created"
+
+	NotificationCreated = "notification.created"
+)
+
+// batchSize bounds how many rows are read from the events table at once.
+const batchSize = 100
+
+// Retention is how long events are kept for clients resuming a stream. A
+// client further behind than that resumes from the oldest event still kept,
+// and misses the ones before it.
+const Retention = 24 * time.Hour
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is dropped. Dropped clients reconnect and resume from their last event ID.
+const subscriberBuffer = 64
+
+// Hub fans events out to subscribers in this process. Events are written to
+// the events table, whose trigger NOTIFYs every server instance, so all
+// instances deliver the same events in the same order.
+type Hub struct {
+	db database.Querier
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	lastID int64
+
+	// published wakes Poll when this process writes an event
+	published chan struct{}
+}
+
+type Subscription struct {
+	Events <-chan database.Event
+
+	events chan database.Event
+	hub    *Hub
+	once   sync.Once
+}
+
+func NewHub(db database.Querier) *Hub {
+	return &Hub{
+		db:        db,
+		subs:      map[*Subscription]struct{}{},
+		published: make(chan struct{}, 1),
+	}
+}
+
+// Publish records an event. A uuid.Nil recipient makes the event public;
+// otherwise only that user receives it. The actor is the user who caused it.
+func (h *Hub) Publish(ctx context.Context, typ string, actor, recipient uuid.UUID, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = h.db.CreateEvent(ctx, database.CreateEventParams{
+		Type:    typ,
+		UserID:  uuid.NullUUID{UUID: recipient, Valid: recipient != uuid.Nil},
+		ActorID: uuid.NullUUID{UUID: actor, Valid: actor != uuid.Nil},
+		Payload: payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case h.published <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Since returns up to one batch of events after the given ID, for clients
+// resuming a stream.
+func (h *Hub) Since(ctx context.Context, id int64) ([]database.Event, error) {
+	return h.db.GetEventsAfter(ctx, database.GetEventsAfterParams{
+		ID:    id,
+		Limit: batchSize,
+	})
+}
+
+// Purge deletes the events older than Retention and reports how many it
+// deleted.
+func (h *Hub) Purge(ctx context.Context) (int64, error) {
+	return h.db.DeleteEventsBefore(ctx, time.Now().Add(-Retention).UTC())
+}
+
+func (h *Hub) Subscribe() *Subscription {
+	events := make(chan database.Event, subscriberBuffer)
+	sub := &Subscription{
+		Events: events,
+		events: events,
+		hub:    h,
+	}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.close()
+}
+
+// close must be called with the hub lock held.
+func (s *Subscription) close() {
+	s.once.Do(func() {
+		delete(s.hub.subs, s)
+		close(s.events)
+	})
+}
+
+// Run listens for notifications until ctx is cancelled. Notifications only
+// carry the event ID; the hub reads everything after the last event it has
+// seen, so notifications missed during a reconnect are caught up as well.
+//
+// Event IDs come from a sequence, so a transaction that commits after a
+// later-numbered one can be skipped. Events are published outside of long
+// transactions to keep that window small.
+func (h *Hub) Run(ctx context.Context, dbURL string) error {
+	latest, err := h.db.GetLatestEventID(ctx)
+	if err != nil {
+		return err
+	}
+	h.lastID = latest
+
+	listener := pq.NewListener(dbURL, 10*time.Second, time.Minute,
+		func(ev pq.ListenerEventType, err error) {
+			if err != nil {
+				slog.Error("Event listener failed", "err", err)
+			}
+		},
+	)
+	defer listener.Close()
+	err = listener.Listen(Channel)
+	if err != nil {
+		return err
+	}
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			h.closeAll()
+			return ctx.Err()
+		case <-listener.Notify:
+			// A nil notification means the connection was re-established
+			h.catchUp(ctx)
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
+
+// Poll is Run for databases without LISTEN/NOTIFY, such as SQLite. Events
+// published by this process are delivered straight away; events from other
+// processes sharing the database are picked up every interval.
+func (h *Hub) Poll(ctx context.Context, interval time.Duration) error {
+	latest, err := h.db.GetLatestEventID(ctx)
+	if err != nil {
+		return err
+	}
+	h.lastID = latest
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			h.closeAll()
+			return ctx.Err()
+		case <-h.published:
+			h.catchUp(ctx)
+		case <-ticker.C:
+			h.catchUp(ctx)
+		}
+	}
+}
+
+func (h *Hub) catchUp(ctx context.Context) {
+	for {
+		events, err := h.Since(ctx, h.lastID)
+		if err != nil {
+			slog.Error("Couldn't read events", "err", err)
+			return
+		}
+		for _, ev := range events {
+			h.broadcast(ev)
+			h.lastID = ev.ID
+		}
+		if len(events) < batchSize {
+			return
+		}
+	}
+}
+
+func (h *Hub) broadcast(ev database.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		select {
+		case sub.events <- ev:
+		default:
+			// Slow consumer; drop it rather than block everyone else
+			sub.close()
+		}
+	}
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		sub.close()
+	}
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store/memory"
+	"github.com/google/uuid"
+)
+
+func TestBroadcast(t *testing.T) {
+	hub := NewHub(nil)
+	fast := hub.Subscribe()
+	defer fast.Close()
+	slow := hub.Subscribe()
+	defer slow.Close()
+
+	// Fill the slow subscriber's buffer, then keep draining the fast one
+	for i := 1; i <= subscriberBuffer+1; i++ {
+		hub.broadcast(database.Event{ID: int64(i)})
+		ev := <-fast.Events
+		if ev.ID != int64(i) {
+			t.Fatalf("fast subscriber got event %d, want %d", ev.ID, i)
+		}
+	}
+
+	received := 0
+	for range slow.Events {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("slow subscriber got %d events before being dropped, want %d", received, subscriberBuffer)
+	}
+}
+
+func TestSubscriptionCloseTwice(t *testing.T) {
+	hub := NewHub(nil)
+	sub := hub.Subscribe()
+	sub.Close()
+	sub.Close()
+
+	hub.broadcast(database.Event{ID: 1})
+	if _, ok := <-sub.Events; ok {
+		t.Error("closed subscription received an event")
+	}
+}
+
+// cutoffStore records the cutoff Purge asks for.
+type cutoffStore struct {
+	*memory.Store
+	cutoff time.Time
+}
+
+func (s *cutoffStore) DeleteEventsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	s.cutoff = createdAt
+	return s.Store.DeleteEventsBefore(ctx, createdAt)
+}
+
+func TestPurge(t *testing.T) {
+	// Postgres would drop a local offset from the cutoff
+	local := time.Local
+	time.Local = time.FixedZone("EST", -5*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	ctx := context.Background()
+	db := &cutoffStore{Store: memory.New()}
+	hub := NewHub(db)
+	err := hub.Publish(ctx, ChirpCreated, uuid.Nil, uuid.Nil, map[string]string{"body": "Yo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	purged, err := hub.Purge(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 0 {
+		t.Errorf("purged %d recent events", purged)
+	}
+	if db.cutoff.Location() != time.UTC {
+		t.Errorf("cutoff %v isn't in UTC", db.cutoff)
+	}
+	if age := start.Sub(db.cutoff); age < Retention-time.Minute || age > Retention {
+		t.Errorf("got a cutoff %v ago, want %v", age, Retention)
+	}
+}
@@ -0,0 +1,99 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2026, time.January, 14, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		spec string
+		want time.Time
+	}{
+		{
+			name: "Every minute",
+			spec: "* * * * *",
+			want: time.Date(2026, time.January, 14, 10, 18, 0, 0, time.UTC),
+		},
+		{
+			name: "Step",
+			spec: "*/10 * * * *",
+			want: time.Date(2026, time.January, 14, 10, 20, 0, 0, time.UTC),
+		},
+		{
+			name: "Hourly rolls over the hour",
+			spec: "@hourly",
+			want: time.Date(2026, time.January, 14, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Daily rolls over the day",
+			spec: "30 3 * * *",
+			want: time.Date(2026, time.January, 15, 3, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "List and range",
+			spec: "0 9-17 * * 1,5",
+			want: time.Date(2026, time.January, 16, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Sunday as 7",
+			spec: "0 0 * * 7",
+			want: time.Date(2026, time.January, 18, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Either day field matches",
+			spec: "0 0 1 * 5",
+			want: time.Date(2026, time.January, 16, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Monthly rolls over the year",
+			spec: "0 0 1 1 *",
+			want: time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Leap day",
+			spec: "0 0 29 2 *",
+			want: time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Never",
+			spec: "0 0 31 2 *",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q) error = %v", tt.spec, err)
+			}
+			got := s.Next(from)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@yearly",
+	} {
+		_, err := ParseSchedule(spec)
+		if err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want error", spec)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Package jobs runs deferred and recurring work from the jobs table. Any
+// number of server instances can run a Runner against the same database:
+// Postgres hands each due job to exactly one worker with FOR UPDATE SKIP
+// LOCKED, and recurring jobs are enqueued under a unique key so every
+// instance agrees on a single run per occurrence.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
+)
+
+// DefaultMaxAttempts is how many times a job runs before it is moved to the
+// dead-letter state.
+const DefaultMaxAttempts = 5
+
+const (
+	// A job that fails is retried after 10s, 20s, 40s and so on, up to an
+	// hour, plus up to 10% jitter so a burst of failures doesn't retry in
+	// lockstep
+	baseBackoff = 10 * time.Second
+	maxBackoff  = time.Hour
+)
+
+// Handler does the work for one kind of job. A returned error, or a panic,
+// fails this attempt. Handlers must be idempotent: a job whose worker dies
+// halfway through runs again.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+type recurring struct {
+	kind     string
+	schedule *Schedule
+}
+
+// Runner claims due jobs and runs them with the registered handlers.
+type Runner struct {
+	db        database.Querier
+	handlers  map[string]Handler
+	recurring []recurring
+
+	// Workers is how many jobs this instance runs at once
+	Workers int
+	// PollInterval is how long an idle worker waits before looking again
+	PollInterval time.Duration
+	// Lease bounds how long a job may run. A job still running after that
+	// is cancelled, and if its worker is gone another one picks it up.
+	Lease time.Duration
+
+	now func() time.Time
+}
+
+func NewRunner(db database.Querier) *Runner {
+	return &Runner{
+		db:           db,
+		handlers:     map[string]Handler{},
+		Workers:      2,
+		PollInterval: time.Second,
+		Lease:        5 * time.Minute,
+		now:          time.Now,
+	}
+}
+
+// Handle registers the handler for jobs of the given kind.
+func (r *Runner) Handle(kind string, h Handler) {
+	r.handlers[kind] = h
+}
+
+// Cron registers a handler that runs on a cron schedule, such as "0 * * * *"
+// for the top of every hour.
+func (r *Runner) Cron(kind, spec string, h Handler) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	r.Handle(kind, h)
+	r.recurring = append(r.recurring, recurring{kind: kind, schedule: schedule})
+	return nil
+}
+
+// Enqueue adds a job that runs once, at or soon after runAt. Pass a
+// transaction's Querier to enqueue only if the transaction commits.
+func Enqueue(ctx context.Context, db database.Querier, kind string, payload any, runAt time.Time) error {
+	return enqueue(ctx, db, kind, payload, runAt, sql.NullString{})
+}
+
+// enqueue and runNext hand the database times in UTC: Postgres keeps run_at
+// and locked_until in TIMESTAMP columns, which drop any other offset and
+// would shift leases and retries by the host's.
+func enqueue(ctx context.Context, db database.Querier, kind string, payload any, runAt time.Time, uniqueKey sql.NullString) error {
+	dat, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return db.CreateJob(ctx, database.CreateJobParams{
+		Kind:        kind,
+		Payload:     dat,
+		MaxAttempts: DefaultMaxAttempts,
+		RunAt:       runAt.UTC(),
+		UniqueKey:   uniqueKey,
+	})
+}
+
+// Run works through due jobs until ctx is cancelled, then waits for the jobs
+// in flight to finish.
+func (r *Runner) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	if len(r.recurring) > 0 {
+		wg.Go(func() { r.schedule(ctx) })
+	}
+	for range r.Workers {
+		wg.Go(func() { r.work(ctx) })
+	}
+	wg.Wait()
+	return nil
+}
+
+// schedule keeps the next occurrence of every recurring job enqueued.
+func (r *Runner) schedule(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		err := r.enqueueRecurring(ctx, r.now())
+		if err != nil {
+			slog.Error("Couldn't schedule recurring jobs", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) enqueueRecurring(ctx context.Context, now time.Time) error {
+	for _, rec := range r.recurring {
+		next := rec.schedule.Next(now)
+		if next.IsZero() {
+			continue
+		}
+		// Every instance computes the same key, so only one row is created
+		key := rec.kind + "@" + next.Format(time.RFC3339)
+		err := enqueue(ctx, r.db, rec.kind, struct{}{}, next, sql.NullString{String: key, Valid: true})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Runner) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		ran, err := r.runNext(ctx)
+		if err != nil {
+			slog.Error("Couldn't claim job", "err", err)
+		}
+		if ran {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// runNext claims and runs one due job. It reports false when none was due.
+func (r *Runner) runNext(ctx context.Context) (bool, error) {
+	lease := sql.NullTime{Time: r.now().Add(r.Lease).UTC(), Valid: true}
+	job, err := r.db.ClaimJob(ctx, lease)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Handlers log with the job they're running
+	logger := slog.Default().With("job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+	ctx = logging.NewContext(ctx, logger)
+
+	// A job that's started runs to the end of its lease, and its outcome is
+	// recorded, even if we're shutting down
+	done := context.WithoutCancel(ctx)
+	if job.Attempts > job.MaxAttempts {
+		// Its last attempt never reported back, so the worker must have died
+		r.kill(done, job, errors.New("lease expired on the final attempt"))
+		return true, nil
+	}
+
+	err = r.run(done, job)
+	switch {
+	case err == nil:
+		err = r.db.DeleteJob(done, database.DeleteJobParams{ID: job.ID, Attempts: job.Attempts})
+		if err != nil {
+			logger.Error("Couldn't delete finished job", "err", err)
+		}
+	case job.Attempts >= job.MaxAttempts:
+		r.kill(done, job, err)
+	default:
+		runAt := r.now().Add(backoff(job.Attempts)).UTC()
+		logger.Warn("Job failed, will retry", "err", err, "retry_at", runAt)
+		err = r.db.RetryJob(done, database.RetryJobParams{
+			ID:        job.ID,
+			Attempts:  job.Attempts,
+			RunAt:     runAt,
+			LastError: sql.NullString{String: err.Error(), Valid: true},
+		})
+		if err != nil {
+			logger.Error("Couldn't reschedule job", "err", err)
+		}
+	}
+	return true, nil
+}
+
+func (r *Runner) run(ctx context.Context, job database.Job) (err error) {
+	h, ok := r.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler for %q jobs", job.Kind)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, r.Lease)
+	defer cancel()
+	return h(ctx, job.Payload)
+}
+
+// kill moves a job to the dead-letter state, where it stays until someone
+// requeues it.
+func (r *Runner) kill(ctx context.Context, job database.Job, cause error) {
+	logger := logging.FromContext(ctx)
+	logger.Error("Job failed too many times, giving up", "err", cause)
+	err := r.db.KillJob(ctx, database.KillJobParams{
+		ID:        job.ID,
+		Attempts:  job.Attempts,
+		LastError: sql.NullString{String: cause.Error(), Valid: true},
+	})
+	if err != nil {
+		logger.Error("Couldn't kill job", "err", err)
+	}
+}
+
+// backoff is how long to wait before retrying after the given attempt.
+func backoff(attempt int32) time.Duration {
+	d := maxBackoff
+	if attempt < 20 {
+		d = min(baseBackoff<<(attempt-1), maxBackoff)
+	}
+	return d + rand.N(d/10+1)
+}
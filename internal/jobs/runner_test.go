@@ -0,0 +1,351 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store/memory"
+)
+
+// past makes every retry due straight away.
+func past() time.Time {
+	return time.Now().Add(-24 * time.Hour)
+}
+
+func TestRunNext(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		handler   Handler
+		kind      string
+		wantError string
+	}{
+		{
+			name:    "Success removes the job",
+			kind:    "ok",
+			handler: func(context.Context, json.RawMessage) error { return nil },
+		},
+		{
+			name:      "Failure retries",
+			kind:      "flaky",
+			handler:   func(context.Context, json.RawMessage) error { return errors.New("boom") },
+			wantError: "boom",
+		},
+		{
+			name:      "Panic retries",
+			kind:      "panicky",
+			handler:   func(context.Context, json.RawMessage) error { panic("oh no") },
+			wantError: "panic: oh no",
+		},
+		{
+			name:      "Unknown kind retries",
+			kind:      "mystery",
+			wantError: `no handler for "mystery" jobs`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.New()
+			r := NewRunner(db)
+			if tt.handler != nil {
+				r.Handle(tt.kind, tt.handler)
+			}
+			err := Enqueue(ctx, db, tt.kind, map[string]int{"n": 1}, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Run it twice, without waiting out any backoff, then look at the
+			// job as a third worker would
+			r.now = past
+			for range 2 {
+				_, err := r.runNext(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			job, err := db.ClaimJob(ctx, sql.NullTime{Time: past(), Valid: true})
+			if tt.wantError == "" {
+				if !errors.Is(err, sql.ErrNoRows) {
+					t.Errorf("finished job is still queued: %+v", job)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if job.Attempts != 3 || job.LastError.String != tt.wantError {
+				t.Errorf("got attempts %d and error %q, want 3 and %q", job.Attempts, job.LastError.String, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestRetryBacksOff(t *testing.T) {
+	ctx := context.Background()
+	db := memory.New()
+	r := NewRunner(db)
+	r.Handle("flaky", func(context.Context, json.RawMessage) error { return errors.New("boom") })
+	err := Enqueue(ctx, db, "flaky", nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ran, err := r.runNext(ctx)
+	if err != nil || !ran {
+		t.Fatalf("runNext() = %v, %v", ran, err)
+	}
+	ran, _ = r.runNext(ctx)
+	if ran {
+		t.Error("retried straight away")
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	db := memory.New()
+	r := NewRunner(db)
+	r.now = past
+	var calls atomic.Int32
+	r.Handle("doomed", func(context.Context, json.RawMessage) error {
+		calls.Add(1)
+		return errors.New("still broken")
+	})
+	err := Enqueue(ctx, db, "doomed", nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		ran, err := r.runNext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ran {
+			break
+		}
+	}
+
+	if got := calls.Load(); got != DefaultMaxAttempts {
+		t.Errorf("handler ran %d times, want %d", got, DefaultMaxAttempts)
+	}
+	dead, err := db.GetDeadJobs(ctx)
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("GetDeadJobs() = %v, %v", dead, err)
+	}
+	if dead[0].LastError.String != "still broken" {
+		t.Errorf("got last error %q", dead[0].LastError.String)
+	}
+}
+
+func TestRunDrains(t *testing.T) {
+	db := memory.New()
+	r := NewRunner(db)
+	r.PollInterval = time.Millisecond
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	var cancelled atomic.Bool
+	r.Handle("slow", func(ctx context.Context, _ json.RawMessage) error {
+		close(started)
+		<-finish
+		cancelled.Store(ctx.Err() != nil)
+		return nil
+	})
+	err := Enqueue(context.Background(), db, "slow", nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(stopped)
+	}()
+	<-started
+	cancel()
+	select {
+	case <-stopped:
+		t.Fatal("Run returned with a job in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(finish)
+	<-stopped
+	if cancelled.Load() {
+		t.Error("the job in flight was cancelled")
+	}
+	_, err = db.ClaimJob(context.Background(), sql.NullTime{Time: past(), Valid: true})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Error("the job in flight wasn't recorded as finished")
+	}
+}
+
+func TestLapsedLease(t *testing.T) {
+	ctx := context.Background()
+	db := memory.New()
+	r := NewRunner(db)
+	r.Handle("slow", func(context.Context, json.RawMessage) error { return nil })
+	err := Enqueue(ctx, db, "slow", nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every attempt is claimed by a worker that dies, leaving its lease to lapse
+	for range DefaultMaxAttempts {
+		_, err := db.ClaimJob(ctx, sql.NullTime{Time: past(), Valid: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ran, err := r.runNext(ctx)
+	if err != nil || !ran {
+		t.Fatalf("runNext() = %v, %v", ran, err)
+	}
+	dead, _ := db.GetDeadJobs(ctx)
+	if len(dead) != 1 {
+		t.Fatalf("got %d dead jobs, want 1", len(dead))
+	}
+}
+
+func TestRecurring(t *testing.T) {
+	ctx := context.Background()
+	db := memory.New()
+
+	// Two instances schedule the same occurrence
+	var runs atomic.Int32
+	now := time.Now()
+	for range 2 {
+		r := NewRunner(db)
+		err := r.Cron("tick", "* * * * *", func(context.Context, json.RawMessage) error {
+			runs.Add(1)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.enqueueRecurring(ctx, now.Add(-2*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewRunner(db)
+	r.Handle("tick", func(context.Context, json.RawMessage) error {
+		runs.Add(1)
+		return nil
+	})
+	for {
+		ran, err := r.runNext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ran {
+			break
+		}
+	}
+	if got := runs.Load(); got != 1 {
+		t.Errorf("occurrence ran %d times, want 1", got)
+	}
+
+	err := r.Cron("bad", "every tuesday", nil)
+	if err == nil {
+		t.Error("Cron() accepted an invalid schedule")
+	}
+}
+
+// timeStore records the times the runner writes to the jobs table.
+type timeStore struct {
+	*memory.Store
+	times []time.Time
+}
+
+func (s *timeStore) CreateJob(ctx context.Context, arg database.CreateJobParams) error {
+	s.times = append(s.times, arg.RunAt)
+	return s.Store.CreateJob(ctx, arg)
+}
+
+func (s *timeStore) ClaimJob(ctx context.Context, lockedUntil sql.NullTime) (database.Job, error) {
+	s.times = append(s.times, lockedUntil.Time)
+	return s.Store.ClaimJob(ctx, lockedUntil)
+}
+
+func (s *timeStore) RetryJob(ctx context.Context, arg database.RetryJobParams) error {
+	s.times = append(s.times, arg.RunAt)
+	return s.Store.RetryJob(ctx, arg)
+}
+
+func TestTimesAreUTC(t *testing.T) {
+	// Postgres would drop a local offset from run_at and locked_until
+	local := time.Local
+	time.Local = time.FixedZone("EST", -5*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	ctx := context.Background()
+	db := &timeStore{Store: memory.New()}
+	r := NewRunner(db)
+	r.Handle("flaky", func(context.Context, json.RawMessage) error { return errors.New("boom") })
+	err := r.Cron("tick", "* * * * *", func(context.Context, json.RawMessage) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err = Enqueue(ctx, db, "flaky", nil, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.enqueueRecurring(ctx, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran, err := r.runNext(ctx)
+	if err != nil || !ran {
+		t.Fatalf("runNext() = %v, %v", ran, err)
+	}
+
+	// Enqueue, the next tick, the lease and the retry
+	if len(db.times) != 4 {
+		t.Fatalf("got %d times written, want 4", len(db.times))
+	}
+	for i, got := range db.times {
+		if got.Location() != time.UTC {
+			t.Errorf("time %d, %v, isn't in UTC", i, got)
+		}
+	}
+	if !db.times[0].Equal(start) {
+		t.Errorf("got run_at %v, want %v", db.times[0], start)
+	}
+	if lease := db.times[2].Sub(start); lease < r.Lease || lease > r.Lease+time.Minute {
+		t.Errorf("got a lease of %v, want %v", lease, r.Lease)
+	}
+	if retry := db.times[3].Sub(start); retry < baseBackoff || retry > baseBackoff+time.Minute {
+		t.Errorf("got a retry after %v, want %v", retry, baseBackoff)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	prev := time.Duration(0)
+	for attempt := int32(1); attempt <= 12; attempt++ {
+		d := backoff(attempt)
+		if d < prev && d < maxBackoff {
+			t.Errorf("backoff(%d) = %v, shorter than attempt %d", attempt, d, attempt-1)
+		}
+		if d > maxBackoff+maxBackoff/10 {
+			t.Errorf("backoff(%d) = %v, over the cap", attempt, d)
+		}
+		prev = d
+	}
+	if d := backoff(1); d < baseBackoff {
+		t.Errorf("backoff(1) = %v, want at least %v", d, baseBackoff)
+	}
+}
@@ -0,0 +1,51 @@
+// Package logging carries a request's slog.Logger through its context, so
+// everything that handles the request, down to the database layer, logs with
+// the same request ID and user.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+type ctxKey struct{}
+
+// scope holds the logger for one request. It's shared by every context
+// derived from the request's, so attributes added once the user is known
+// show up in everything logged after, including the access log line.
+type scope struct {
+	logger atomic.Pointer[slog.Logger]
+}
+
+// NewContext returns a context that carries l.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	s := &scope{}
+	s.logger.Store(l)
+	return context.WithValue(ctx, ctxKey{}, s)
+}
+
+// FromContext returns the logger ctx carries, or slog.Default() if it has
+// none.
+func FromContext(ctx context.Context) *slog.Logger {
+	s, ok := ctx.Value(ctxKey{}).(*scope)
+	if !ok {
+		return slog.Default()
+	}
+	return s.logger.Load()
+}
+
+// With adds attributes to the logger ctx carries, for the rest of the
+// request. It does nothing if ctx carries no logger.
+func With(ctx context.Context, args ...any) {
+	s, ok := ctx.Value(ctxKey{}).(*scope)
+	if !ok {
+		return
+	}
+	for {
+		old := s.logger.Load()
+		if s.logger.CompareAndSwap(old, old.With(args...)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestWith(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := NewContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)).With("request_id", "abc"))
+
+	// Attributes added through a derived context reach the original one
+	child, cancel := context.WithCancel(ctx)
+	defer cancel()
+	With(child, "user_id", "walt")
+	FromContext(ctx).Info("done")
+
+	var line map[string]any
+	err := json.Unmarshal(buf.Bytes(), &line)
+	if err != nil {
+		t.Fatalf("Couldn't decode %q: %v", buf.String(), err)
+	}
+	if line["request_id"] != "abc" || line["user_id"] != "walt" {
+		t.Errorf("got %v, want request_id and user_id", line)
+	}
+}
+
+func TestFromContextDefault(t *testing.T) {
+	ctx := context.Background()
+	if FromContext(ctx) != slog.Default() {
+		t.Error("FromContext() without a logger isn't the default")
+	}
+	// Must not panic
+	With(ctx, "user_id", "walt")
+}
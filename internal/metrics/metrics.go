@@ -0,0 +1,148 @@
+// Package metrics holds the Prometheus metrics Chirpy exports at /metrics,
+// and summarises them for the admin dashboard.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Why a login failed.
+const (
+	LoginUnknownEmail  = "unknown_email"
+	LoginWrongPassword = "wrong_password"
+)
+
+// What became of a Polka webhook.
+const (
+	WebhookUpgraded     = "upgraded"
+	WebhookIgnored      = "ignored"
+	WebhookUnauthorized = "unauthorized"
+	WebhookUnknownUser  = "unknown_user"
+	WebhookFailed       = "failed"
+)
+
+// UnmatchedRoute labels requests no route matched, so stray paths don't each
+// get their own series.
+const UnmatchedRoute = "unmatched"
+
+// Metrics is one server's set of metrics. Each has its own registry, so tests
+// can run servers side by side.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+
+	// FileserverHits counts requests for the web app under /app/
+	FileserverHits prometheus.Counter
+	// UsersCreated counts sign-ups
+	UsersCreated prometheus.Counter
+	// ChirpsCreated counts new chirps by the status they were created with
+	ChirpsCreated *prometheus.CounterVec
+	// LoginFailures counts rejected logins by reason
+	LoginFailures *prometheus.CounterVec
+	// Webhooks counts Polka webhooks by outcome
+	Webhooks *prometheus.CounterVec
+	// RateLimited counts requests refused by rate limiting, by policy
+	RateLimited *prometheus.CounterVec
+	// DeprecatedRequests counts calls to deprecated routes, by route
+	// pattern, so we know who still has to move before the sunset
+	DeprecatedRequests *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests served, by route pattern and status code.",
+		}, []string{"route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by route pattern and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "status"}),
+		FileserverHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_fileserver_hits_total",
+			Help: "Requests for the web app.",
+		}),
+		UsersCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_users_created_total",
+			Help: "Users who signed up.",
+		}),
+		ChirpsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps created, by status: published, scheduled or draft.",
+		}, []string{"status"}),
+		LoginFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_login_failures_total",
+			Help: "Rejected logins, by reason.",
+		}, []string{"reason"}),
+		Webhooks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_polka_webhooks_total",
+			Help: "Polka webhooks received, by outcome.",
+		}, []string{"outcome"}),
+		RateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_rate_limited_total",
+			Help: "Requests refused by rate limiting, by policy.",
+		}, []string{"policy"}),
+		DeprecatedRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_deprecated_requests_total",
+			Help: "Requests to deprecated routes, by route pattern.",
+		}, []string{"route"}),
+	}
+
+	// Known label values start at zero rather than appearing on first use,
+	// so rates over them are defined from the start
+	for _, status := range []string{"published", "scheduled", "draft"} {
+		m.ChirpsCreated.WithLabelValues(status)
+	}
+	for _, reason := range []string{LoginUnknownEmail, LoginWrongPassword} {
+		m.LoginFailures.WithLabelValues(reason)
+	}
+	for _, outcome := range []string{WebhookUpgraded, WebhookIgnored, WebhookUnauthorized, WebhookUnknownUser, WebhookFailed} {
+		m.Webhooks.WithLabelValues(outcome)
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.latency,
+		m.FileserverHits,
+		m.UsersCreated,
+		m.ChirpsCreated,
+		m.LoginFailures,
+		m.Webhooks,
+		m.RateLimited,
+		m.DeprecatedRequests,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// RegisterDB exports the connection pool stats of db.
+func (m *Metrics) RegisterDB(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, "chirpy"))
+}
+
+// ObserveRequest records a served request. route is the pattern the mux
+// matched, or "" if none did.
+func (m *Metrics) ObserveRequest(route string, status int, latency time.Duration) {
+	if route == "" {
+		route = UnmatchedRoute
+	}
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(route, code).Inc()
+	m.latency.WithLabelValues(route, code).Observe(latency.Seconds())
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummaryRoutes(t *testing.T) {
+	m := New()
+	for range 19 {
+		m.ObserveRequest("GET /api/chirps", 200, 3*time.Millisecond)
+	}
+	m.ObserveRequest("GET /api/chirps", 500, 2*time.Second)
+	m.ObserveRequest("", 404, time.Millisecond)
+
+	s, err := m.Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.DB != nil {
+		t.Errorf("got DB stats %+v without a DB", s.DB)
+	}
+	if len(s.Routes) != 2 {
+		t.Fatalf("got routes %+v, want 2", s.Routes)
+	}
+
+	tests := []struct {
+		got, want RouteSummary
+	}{
+		{
+			got: s.Routes[0],
+			want: RouteSummary{
+				Route:       "GET /api/chirps",
+				Requests:    20,
+				Errors:      1,
+				MeanLatency: (19*3*time.Millisecond + 2*time.Second) / 20,
+				P95Latency:  5 * time.Millisecond,
+			},
+		},
+		{
+			got: s.Routes[1],
+			want: RouteSummary{
+				Route:       UnmatchedRoute,
+				Requests:    1,
+				MeanLatency: time.Millisecond,
+				P95Latency:  5 * time.Millisecond,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want.Route, func(t *testing.T) {
+			// Latencies go through float seconds, so allow for rounding
+			diff := tt.got.MeanLatency - tt.want.MeanLatency
+			if diff < -time.Microsecond || diff > time.Microsecond {
+				t.Errorf("got mean latency %v, want %v", tt.got.MeanLatency, tt.want.MeanLatency)
+			}
+			tt.got.MeanLatency = tt.want.MeanLatency
+			if tt.got != tt.want {
+				t.Errorf("got %+v, want %+v", tt.got, tt.want)
+			}
+		})
+	}
+}
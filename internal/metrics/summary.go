@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Summary is a point-in-time digest of the metrics, for people rather than
+// Prometheus.
+type Summary struct {
+	FileserverHits float64
+	UsersCreated   float64
+	ChirpsCreated  map[string]float64
+	LoginFailures  map[string]float64
+	Webhooks       map[string]float64
+	RateLimited    map[string]float64
+	// Deprecated counts calls to deprecated routes, by route pattern
+	Deprecated map[string]float64
+	Routes     []RouteSummary
+	// DB is nil unless RegisterDB was called
+	DB *DBSummary
+}
+
+// RouteSummary covers every request one route pattern served.
+type RouteSummary struct {
+	Route    string
+	Requests float64
+	// Errors counts 5XX responses
+	Errors      float64
+	MeanLatency time.Duration
+	// P95Latency is estimated from the histogram buckets: it's the upper
+	// bound of the bucket the 95th percentile falls in, or of the last
+	// bucket if it's beyond them all
+	P95Latency time.Duration
+}
+
+type DBSummary struct {
+	OpenConnections float64
+	InUse           float64
+	Idle            float64
+	WaitCount       float64
+	WaitDuration    time.Duration
+}
+
+// Summary gathers the current values of every metric.
+func (m *Metrics) Summary() (Summary, error) {
+	families, err := m.registry.Gather()
+	if err != nil {
+		return Summary{}, err
+	}
+
+	s := Summary{
+		ChirpsCreated: map[string]float64{},
+		LoginFailures: map[string]float64{},
+		Webhooks:      map[string]float64{},
+		RateLimited:   map[string]float64{},
+		Deprecated:    map[string]float64{},
+	}
+	routes := map[string]*routeHistogram{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			switch family.GetName() {
+			case "chirpy_fileserver_hits_total":
+				s.FileserverHits = metric.GetCounter().GetValue()
+			case "chirpy_users_created_total":
+				s.UsersCreated = metric.GetCounter().GetValue()
+			case "chirpy_chirps_created_total":
+				s.ChirpsCreated[label(metric, "status")] = metric.GetCounter().GetValue()
+			case "chirpy_login_failures_total":
+				s.LoginFailures[label(metric, "reason")] = metric.GetCounter().GetValue()
+			case "chirpy_polka_webhooks_total":
+				s.Webhooks[label(metric, "outcome")] = metric.GetCounter().GetValue()
+			case "chirpy_rate_limited_total":
+				s.RateLimited[label(metric, "policy")] = metric.GetCounter().GetValue()
+			case "chirpy_deprecated_requests_total":
+				s.Deprecated[label(metric, "route")] = metric.GetCounter().GetValue()
+			case "chirpy_http_request_duration_seconds":
+				route := label(metric, "route")
+				if routes[route] == nil {
+					routes[route] = &routeHistogram{}
+				}
+				routes[route].add(metric.GetHistogram(), strings.HasPrefix(label(metric, "status"), "5"))
+			case "go_sql_open_connections":
+				s.db().OpenConnections = metric.GetGauge().GetValue()
+			case "go_sql_in_use_connections":
+				s.db().InUse = metric.GetGauge().GetValue()
+			case "go_sql_idle_connections":
+				s.db().Idle = metric.GetGauge().GetValue()
+			case "go_sql_wait_count_total":
+				s.db().WaitCount = metric.GetCounter().GetValue()
+			case "go_sql_wait_duration_seconds_total":
+				s.db().WaitDuration = seconds(metric.GetCounter().GetValue())
+			}
+		}
+	}
+
+	for route, h := range routes {
+		s.Routes = append(s.Routes, h.summary(route))
+	}
+	// Busiest first
+	slices.SortFunc(s.Routes, func(a, b RouteSummary) int {
+		return cmp.Or(cmp.Compare(b.Requests, a.Requests), strings.Compare(a.Route, b.Route))
+	})
+	return s, nil
+}
+
+func (s *Summary) db() *DBSummary {
+	if s.DB == nil {
+		s.DB = &DBSummary{}
+	}
+	return s.DB
+}
+
+func label(metric *dto.Metric, name string) string {
+	for _, pair := range metric.GetLabel() {
+		if pair.GetName() == name {
+			return pair.GetValue()
+		}
+	}
+	return ""
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// routeHistogram merges the per-status histograms of one route.
+type routeHistogram struct {
+	count, errors, sum float64
+	// buckets maps each upper bound to its cumulative count
+	buckets map[float64]float64
+}
+
+func (h *routeHistogram) add(hist *dto.Histogram, serverError bool) {
+	count := float64(hist.GetSampleCount())
+	h.count += count
+	h.sum += hist.GetSampleSum()
+	if serverError {
+		h.errors += count
+	}
+	if h.buckets == nil {
+		h.buckets = map[float64]float64{}
+	}
+	for _, b := range hist.GetBucket() {
+		h.buckets[b.GetUpperBound()] += float64(b.GetCumulativeCount())
+	}
+}
+
+func (h *routeHistogram) summary(route string) RouteSummary {
+	rs := RouteSummary{Route: route, Requests: h.count, Errors: h.errors}
+	if h.count == 0 {
+		return rs
+	}
+	rs.MeanLatency = seconds(h.sum / h.count)
+
+	bounds := make([]float64, 0, len(h.buckets))
+	for bound := range h.buckets {
+		bounds = append(bounds, bound)
+	}
+	slices.Sort(bounds)
+	target := math.Ceil(h.count * 0.95)
+	for _, bound := range bounds {
+		rs.P95Latency = seconds(bound)
+		if h.buckets[bound] >= target {
+			break
+		}
+	}
+	return rs
+}
@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// Migrator applies the goose migrations in a filesystem to a database. It
+// uses goose's own version table, so databases migrated by hand with the
+// goose CLI are picked up where they left off.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+// New builds a Migrator for a pool opened with the given store driver. fsys
+// holds the .sql files for that driver at its root.
+func New(db *sql.DB, driver string, fsys fs.FS) (*Migrator, error) {
+	var opts []goose.ProviderOption
+	dialect := goose.DialectSQLite3
+	if driver == store.DriverPostgres {
+		dialect = goose.DialectPostgres
+
+		// Several instances starting at once take turns instead of racing
+		// each other through the same migrations
+		locker, err := lock.NewPostgresSessionLocker()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, goose.WithSessionLocker(locker))
+	}
+
+	provider, err := goose.NewProvider(dialect, db, fsys, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// Up applies every pending migration and returns the ones it ran.
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the most recently applied migration. It returns
+// goose.ErrNoNextVersion when there is nothing left to roll back.
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// Check returns an error unless the database is at exactly the latest
+// migration: one that is behind needs `chirpy migrate up`, and one that is
+// ahead was migrated by a newer build that this one doesn't understand.
+func (m *Migrator) Check(ctx context.Context) error {
+	current, latest, err := m.provider.GetVersions(ctx)
+	if err != nil {
+		return err
+	}
+	if current > latest {
+		return fmt.Errorf("database is at version %d but this build only knows migrations up to %d", current, latest)
+	}
+
+	pending, err := m.provider.HasPending(ctx)
+	if err != nil {
+		return err
+	}
+	if pending {
+		return fmt.Errorf("database is at version %d but the latest migration is %d", current, latest)
+	}
+	return nil
+}
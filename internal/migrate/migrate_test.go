@@ -0,0 +1,110 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store"
+	"github.com/pressly/goose/v3"
+)
+
+const schemaDir = "../../sql/sqlite/schema"
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := store.OpenDB("sqlite://" + filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestMigrator(t *testing.T, db *sql.DB, fsys fs.FS) *Migrator {
+	t.Helper()
+	m, err := New(db, store.DriverSQLite, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator(t, openTestDB(t), os.DirFS(schemaDir))
+
+	if m.Check(ctx) == nil {
+		t.Fatal("Check() on an empty database = nil, want error")
+	}
+
+	results, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if len(results) == 0 {
+		t.Fatal("Up() applied no migrations")
+	}
+	err = m.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check() after Up() = %v, want nil", err)
+	}
+
+	res, err := m.Down(ctx)
+	if err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	if m.Check(ctx) == nil {
+		t.Fatalf("Check() after rolling back %d = nil, want error", res.Source.Version)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	for i, st := range statuses {
+		want := goose.StateApplied
+		if i == len(statuses)-1 {
+			want = goose.StatePending
+		}
+		if st.State != want {
+			t.Errorf("migration %d is %s, want %s", st.Source.Version, st.State, want)
+		}
+	}
+
+	for {
+		_, err = m.Down(ctx)
+		if err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, goose.ErrNoNextVersion) {
+		t.Errorf("Down() past the first migration error = %v, want ErrNoNextVersion", err)
+	}
+}
+
+func TestCheckNewerDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	_, err := newTestMigrator(t, db, os.DirFS(schemaDir)).Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An older build that only shipped the first migration
+	first, err := os.ReadFile(filepath.Join(schemaDir, "001_users.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	older := newTestMigrator(t, db, fstest.MapFS{
+		"001_users.sql": {Data: first},
+	})
+	if older.Check(ctx) == nil {
+		t.Error("Check() on a newer database = nil, want error")
+	}
+}
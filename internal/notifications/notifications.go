@@ -0,0 +1,107 @@
+package notifications
+
+import (
+	"context"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store"
+	"github.com/google/uuid"
+)
+
+type Type string
+
+// Likes, replies, follows and mentions have no feature behind them yet; the
+// types exist so preferences can be set ahead of time.
+const (
+	TypeLike    Type = "like"
+	TypeReply   Type = "reply"
+	TypeFollow  Type = "follow"
+	TypeMention Type = "mention"
+	TypeMessage Type = "message"
+)
+
+// Types lists every notification type, in the order preferences are shown.
+var Types = []Type{TypeLike, TypeReply, TypeFollow, TypeMention, TypeMessage}
+
+func Valid(typ string) bool {
+	for _, t := range Types {
+		if string(t) == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Service records notifications. Events on the same subject are grouped into
+// a single unread notification with a list of actors, so five likes on one
+// chirp show up as one "5 people liked your chirp" entry.
+type Service struct {
+	store  store.Store
+	events *events.Hub
+}
+
+func NewService(s store.Store, hub *events.Hub) *Service {
+	return &Service{
+		store:  s,
+		events: hub,
+	}
+}
+
+// Notify records that actor did something of the given type to subject, for
+// recipient to see. Nothing is recorded for the recipient's own actions,
+// disabled types, or actors the recipient has a block with.
+func (s *Service) Notify(ctx context.Context, recipient, actor uuid.UUID, typ Type, subject uuid.UUID) error {
+	if recipient == actor {
+		return nil
+	}
+
+	enabled, err := s.store.NotificationEnabled(ctx, database.NotificationEnabledParams{
+		UserID: recipient,
+		Type:   string(typ),
+	})
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+
+	blocked, err := s.store.IsBlocked(ctx, database.IsBlockedParams{
+		UserA: recipient,
+		UserB: actor,
+	})
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return nil
+	}
+
+	var notification database.Notification
+	err = s.store.InTx(ctx, func(q database.Querier) error {
+		var err error
+		notification, err = q.UpsertNotification(ctx, database.UpsertNotificationParams{
+			UserID:    recipient,
+			Type:      string(typ),
+			SubjectID: subject,
+		})
+		if err != nil {
+			return err
+		}
+		return q.AddNotificationActor(ctx, database.AddNotificationActorParams{
+			NotificationID: notification.ID,
+			ActorID:        actor,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.events.Publish(ctx, events.NotificationCreated, actor, recipient, map[string]any{
+		"id":         notification.ID,
+		"type":       typ,
+		"subject_id": subject,
+		"actor_id":   actor,
+	})
+}
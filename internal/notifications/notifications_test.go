@@ -0,0 +1,83 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store/memory"
+	"github.com/google/uuid"
+)
+
+func TestNotify(t *testing.T) {
+	ctx := t.Context()
+	s := memory.New()
+	hub := events.NewHub(s)
+	service := NewService(s, hub)
+
+	var users []uuid.UUID
+	for _, email := range []string{"walt@breakingbad.com", "jesse@breakingbad.com", "hank@dea.gov", "gus@pollos.com"} {
+		user, err := s.CreateUser(ctx, database.CreateUserParams{Email: email, HashedPassword: "hash"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, user.ID)
+	}
+	walt, jesse, hank, gus := users[0], users[1], users[2], users[3]
+	err := s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: walt, BlockedID: gus})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetNotificationPreference(ctx, database.SetNotificationPreferenceParams{UserID: walt, Type: string(TypeFollow), Enabled: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chirp := uuid.New()
+	tests := []struct {
+		name   string
+		actor  uuid.UUID
+		typ    Type
+		record bool
+	}{
+		{name: "Own action", actor: walt, typ: TypeLike},
+		{name: "Disabled type", actor: jesse, typ: TypeFollow},
+		{name: "Blocked actor", actor: gus, typ: TypeLike},
+		{name: "First like", actor: jesse, typ: TypeLike, record: true},
+		{name: "Second like", actor: hank, typ: TypeLike, record: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := hub.Since(ctx, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = service.Notify(ctx, walt, tt.actor, tt.typ, chirp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, err := hub.Since(ctx, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if published := len(after) > len(before); published != tt.record {
+				t.Errorf("published an event: %v, want %v", published, tt.record)
+			}
+		})
+	}
+
+	// Both likes are one notification
+	rows, err := s.GetNotifications(ctx, database.GetNotificationsParams{
+		UserID:     walt,
+		BeforeTime: time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC),
+		BeforeID:   uuid.Max,
+		MaxResults: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].ActorCount != 2 || rows[0].SubjectID != chirp {
+		t.Fatalf("got notifications %+v, want one like of the chirp from two people", rows)
+	}
+}
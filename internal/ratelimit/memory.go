@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+)
+
+// MemoryStore keeps buckets in this process, so each instance limits
+// separately. It works like the rate_limits queries.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]int64{}}
+}
+
+func (m *MemoryStore) TakeRateLimit(ctx context.Context, arg database.TakeRateLimitParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fullAt := max(m.buckets[arg.Key], arg.Now) + arg.Cost
+	if fullAt > arg.Now+arg.Capacity {
+		return 0, sql.ErrNoRows
+	}
+	m.buckets[arg.Key] = fullAt
+	return fullAt, nil
+}
+
+func (m *MemoryStore) GetRateLimit(ctx context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fullAt, ok := m.buckets[key]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return fullAt, nil
+}
+
+func (m *MemoryStore) DeleteFullRateLimits(ctx context.Context, fullAt int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for key, t := range m.buckets {
+		if t <= fullAt {
+			delete(m.buckets, key)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,121 @@
+// Package ratelimit limits how often each client may call an endpoint, with
+// a token bucket per client and policy.
+//
+// Buckets live in a Store: MemoryStore for a single instance, or the
+// database, through the same queries, so every instance shares them.
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+)
+
+// Limit is the size and refill rate of a bucket.
+type Limit struct {
+	// Burst is how many requests a full bucket allows at once
+	Burst int
+	// Per is how long an empty bucket takes to fill again
+	Per time.Duration
+}
+
+// Store holds the buckets. A bucket is stored as the time it will next be
+// full, in microseconds since the Unix epoch, and taking a token must check
+// and move that time in one step. store.Store and MemoryStore both satisfy
+// it.
+type Store interface {
+	TakeRateLimit(ctx context.Context, arg database.TakeRateLimitParams) (int64, error)
+	GetRateLimit(ctx context.Context, key string) (int64, error)
+	DeleteFullRateLimits(ctx context.Context, fullAt int64) (int64, error)
+}
+
+// Result is the outcome of one request, with what's needed to tell the
+// client where it stands.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long a refused client should wait before trying
+	// again. It's zero for allowed requests.
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+func New(store Store) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Allow takes a token from key's bucket if there's one left.
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := l.now().UnixMicro()
+	// Each request moves the bucket's full time on by cost; a request is
+	// allowed while that stays within capacity of now
+	cost := max(limit.Per.Microseconds()/int64(limit.Burst), 1)
+	capacity := cost * int64(limit.Burst)
+
+	fullAt, err := l.store.TakeRateLimit(ctx, database.TakeRateLimitParams{
+		Key:      key,
+		Now:      now,
+		Cost:     cost,
+		Capacity: capacity,
+	})
+	if err == nil {
+		return Result{
+			Allowed:   true,
+			Remaining: int((now + capacity - fullAt) / cost),
+			Reset:     micros(fullAt - now),
+		}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Result{}, err
+	}
+
+	fullAt, err = l.store.GetRateLimit(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Cleaned up in between, so it's full now
+		return Result{RetryAfter: time.Second}, nil
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Reset:      micros(fullAt - now),
+		RetryAfter: micros(max(fullAt+cost-capacity-now, 0)),
+	}, nil
+}
+
+// Prune deletes buckets that have filled up again, which are the same as no
+// bucket at all.
+func (l *Limiter) Prune(ctx context.Context) (int64, error) {
+	return l.store.DeleteFullRateLimits(ctx, l.now().UnixMicro())
+}
+
+// Run prunes every interval until ctx is cancelled.
+func (l *Limiter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := l.Prune(ctx)
+			if err != nil {
+				slog.Error("Couldn't prune rate limits", "err", err)
+			}
+		}
+	}
+}
+
+func micros(n int64) time.Duration {
+	return time.Duration(n) * time.Microsecond
+}
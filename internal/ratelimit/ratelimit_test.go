@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	limit := Limit{Burst: 3, Per: 30 * time.Second}
+
+	// Each step happens at start plus at, in order, against one bucket
+	tests := []struct {
+		name          string
+		at            time.Duration
+		key           string
+		want          bool
+		wantRemaining int
+		wantReset     time.Duration
+		wantRetry     time.Duration
+	}{
+		{name: "First", want: true, wantRemaining: 2, wantReset: 10 * time.Second},
+		{name: "Second", want: true, wantRemaining: 1, wantReset: 20 * time.Second},
+		{name: "Third", want: true, wantRemaining: 0, wantReset: 30 * time.Second},
+		{name: "Empty", at: time.Second, want: false, wantReset: 29 * time.Second, wantRetry: 9 * time.Second},
+		{name: "Other key", at: time.Second, key: "other", want: true, wantRemaining: 2, wantReset: 10 * time.Second},
+		{name: "Refused requests don't count", at: 10 * time.Second, want: true, wantRemaining: 0, wantReset: 30 * time.Second},
+		{name: "Full again", at: time.Minute, want: true, wantRemaining: 2, wantReset: 10 * time.Second},
+	}
+
+	l := New(NewMemoryStore())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l.now = func() time.Time { return start.Add(tt.at) }
+			key := tt.key
+			if key == "" {
+				key = "user"
+			}
+			got, err := l.Allow(ctx, key, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Result{Allowed: tt.want, Remaining: tt.wantRemaining, Reset: tt.wantReset, RetryAfter: tt.wantRetry}
+			if got != want {
+				t.Errorf("Allow() = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	l.now = func() time.Time { return start.Add(time.Minute + 5*time.Second) }
+	n, err := l.Prune(ctx)
+	if err != nil || n != 1 {
+		t.Errorf("Prune() = %d, %v; want the full bucket of other deleted", n, err)
+	}
+}
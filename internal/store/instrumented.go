@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentedDB traces every query as a child of the request that ran it,
+// and logs it at debug level with the request's logger. It satisfies the
+// DBTX interface of both generated packages.
+//
+// Spans and logged durations cover dispatching a query: for :many queries,
+// that's until the first rows are ready, not until they've all been read.
+// *sql.Rows is a concrete type, so there's nothing here to hook the caller's
+// iteration or Close on; timing that would mean wrapping the driver.
+type instrumentedDB struct {
+	db database.DBTX
+	// system is the db.system.name attribute of every span
+	system attribute.KeyValue
+}
+
+func (i instrumentedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, done := i.start(ctx, query)
+	res, err := i.db.ExecContext(ctx, query, args...)
+	done(err)
+	return res, err
+}
+
+func (i instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return i.db.PrepareContext(ctx, query)
+}
+
+// QueryContext's span ends once the query returns its rows, before the
+// caller reads them; see instrumentedDB.
+func (i instrumentedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, done := i.start(ctx, query)
+	rows, err := i.db.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+func (i instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, done := i.start(ctx, query)
+	row := i.db.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+// start opens a span for query and returns a function that ends it and logs
+// the query.
+func (i instrumentedDB) start(ctx context.Context, query string) (context.Context, func(error)) {
+	start := time.Now()
+	name := queryName(query)
+	ctx, span := tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			i.system,
+			semconv.DBOperationName(name),
+			semconv.DBQueryText(query),
+		),
+	)
+	return ctx, func(err error) {
+		tracing.End(span, err)
+		logQuery(ctx, name, start, err)
+	}
+}
+
+func logQuery(ctx context.Context, name string, start time.Time, err error) {
+	logger := logging.FromContext(ctx)
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("query", name),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("err", err))
+	}
+	logger.LogAttrs(ctx, slog.LevelDebug, "query", attrs...)
+}
+
+// queryName picks the name out of the "-- name: GetChirps :many" line sqlc
+// starts every query with, so logs don't carry whole statements.
+func queryName(query string) string {
+	line, _, _ := strings.Cut(query, "\n")
+	name, ok := strings.CutPrefix(line, "-- name: ")
+	if !ok {
+		return line
+	}
+	name, _, _ = strings.Cut(name, " ")
+	return name
+}
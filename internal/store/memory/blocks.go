@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateBlock(ctx context.Context, arg database.CreateBlockParams) error {
+	defer s.lock()()
+
+	if err := s.d.requireUser(arg.BlockerID); err != nil {
+		return err
+	}
+	if err := s.d.requireUser(arg.BlockedID); err != nil {
+		return err
+	}
+	key := pair{arg.BlockerID, arg.BlockedID}
+	if _, ok := s.d.blocks[key]; ok {
+		return nil
+	}
+	s.d.blocks[key] = database.Block{
+		BlockerID: arg.BlockerID,
+		BlockedID: arg.BlockedID,
+		CreatedAt: s.d.now(),
+	}
+	return nil
+}
+
+func (s *Store) DeleteBlock(ctx context.Context, arg database.DeleteBlockParams) (int64, error) {
+	defer s.lock()()
+
+	key := pair{arg.BlockerID, arg.BlockedID}
+	if _, ok := s.d.blocks[key]; !ok {
+		return 0, nil
+	}
+	delete(s.d.blocks, key)
+	return 1, nil
+}
+
+func (s *Store) GetBlocks(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error) {
+	defer s.lock()()
+
+	var blocks []database.Block
+	for _, block := range sortedValues(s.d.blocks, func(a, b database.Block) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}) {
+		if block.BlockerID == blockerID {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+func (s *Store) IsBlocked(ctx context.Context, arg database.IsBlockedParams) (bool, error) {
+	defer s.lock()()
+
+	return s.d.blockedBetween(arg.UserA, arg.UserB), nil
+}
+
+func (s *Store) CreateMute(ctx context.Context, arg database.CreateMuteParams) error {
+	defer s.lock()()
+
+	if err := s.d.requireUser(arg.MuterID); err != nil {
+		return err
+	}
+	if err := s.d.requireUser(arg.MutedID); err != nil {
+		return err
+	}
+	key := pair{arg.MuterID, arg.MutedID}
+	if _, ok := s.d.mutes[key]; ok {
+		return nil
+	}
+	s.d.mutes[key] = database.Mute{
+		MuterID:   arg.MuterID,
+		MutedID:   arg.MutedID,
+		CreatedAt: s.d.now(),
+	}
+	return nil
+}
+
+func (s *Store) DeleteMute(ctx context.Context, arg database.DeleteMuteParams) (int64, error) {
+	defer s.lock()()
+
+	key := pair{arg.MuterID, arg.MutedID}
+	if _, ok := s.d.mutes[key]; !ok {
+		return 0, nil
+	}
+	delete(s.d.mutes, key)
+	return 1, nil
+}
+
+func (s *Store) GetMutes(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error) {
+	defer s.lock()()
+
+	var mutes []database.Mute
+	for _, mute := range sortedValues(s.d.mutes, func(a, b database.Mute) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}) {
+		if mute.MuterID == muterID {
+			mutes = append(mutes, mute)
+		}
+	}
+	return mutes, nil
+}
+
+func (s *Store) GetHiddenAuthors(ctx context.Context, blockerID uuid.UUID) ([]uuid.UUID, error) {
+	defer s.lock()()
+
+	hidden := map[uuid.UUID]bool{}
+	for key := range s.d.blocks {
+		if key.a == blockerID {
+			hidden[key.b] = true
+		}
+		if key.b == blockerID {
+			hidden[key.a] = true
+		}
+	}
+	for key := range s.d.mutes {
+		if key.a == blockerID {
+			hidden[key.b] = true
+		}
+	}
+
+	var ids []uuid.UUID
+	for id := range hidden {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, compareUUID)
+	return ids, nil
+}
+
+func (d *data) blockedBetween(a, b uuid.UUID) bool {
+	_, ab := d.blocks[pair{a, b}]
+	_, ba := d.blocks[pair{b, a}]
+	return ab || ba
+}
+
+func (d *data) muted(muter, muted uuid.UUID) bool {
+	_, ok := d.mutes[pair{muter, muted}]
+	return ok
+}
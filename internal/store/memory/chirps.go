@@ -0,0 +1,183 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	defer s.lock()()
+
+	if err := s.d.requireUser(arg.UserID); err != nil {
+		return database.Chirp{}, err
+	}
+	now := s.d.now()
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+		Status:    arg.Status,
+		PublishAt: truncateNull(arg.PublishAt),
+	}
+	s.d.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (s *Store) GetChirps(ctx context.Context, viewerID uuid.UUID) ([]database.Chirp, error) {
+	defer s.lock()()
+
+	var chirps []database.Chirp
+	for _, chirp := range s.d.sortedChirps() {
+		if chirp.Status != "published" {
+			continue
+		}
+		if s.d.blockedBetween(viewerID, chirp.UserID) || s.d.muted(viewerID, chirp.UserID) {
+			continue
+		}
+		chirps = append(chirps, chirp)
+	}
+	return chirps, nil
+}
+
+func (s *Store) GetAuthorChirps(ctx context.Context, arg database.GetAuthorChirpsParams) ([]database.Chirp, error) {
+	defer s.lock()()
+
+	var chirps []database.Chirp
+	for _, chirp := range s.d.sortedChirps() {
+		if chirp.UserID != arg.UserID || chirp.Status != "published" {
+			continue
+		}
+		if s.d.blockedBetween(arg.ViewerID, chirp.UserID) || s.d.muted(arg.ViewerID, chirp.UserID) {
+			continue
+		}
+		chirps = append(chirps, chirp)
+	}
+	return chirps, nil
+}
+
+func (s *Store) GetAuthorsChirps(ctx context.Context, arg database.GetAuthorsChirpsParams) ([]database.Chirp, error) {
+	defer s.lock()()
+
+	sorted := s.d.sortedChirps()
+	if arg.NewestFirst {
+		slices.Reverse(sorted)
+	}
+	var chirps []database.Chirp
+	perAuthor := map[uuid.UUID]int32{}
+	for _, chirp := range sorted {
+		if !slices.Contains(arg.UserIds, chirp.UserID) || chirp.Status != "published" {
+			continue
+		}
+		if s.d.blockedBetween(arg.ViewerID, chirp.UserID) || s.d.muted(arg.ViewerID, chirp.UserID) {
+			continue
+		}
+		if perAuthor[chirp.UserID] == arg.PerAuthor {
+			continue
+		}
+		perAuthor[chirp.UserID]++
+		chirps = append(chirps, chirp)
+	}
+	// Whichever end each author's chirps came from, they're oldest first
+	if arg.NewestFirst {
+		slices.Reverse(chirps)
+	}
+	return chirps, nil
+}
+
+func (s *Store) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	defer s.lock()()
+
+	chirp, ok := s.d.chirps[id]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (s *Store) GetVisibleChirp(ctx context.Context, arg database.GetVisibleChirpParams) (database.Chirp, error) {
+	defer s.lock()()
+
+	chirp, ok := s.d.chirps[arg.ID]
+	if !ok || chirp.Status != "published" || s.d.blockedBetween(arg.ViewerID, chirp.UserID) {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (s *Store) GetDrafts(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	defer s.lock()()
+
+	var chirps []database.Chirp
+	for _, chirp := range s.d.sortedChirps() {
+		if chirp.UserID == userID && chirp.Status != "published" {
+			chirps = append(chirps, chirp)
+		}
+	}
+	return chirps, nil
+}
+
+func (s *Store) UpdateDraft(ctx context.Context, arg database.UpdateDraftParams) (database.Chirp, error) {
+	defer s.lock()()
+
+	chirp, ok := s.d.chirps[arg.ID]
+	if !ok || chirp.Status == "published" {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	now := s.d.now()
+	chirp.Body = arg.Body
+	chirp.Status = arg.Status
+	chirp.PublishAt = truncateNull(arg.PublishAt)
+	if arg.Status == "published" {
+		chirp.CreatedAt = now
+	}
+	chirp.UpdatedAt = now
+	s.d.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (s *Store) PublishDueChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	defer s.lock()()
+
+	chirp, ok := s.d.chirps[id]
+	now := s.d.now()
+	if !ok || chirp.Status != "scheduled" || chirp.PublishAt.Time.After(now) {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	chirp.Status = "published"
+	chirp.CreatedAt = now
+	chirp.UpdatedAt = now
+	s.d.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (s *Store) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	defer s.lock()()
+
+	delete(s.d.chirps, id)
+	return nil
+}
+
+func (s *Store) DeleteUserChirps(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	defer s.lock()()
+
+	var ids []uuid.UUID
+	for _, chirp := range s.d.sortedChirps() {
+		if chirp.UserID == userID {
+			delete(s.d.chirps, chirp.ID)
+			ids = append(ids, chirp.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (d *data) sortedChirps() []database.Chirp {
+	return sortedValues(d.chirps, func(a, b database.Chirp) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+}
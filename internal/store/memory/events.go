@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+)
+
+func (s *Store) CreateEvent(ctx context.Context, arg database.CreateEventParams) (database.Event, error) {
+	defer s.lock()()
+
+	if arg.UserID.Valid {
+		if err := s.d.requireUser(arg.UserID.UUID); err != nil {
+			return database.Event{}, err
+		}
+	}
+	if arg.ActorID.Valid {
+		if err := s.d.requireUser(arg.ActorID.UUID); err != nil {
+			return database.Event{}, err
+		}
+	}
+	if len(arg.Payload) == 0 {
+		return database.Event{}, fmt.Errorf("memory: payload can't be null")
+	}
+
+	s.d.lastEventID++
+	ev := database.Event{
+		ID:        s.d.lastEventID,
+		CreatedAt: s.d.now(),
+		Type:      arg.Type,
+		UserID:    arg.UserID,
+		ActorID:   arg.ActorID,
+		Payload:   slices.Clone(arg.Payload),
+	}
+	s.d.events = append(s.d.events, ev)
+	return ev, nil
+}
+
+func (s *Store) DeleteEventsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	defer s.lock()()
+
+	n := len(s.d.events)
+	s.d.events = slices.DeleteFunc(s.d.events, func(ev database.Event) bool {
+		return ev.CreatedAt.Before(createdAt)
+	})
+	return int64(n - len(s.d.events)), nil
+}
+
+func (s *Store) GetEventsAfter(ctx context.Context, arg database.GetEventsAfterParams) ([]database.Event, error) {
+	defer s.lock()()
+
+	var events []database.Event
+	for _, ev := range s.d.events {
+		if len(events) == int(arg.Limit) {
+			break
+		}
+		if ev.ID > arg.ID {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+func (s *Store) GetLatestEventID(ctx context.Context) (int64, error) {
+	defer s.lock()()
+
+	if len(s.d.events) == 0 {
+		return 0, nil
+	}
+	return s.d.events[len(s.d.events)-1].ID, nil
+}
@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateJob(ctx context.Context, arg database.CreateJobParams) error {
+	defer s.lock()()
+
+	if arg.UniqueKey.Valid {
+		for _, job := range s.d.jobs {
+			if job.UniqueKey == arg.UniqueKey {
+				return nil
+			}
+		}
+	}
+	now := s.d.now()
+	job := database.Job{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Kind:        arg.Kind,
+		Payload:     slices.Clone(arg.Payload),
+		State:       "pending",
+		MaxAttempts: arg.MaxAttempts,
+		RunAt:       timestamp(arg.RunAt),
+		UniqueKey:   arg.UniqueKey,
+	}
+	s.d.jobs[job.ID] = job
+	return nil
+}
+
+func (s *Store) ClaimJob(ctx context.Context, lockedUntil sql.NullTime) (database.Job, error) {
+	defer s.lock()()
+
+	now := s.d.now()
+	due := sortedValues(s.d.jobs, func(a, b database.Job) int {
+		return a.RunAt.Compare(b.RunAt)
+	})
+	for _, job := range due {
+		pending := job.State == "pending" && !job.RunAt.After(now)
+		lapsed := job.State == "running" && job.LockedUntil.Valid && job.LockedUntil.Time.Before(now)
+		if !pending && !lapsed {
+			continue
+		}
+		job.State = "running"
+		job.Attempts++
+		job.LockedUntil = truncateNull(lockedUntil)
+		job.UpdatedAt = now
+		s.d.jobs[job.ID] = job
+		return job, nil
+	}
+	return database.Job{}, sql.ErrNoRows
+}
+
+func (s *Store) DeleteJob(ctx context.Context, arg database.DeleteJobParams) error {
+	defer s.lock()()
+
+	job, ok := s.d.jobs[arg.ID]
+	if ok && job.Attempts == arg.Attempts {
+		delete(s.d.jobs, arg.ID)
+	}
+	return nil
+}
+
+func (s *Store) RetryJob(ctx context.Context, arg database.RetryJobParams) error {
+	defer s.lock()()
+
+	job, ok := s.d.jobs[arg.ID]
+	if !ok || job.Attempts != arg.Attempts {
+		return nil
+	}
+	job.State = "pending"
+	job.RunAt = timestamp(arg.RunAt)
+	job.LastError = arg.LastError
+	job.LockedUntil = sql.NullTime{}
+	job.UpdatedAt = s.d.now()
+	s.d.jobs[job.ID] = job
+	return nil
+}
+
+func (s *Store) KillJob(ctx context.Context, arg database.KillJobParams) error {
+	defer s.lock()()
+
+	job, ok := s.d.jobs[arg.ID]
+	if !ok || job.Attempts != arg.Attempts {
+		return nil
+	}
+	job.State = "dead"
+	job.LastError = arg.LastError
+	job.LockedUntil = sql.NullTime{}
+	job.UpdatedAt = s.d.now()
+	s.d.jobs[job.ID] = job
+	return nil
+}
+
+func (s *Store) GetDeadJobs(ctx context.Context) ([]database.Job, error) {
+	defer s.lock()()
+
+	var dead []database.Job
+	for _, job := range s.d.jobs {
+		if job.State == "dead" {
+			dead = append(dead, job)
+		}
+	}
+	slices.SortFunc(dead, func(a, b database.Job) int {
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	})
+	return dead, nil
+}
+
+func (s *Store) RequeueDeadJob(ctx context.Context, id uuid.UUID) (database.Job, error) {
+	defer s.lock()()
+
+	job, ok := s.d.jobs[id]
+	if !ok || job.State != "dead" {
+		return database.Job{}, sql.ErrNoRows
+	}
+	now := s.d.now()
+	job.State = "pending"
+	job.Attempts = 0
+	job.RunAt = now
+	job.LastError = sql.NullString{}
+	job.UpdatedAt = now
+	s.d.jobs[job.ID] = job
+	return job, nil
+}
@@ -0,0 +1,185 @@
+// Package memory is an in-memory store.Store for tests. It mirrors the
+// Postgres queries closely enough that handlers can't tell the difference:
+// rows come back in the same order, missing rows return sql.ErrNoRows, and
+// unique keys, foreign keys and ON DELETE CASCADE are enforced.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUniqueViolation     = fmt.Errorf("memory: %w", store.ErrUniqueViolation)
+	ErrForeignKeyViolation = errors.New("memory: foreign key violation")
+)
+
+type Store struct {
+	mu *sync.Mutex
+	d  *data
+
+	// tx is set on the view handed to InTx, which already holds mu
+	tx bool
+}
+
+var _ store.Store = (*Store)(nil)
+
+// pair keys the tables whose primary key is two UUIDs.
+type pair struct {
+	a, b uuid.UUID
+}
+
+type preferenceKey struct {
+	userID uuid.UUID
+	typ    string
+}
+
+type data struct {
+	users         map[uuid.UUID]database.User
+	chirps        map[uuid.UUID]database.Chirp
+	refreshTokens map[string]database.RefreshToken
+
+	blocks map[pair]database.Block
+	mutes  map[pair]database.Mute
+
+	conversations map[uuid.UUID]database.Conversation
+	participants  map[pair]database.ConversationParticipant
+	messages      map[uuid.UUID]database.Message
+
+	events      []database.Event
+	lastEventID int64
+
+	notifications      map[uuid.UUID]database.Notification
+	notificationActors map[pair]database.NotificationActor
+	preferences        map[preferenceKey]database.NotificationPreference
+
+	jobs map[uuid.UUID]database.Job
+
+	// rateLimits maps each key to when its bucket is next full
+	rateLimits map[string]int64
+
+	lastNow time.Time
+}
+
+func New() *Store {
+	return &Store{
+		mu: &sync.Mutex{},
+		d: &data{
+			users:              map[uuid.UUID]database.User{},
+			chirps:             map[uuid.UUID]database.Chirp{},
+			refreshTokens:      map[string]database.RefreshToken{},
+			blocks:             map[pair]database.Block{},
+			mutes:              map[pair]database.Mute{},
+			conversations:      map[uuid.UUID]database.Conversation{},
+			participants:       map[pair]database.ConversationParticipant{},
+			messages:           map[uuid.UUID]database.Message{},
+			notifications:      map[uuid.UUID]database.Notification{},
+			notificationActors: map[pair]database.NotificationActor{},
+			preferences:        map[preferenceKey]database.NotificationPreference{},
+			jobs:               map[uuid.UUID]database.Job{},
+			rateLimits:         map[string]int64{},
+		},
+	}
+}
+
+// InTx runs fn against a snapshot-protected view of the store. Other callers
+// wait until it finishes, and if fn fails every change it made is undone.
+func (s *Store) InTx(ctx context.Context, fn func(q database.Querier) error) error {
+	if s.tx {
+		return fmt.Errorf("memory: nested transactions are not supported")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.d.clone()
+	err := fn(&Store{mu: s.mu, d: s.d, tx: true})
+	if err != nil {
+		*s.d = *snapshot
+		return err
+	}
+	return nil
+}
+
+// lock takes the store lock unless this is a transaction view. Use as
+// defer s.lock()().
+func (s *Store) lock() func() {
+	if s.tx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// now mimics NOW() on a TIMESTAMP column: UTC with microsecond precision.
+// It never returns the same instant twice, so ORDER BY created_at is stable.
+func (d *data) now() time.Time {
+	t := time.Now().UTC().Truncate(time.Microsecond)
+	if !t.After(d.lastNow) {
+		t = d.lastNow.Add(time.Microsecond)
+	}
+	d.lastNow = t
+	return t
+}
+
+// timestamp stores a caller's time the way a TIMESTAMP column would.
+func timestamp(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+func truncateNull(t sql.NullTime) sql.NullTime {
+	if t.Valid {
+		t.Time = timestamp(t.Time)
+	}
+	return t
+}
+
+func (d *data) requireUser(id uuid.UUID) error {
+	if _, ok := d.users[id]; !ok {
+		return fmt.Errorf("%w: user %s", ErrForeignKeyViolation, id)
+	}
+	return nil
+}
+
+func (d *data) clone() *data {
+	return &data{
+		users:              maps.Clone(d.users),
+		chirps:             maps.Clone(d.chirps),
+		refreshTokens:      maps.Clone(d.refreshTokens),
+		blocks:             maps.Clone(d.blocks),
+		mutes:              maps.Clone(d.mutes),
+		conversations:      maps.Clone(d.conversations),
+		participants:       maps.Clone(d.participants),
+		messages:           maps.Clone(d.messages),
+		events:             slices.Clone(d.events),
+		lastEventID:        d.lastEventID,
+		notifications:      maps.Clone(d.notifications),
+		notificationActors: maps.Clone(d.notificationActors),
+		preferences:        maps.Clone(d.preferences),
+		jobs:               maps.Clone(d.jobs),
+		rateLimits:         maps.Clone(d.rateLimits),
+		lastNow:            d.lastNow,
+	}
+}
+
+// sortedValues returns the map's values ordered by cmp.
+func sortedValues[K comparable, V any](m map[K]V, cmp func(a, b V) int) []V {
+	values := slices.Collect(maps.Values(m))
+	slices.SortFunc(values, cmp)
+	return values
+}
+
+// compareUUID orders UUIDs the way Postgres does, byte by byte.
+func compareUUID(a, b uuid.UUID) int {
+	return bytes.Compare(a[:], b[:])
+}
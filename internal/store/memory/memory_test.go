@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store/storetest"
+)
+
+func TestInTx(t *testing.T) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	tests := []struct {
+		name     string
+		fn       func(q database.Querier) error
+		wantErr  error
+		wantUser bool
+	}{
+		{
+			name: "Commit",
+			fn: func(q database.Querier) error {
+				_, err := q.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+				return err
+			},
+			wantUser: true,
+		},
+		{
+			name: "Rollback",
+			fn: func(q database.Querier) error {
+				_, err := q.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+				if err != nil {
+					return err
+				}
+				return errAbort
+			},
+			wantErr: errAbort,
+		},
+		{
+			name: "Constraint violation",
+			fn: func(q database.Querier) error {
+				_, err := q.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+				if err != nil {
+					return err
+				}
+				_, err = q.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+				return err
+			},
+			wantErr: ErrUniqueViolation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			err := s.InTx(ctx, tt.fn)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("InTx() error = %v, want %v", err, tt.wantErr)
+			}
+
+			_, err = s.GetUser(ctx, "walt@breakingbad.com")
+			if tt.wantUser && err != nil {
+				t.Errorf("user wasn't committed: %v", err)
+			}
+			if !tt.wantUser && !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("user wasn't rolled back: %v", err)
+			}
+		})
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return New()
+	})
+}
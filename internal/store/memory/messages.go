@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateConversation(ctx context.Context) (database.Conversation, error) {
+	defer s.lock()()
+
+	now := s.d.now()
+	conversation := database.Conversation{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.d.conversations[conversation.ID] = conversation
+	return conversation, nil
+}
+
+func (s *Store) AddConversationParticipant(ctx context.Context, arg database.AddConversationParticipantParams) error {
+	defer s.lock()()
+
+	if _, ok := s.d.conversations[arg.ConversationID]; !ok {
+		return fmt.Errorf("%w: conversation %s", ErrForeignKeyViolation, arg.ConversationID)
+	}
+	if err := s.d.requireUser(arg.UserID); err != nil {
+		return err
+	}
+	key := pair{arg.ConversationID, arg.UserID}
+	if _, ok := s.d.participants[key]; ok {
+		return ErrUniqueViolation
+	}
+	s.d.participants[key] = database.ConversationParticipant{
+		ConversationID: arg.ConversationID,
+		UserID:         arg.UserID,
+		JoinedAt:       s.d.now(),
+	}
+	return nil
+}
+
+func (s *Store) GetConversationParticipant(ctx context.Context, arg database.GetConversationParticipantParams) (database.ConversationParticipant, error) {
+	defer s.lock()()
+
+	participant, ok := s.d.participants[pair{arg.ConversationID, arg.UserID}]
+	if !ok {
+		return database.ConversationParticipant{}, sql.ErrNoRows
+	}
+	return participant, nil
+}
+
+func (s *Store) GetConversationsParticipants(ctx context.Context, conversationIds []uuid.UUID) ([]database.ConversationParticipant, error) {
+	defer s.lock()()
+
+	var participants []database.ConversationParticipant
+	for _, participant := range s.d.sortedParticipants() {
+		if slices.Contains(conversationIds, participant.ConversationID) {
+			participants = append(participants, participant)
+		}
+	}
+	return participants, nil
+}
+
+func (s *Store) FindDirectConversation(ctx context.Context, arg database.FindDirectConversationParams) (database.Conversation, error) {
+	defer s.lock()()
+
+	for _, conversation := range sortedValues(s.d.conversations, func(a, b database.Conversation) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}) {
+		members := s.d.members(conversation.ID)
+		if len(members) == 2 && slices.Contains(members, arg.UserA) && slices.Contains(members, arg.UserB) {
+			return conversation, nil
+		}
+	}
+	return database.Conversation{}, sql.ErrNoRows
+}
+
+func (s *Store) GetUserConversations(ctx context.Context, userID uuid.UUID) ([]database.GetUserConversationsRow, error) {
+	defer s.lock()()
+
+	var rows []database.GetUserConversationsRow
+	for _, participant := range s.d.participants {
+		if participant.UserID != userID {
+			continue
+		}
+		conversation := s.d.conversations[participant.ConversationID]
+		if participant.ClearedAt.Valid && !conversation.UpdatedAt.After(participant.ClearedAt.Time) {
+			continue
+		}
+
+		var unread int64
+		for _, message := range s.d.messages {
+			if message.ConversationID != conversation.ID || message.SenderID == userID {
+				continue
+			}
+			if participant.LastReadAt.Valid && !message.CreatedAt.After(participant.LastReadAt.Time) {
+				continue
+			}
+			if participant.ClearedAt.Valid && !message.CreatedAt.After(participant.ClearedAt.Time) {
+				continue
+			}
+			unread++
+		}
+
+		rows = append(rows, database.GetUserConversationsRow{
+			ID:          conversation.ID,
+			CreatedAt:   conversation.CreatedAt,
+			UpdatedAt:   conversation.UpdatedAt,
+			UnreadCount: unread,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.GetUserConversationsRow) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return rows, nil
+}
+
+func (s *Store) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	defer s.lock()()
+
+	conversation, ok := s.d.conversations[id]
+	if !ok {
+		return nil
+	}
+	conversation.UpdatedAt = s.d.now()
+	s.d.conversations[id] = conversation
+	return nil
+}
+
+func (s *Store) ConversationHasBlock(ctx context.Context, arg database.ConversationHasBlockParams) (bool, error) {
+	defer s.lock()()
+
+	for _, member := range s.d.members(arg.ConversationID) {
+		if s.d.blockedBetween(arg.UserID, member) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) CreateMessage(ctx context.Context, arg database.CreateMessageParams) (database.Message, error) {
+	defer s.lock()()
+
+	if _, ok := s.d.conversations[arg.ConversationID]; !ok {
+		return database.Message{}, fmt.Errorf("%w: conversation %s", ErrForeignKeyViolation, arg.ConversationID)
+	}
+	if err := s.d.requireUser(arg.SenderID); err != nil {
+		return database.Message{}, err
+	}
+	now := s.d.now()
+	message := database.Message{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ConversationID: arg.ConversationID,
+		SenderID:       arg.SenderID,
+		Body:           arg.Body,
+	}
+	s.d.messages[message.ID] = message
+	return message, nil
+}
+
+func (s *Store) GetConversationMessages(ctx context.Context, arg database.GetConversationMessagesParams) ([]database.Message, error) {
+	defer s.lock()()
+
+	participant, ok := s.d.participants[pair{arg.ConversationID, arg.UserID}]
+	if !ok {
+		return nil, nil
+	}
+
+	var messages []database.Message
+	for _, message := range sortedValues(s.d.messages, func(a, b database.Message) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}) {
+		if message.ConversationID != arg.ConversationID {
+			continue
+		}
+		if participant.ClearedAt.Valid && !message.CreatedAt.After(participant.ClearedAt.Time) {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (s *Store) MarkConversationRead(ctx context.Context, arg database.MarkConversationReadParams) (int64, error) {
+	defer s.lock()()
+
+	key := pair{arg.ConversationID, arg.UserID}
+	participant, ok := s.d.participants[key]
+	if !ok {
+		return 0, nil
+	}
+	message, ok := s.d.messages[arg.MessageID]
+	if !ok || message.ConversationID != arg.ConversationID {
+		return 0, nil
+	}
+	if !participant.LastReadAt.Valid || message.CreatedAt.After(participant.LastReadAt.Time) {
+		participant.LastReadAt = sql.NullTime{Time: message.CreatedAt, Valid: true}
+	}
+	s.d.participants[key] = participant
+	return 1, nil
+}
+
+func (s *Store) ClearConversation(ctx context.Context, arg database.ClearConversationParams) error {
+	defer s.lock()()
+
+	key := pair{arg.ConversationID, arg.UserID}
+	participant, ok := s.d.participants[key]
+	if !ok {
+		return nil
+	}
+	now := sql.NullTime{Time: s.d.now(), Valid: true}
+	participant.ClearedAt = now
+	participant.LastReadAt = now
+	s.d.participants[key] = participant
+	return nil
+}
+
+func (s *Store) ResetConversations(ctx context.Context) error {
+	defer s.lock()()
+
+	clear(s.d.conversations)
+	clear(s.d.participants)
+	clear(s.d.messages)
+	return nil
+}
+
+func (d *data) sortedParticipants() []database.ConversationParticipant {
+	return sortedValues(d.participants, func(a, b database.ConversationParticipant) int {
+		return a.JoinedAt.Compare(b.JoinedAt)
+	})
+}
+
+func (d *data) members(conversationID uuid.UUID) []uuid.UUID {
+	var members []uuid.UUID
+	for key := range d.participants {
+		if key.a == conversationID {
+			members = append(members, key.b)
+		}
+	}
+	return members
+}
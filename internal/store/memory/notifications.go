@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// recentActors matches the LIMIT in GetNotifications.
+const recentActors = 3
+
+func (s *Store) UpsertNotification(ctx context.Context, arg database.UpsertNotificationParams) (database.Notification, error) {
+	defer s.lock()()
+
+	if err := s.d.requireUser(arg.UserID); err != nil {
+		return database.Notification{}, err
+	}
+
+	// Join the unread notification for the same subject, if there is one
+	now := s.d.now()
+	for id, notification := range s.d.notifications {
+		if notification.UserID == arg.UserID && notification.Type == arg.Type &&
+			notification.SubjectID == arg.SubjectID && !notification.ReadAt.Valid {
+			notification.UpdatedAt = now
+			s.d.notifications[id] = notification
+			return notification, nil
+		}
+	}
+
+	notification := database.Notification{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		Type:      arg.Type,
+		SubjectID: arg.SubjectID,
+	}
+	s.d.notifications[notification.ID] = notification
+	return notification, nil
+}
+
+func (s *Store) AddNotificationActor(ctx context.Context, arg database.AddNotificationActorParams) error {
+	defer s.lock()()
+
+	if _, ok := s.d.notifications[arg.NotificationID]; !ok {
+		return fmt.Errorf("%w: notification %s", ErrForeignKeyViolation, arg.NotificationID)
+	}
+	if err := s.d.requireUser(arg.ActorID); err != nil {
+		return err
+	}
+	s.d.notificationActors[pair{arg.NotificationID, arg.ActorID}] = database.NotificationActor{
+		NotificationID: arg.NotificationID,
+		ActorID:        arg.ActorID,
+		CreatedAt:      s.d.now(),
+	}
+	return nil
+}
+
+func (s *Store) GetNotifications(ctx context.Context, arg database.GetNotificationsParams) ([]database.GetNotificationsRow, error) {
+	defer s.lock()()
+
+	var rows []database.GetNotificationsRow
+	for _, notification := range s.d.notifications {
+		if notification.UserID != arg.UserID {
+			continue
+		}
+		if arg.UnreadOnly && notification.ReadAt.Valid {
+			continue
+		}
+		// (updated_at, id) < (before_time, before_id)
+		if c := notification.UpdatedAt.Compare(arg.BeforeTime); c > 0 || c == 0 && compareUUID(notification.ID, arg.BeforeID) >= 0 {
+			continue
+		}
+
+		actors := sortedValues(s.d.notificationActors, func(a, b database.NotificationActor) int {
+			return b.CreatedAt.Compare(a.CreatedAt)
+		})
+		recent := []uuid.UUID{}
+		var count int64
+		for _, actor := range actors {
+			if actor.NotificationID != notification.ID {
+				continue
+			}
+			count++
+			if len(recent) < recentActors {
+				recent = append(recent, actor.ActorID)
+			}
+		}
+
+		rows = append(rows, database.GetNotificationsRow{
+			ID:           notification.ID,
+			CreatedAt:    notification.CreatedAt,
+			UpdatedAt:    notification.UpdatedAt,
+			UserID:       notification.UserID,
+			Type:         notification.Type,
+			SubjectID:    notification.SubjectID,
+			ReadAt:       notification.ReadAt,
+			ActorCount:   count,
+			RecentActors: recent,
+		})
+	}
+
+	slices.SortFunc(rows, func(a, b database.GetNotificationsRow) int {
+		if c := b.UpdatedAt.Compare(a.UpdatedAt); c != 0 {
+			return c
+		}
+		return compareUUID(b.ID, a.ID)
+	})
+	if len(rows) > int(arg.MaxResults) {
+		rows = rows[:arg.MaxResults]
+	}
+	return rows, nil
+}
+
+func (s *Store) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	defer s.lock()()
+
+	var count int64
+	for _, notification := range s.d.notifications {
+		if notification.UserID == userID && !notification.ReadAt.Valid {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) MarkNotificationRead(ctx context.Context, arg database.MarkNotificationReadParams) (int64, error) {
+	defer s.lock()()
+
+	notification, ok := s.d.notifications[arg.ID]
+	if !ok || notification.UserID != arg.UserID {
+		return 0, nil
+	}
+	if !notification.ReadAt.Valid {
+		notification.ReadAt = sql.NullTime{Time: s.d.now(), Valid: true}
+		s.d.notifications[arg.ID] = notification
+	}
+	return 1, nil
+}
+
+func (s *Store) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) error {
+	defer s.lock()()
+
+	now := sql.NullTime{Time: s.d.now(), Valid: true}
+	for id, notification := range s.d.notifications {
+		if notification.UserID == userID && !notification.ReadAt.Valid {
+			notification.ReadAt = now
+			s.d.notifications[id] = notification
+		}
+	}
+	return nil
+}
+
+func (s *Store) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	defer s.lock()()
+
+	var preferences []database.NotificationPreference
+	for _, preference := range sortedValues(s.d.preferences, func(a, b database.NotificationPreference) int {
+		return strings.Compare(a.Type, b.Type)
+	}) {
+		if preference.UserID == userID {
+			preferences = append(preferences, preference)
+		}
+	}
+	return preferences, nil
+}
+
+func (s *Store) SetNotificationPreference(ctx context.Context, arg database.SetNotificationPreferenceParams) error {
+	defer s.lock()()
+
+	if err := s.d.requireUser(arg.UserID); err != nil {
+		return err
+	}
+	s.d.preferences[preferenceKey{arg.UserID, arg.Type}] = database.NotificationPreference{
+		UserID:    arg.UserID,
+		Type:      arg.Type,
+		Enabled:   arg.Enabled,
+		UpdatedAt: s.d.now(),
+	}
+	return nil
+}
+
+func (s *Store) NotificationEnabled(ctx context.Context, arg database.NotificationEnabledParams) (bool, error) {
+	defer s.lock()()
+
+	preference, ok := s.d.preferences[preferenceKey{arg.UserID, arg.Type}]
+	if !ok {
+		return true, nil
+	}
+	return preference.Enabled, nil
+}
@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+)
+
+func (s *Store) TakeRateLimit(ctx context.Context, arg database.TakeRateLimitParams) (int64, error) {
+	defer s.lock()()
+
+	fullAt := max(s.d.rateLimits[arg.Key], arg.Now) + arg.Cost
+	if fullAt > arg.Now+arg.Capacity {
+		return 0, sql.ErrNoRows
+	}
+	s.d.rateLimits[arg.Key] = fullAt
+	return fullAt, nil
+}
+
+func (s *Store) GetRateLimit(ctx context.Context, key string) (int64, error) {
+	defer s.lock()()
+
+	fullAt, ok := s.d.rateLimits[key]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return fullAt, nil
+}
+
+func (s *Store) DeleteFullRateLimits(ctx context.Context, fullAt int64) (int64, error) {
+	defer s.lock()()
+
+	var n int64
+	for key, t := range s.d.rateLimits {
+		if t <= fullAt {
+			delete(s.d.rateLimits, key)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	defer s.lock()()
+
+	if _, ok := s.d.refreshTokens[arg.Token]; ok {
+		return database.RefreshToken{}, ErrUniqueViolation
+	}
+	if err := s.d.requireUser(arg.UserID); err != nil {
+		return database.RefreshToken{}, err
+	}
+	now := s.d.now()
+	token := database.RefreshToken{
+		Token:     arg.Token,
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		ExpiresAt: timestamp(arg.ExpiresAt),
+	}
+	s.d.refreshTokens[token.Token] = token
+	return token, nil
+}
+
+func (s *Store) GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	defer s.lock()()
+
+	refresh, ok := s.d.refreshTokens[token]
+	if !ok {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return refresh, nil
+}
+
+func (s *Store) GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error) {
+	defer s.lock()()
+
+	refresh, ok := s.d.refreshTokens[token]
+	if !ok || refresh.RevokedAt.Valid || !refresh.ExpiresAt.After(s.d.now()) {
+		return database.User{}, sql.ErrNoRows
+	}
+	user, ok := s.d.users[refresh.UserID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *Store) RevokeRefreshToken(ctx context.Context, token string) error {
+	defer s.lock()()
+
+	refresh, ok := s.d.refreshTokens[token]
+	if !ok {
+		return nil
+	}
+	now := s.d.now()
+	refresh.UpdatedAt = now
+	refresh.RevokedAt = sql.NullTime{Time: now, Valid: true}
+	s.d.refreshTokens[token] = refresh
+	return nil
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	defer s.lock()()
+
+	var revoked int64
+	now := s.d.now()
+	for key, refresh := range s.d.refreshTokens {
+		if refresh.UserID != userID || refresh.RevokedAt.Valid {
+			continue
+		}
+		refresh.UpdatedAt = now
+		refresh.RevokedAt = sql.NullTime{Time: now, Valid: true}
+		s.d.refreshTokens[key] = refresh
+		revoked++
+	}
+	return revoked, nil
+}
+
+func (s *Store) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	defer s.lock()()
+
+	var deleted int64
+	now := s.d.now()
+	for key, refresh := range s.d.refreshTokens {
+		if refresh.RevokedAt.Valid || !refresh.ExpiresAt.After(now) {
+			delete(s.d.refreshTokens, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	defer s.lock()()
+
+	if s.d.emailTaken(arg.Email, uuid.Nil) {
+		return database.User{}, ErrUniqueViolation
+	}
+	now := s.d.now()
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		Role:           "user",
+	}
+	s.d.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) GetUser(ctx context.Context, email string) (database.User, error) {
+	defer s.lock()()
+
+	for _, user := range s.d.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) GetUserFromID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	defer s.lock()()
+
+	user, ok := s.d.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *Store) GetUsersFromIDs(ctx context.Context, ids []uuid.UUID) ([]database.User, error) {
+	defer s.lock()()
+
+	var users []database.User
+	for id, user := range s.d.users {
+		if slices.Contains(ids, id) {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (s *Store) GetRefreshTokenFromUser(ctx context.Context, id uuid.UUID) (database.RefreshToken, error) {
+	defer s.lock()()
+
+	// Postgres returns an arbitrary row here; the oldest is as good as any
+	var found *database.RefreshToken
+	for _, token := range s.d.refreshTokens {
+		if token.UserID != id {
+			continue
+		}
+		if found == nil || token.CreatedAt.Before(found.CreatedAt) {
+			found = &token
+		}
+	}
+	if found == nil {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return *found, nil
+}
+
+func (s *Store) UpdateUserEmail(ctx context.Context, arg database.UpdateUserEmailParams) (database.User, error) {
+	defer s.lock()()
+
+	user, ok := s.d.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	if s.d.emailTaken(arg.Email, arg.ID) {
+		return database.User{}, ErrUniqueViolation
+	}
+	user.Email = arg.Email
+	user.UpdatedAt = s.d.now()
+	s.d.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	defer s.lock()()
+
+	user, ok := s.d.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.HashedPassword = arg.HashedPassword
+	user.UpdatedAt = s.d.now()
+	s.d.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) UpdateUserRed(ctx context.Context, arg database.UpdateUserRedParams) (database.User, error) {
+	defer s.lock()()
+
+	user, ok := s.d.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.IsChirpyRed = true
+	user.ChirpyRedExpiresAt = truncateNull(arg.ChirpyRedExpiresAt)
+	user.UpdatedAt = s.d.now()
+	s.d.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) SetUserRed(ctx context.Context, arg database.SetUserRedParams) (database.User, error) {
+	defer s.lock()()
+
+	user, ok := s.d.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.IsChirpyRed = arg.IsChirpyRed
+	user.ChirpyRedExpiresAt = sql.NullTime{}
+	user.UpdatedAt = s.d.now()
+	s.d.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error) {
+	defer s.lock()()
+
+	user, ok := s.d.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.Role = arg.Role
+	user.UpdatedAt = s.d.now()
+	s.d.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) ExpireChirpyRed(ctx context.Context) (int64, error) {
+	defer s.lock()()
+
+	var expired int64
+	now := s.d.now()
+	for id, user := range s.d.users {
+		if !user.IsChirpyRed || !user.ChirpyRedExpiresAt.Valid || user.ChirpyRedExpiresAt.Time.After(now) {
+			continue
+		}
+		user.IsChirpyRed = false
+		user.ChirpyRedExpiresAt = sql.NullTime{}
+		user.UpdatedAt = now
+		s.d.users[id] = user
+		expired++
+	}
+	return expired, nil
+}
+
+// ResetUsers deletes every user along with everything that cascades from
+// them. Conversations and events without a user survive, as in Postgres.
+func (s *Store) ResetUsers(ctx context.Context) error {
+	defer s.lock()()
+
+	clear(s.d.users)
+	clear(s.d.chirps)
+	clear(s.d.refreshTokens)
+	clear(s.d.blocks)
+	clear(s.d.mutes)
+	clear(s.d.participants)
+	clear(s.d.messages)
+	clear(s.d.notifications)
+	clear(s.d.notificationActors)
+	clear(s.d.preferences)
+
+	kept := s.d.events[:0]
+	for _, ev := range s.d.events {
+		if !ev.UserID.Valid && !ev.ActorID.Valid {
+			kept = append(kept, ev)
+		}
+	}
+	s.d.events = kept
+	return nil
+}
+
+func (d *data) emailTaken(email string, except uuid.UUID) bool {
+	for _, user := range d.users {
+		if user.Email == email && user.ID != except {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database/sqlite"
+	"github.com/google/uuid"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteTimeFormat is how timestamps are stored. It is the driver's
+// _time_format=sqlite layout, so times written by Go and by NOW() are
+// identical text and compare correctly. All times are stored in UTC.
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+func init() {
+	// The SQLite queries call NOW() and gen_random_uuid() just like the
+	// Postgres ones
+	sqlitedriver.MustRegisterScalarFunction("now", 0, sqliteNow)
+	sqlitedriver.MustRegisterScalarFunction("gen_random_uuid", 0,
+		func(*sqlitedriver.FunctionContext, []driver.Value) (driver.Value, error) {
+			return uuid.NewString(), nil
+		},
+	)
+}
+
+var (
+	clockMu sync.Mutex
+	lastNow time.Time
+)
+
+// sqliteNow returns the current time with microsecond precision, like a
+// Postgres TIMESTAMP. It never returns the same instant twice, so rows
+// created in quick succession still sort in creation order.
+func sqliteNow(*sqlitedriver.FunctionContext, []driver.Value) (driver.Value, error) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if !now.After(lastNow) {
+		now = lastNow.Add(time.Microsecond)
+	}
+	lastNow = now
+	return now.Format(sqliteTimeFormat), nil
+}
+
+// isSQLiteUniqueViolation is IsUniqueViolation for SQLite, which reports
+// duplicate primary keys separately from other unique constraints.
+func isSQLiteUniqueViolation(err error) bool {
+	var sqliteErr *sqlitedriver.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code()
+	return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+type sqliteStore struct {
+	sqliteQueries
+	db *sql.DB
+}
+
+// openSQLite opens the SQLite database at path, which may carry extra driver
+// options as a query string. Foreign keys are enforced, and transactions take
+// the write lock when they begin so concurrent writers wait for each other
+// instead of failing halfway through.
+func openSQLite(path string) (*sql.DB, error) {
+	path, rawQuery, _ := strings.Cut(path, "?")
+	options, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	options.Add("_pragma", "foreign_keys(1)")
+	options.Add("_pragma", "busy_timeout(5000)")
+	options.Add("_pragma", "journal_mode(WAL)")
+	options.Set("_time_format", "sqlite")
+	options.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", path+"?"+options.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if path == ":memory:" {
+		// Every connection to :memory: gets its own empty database
+		db.SetMaxOpenConns(1)
+	}
+	return db, nil
+}
+
+// NewSQLite wraps a pool opened by OpenDB with a sqlite:// URL.
+func NewSQLite(db *sql.DB) Store {
+	return &sqliteStore{
+		sqliteQueries: sqliteQueries{q: sqlite.New(instrumentedDB{db, semconv.DBSystemNameSQLite})},
+		db:            db,
+	}
+}
+
+func (s *sqliteStore) InTx(ctx context.Context, fn func(q database.Querier) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(sqliteQueries{q: sqlite.New(instrumentedDB{tx, semconv.DBSystemNameSQLite})})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,458 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database/sqlite"
+	"github.com/google/uuid"
+)
+
+// sqliteQueries adapts the queries generated for SQLite to database.Querier.
+// sqlc.yaml maps both engines to the same Go types, so rows and parameters
+// convert directly; the few queries that differ are converted by hand.
+type sqliteQueries struct {
+	q *sqlite.Queries
+}
+
+var _ database.Querier = sqliteQueries{}
+
+func convertRows[T, U any](rows []T, convert func(T) U) []U {
+	if rows == nil {
+		return nil
+	}
+	converted := make([]U, len(rows))
+	for i, row := range rows {
+		converted[i] = convert(row)
+	}
+	return converted
+}
+
+// convertJob widens the attempt counters, which SQLite reads as int64.
+func convertJob(row sqlite.Job) database.Job {
+	return database.Job{
+		ID:          row.ID,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+		Kind:        row.Kind,
+		Payload:     row.Payload,
+		State:       row.State,
+		Attempts:    int32(row.Attempts),
+		MaxAttempts: int32(row.MaxAttempts),
+		RunAt:       row.RunAt,
+		LockedUntil: row.LockedUntil,
+		LastError:   row.LastError,
+		UniqueKey:   row.UniqueKey,
+	}
+}
+
+func (s sqliteQueries) ClaimJob(ctx context.Context, lockedUntil sql.NullTime) (database.Job, error) {
+	lockedUntil.Time = lockedUntil.Time.UTC()
+	row, err := s.q.ClaimJob(ctx, lockedUntil)
+	return convertJob(row), err
+}
+
+func (s sqliteQueries) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	arg.PublishAt.Time = arg.PublishAt.Time.UTC()
+	row, err := s.q.CreateChirp(ctx, sqlite.CreateChirpParams(arg))
+	return database.Chirp(row), err
+}
+
+func (s sqliteQueries) CreateJob(ctx context.Context, arg database.CreateJobParams) error {
+	return s.q.CreateJob(ctx, sqlite.CreateJobParams{
+		Kind:        arg.Kind,
+		Payload:     arg.Payload,
+		MaxAttempts: int64(arg.MaxAttempts),
+		RunAt:       arg.RunAt.UTC(),
+		UniqueKey:   arg.UniqueKey,
+	})
+}
+
+func (s sqliteQueries) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	// Stored times compare as text, so they must all be in UTC
+	arg.ExpiresAt = arg.ExpiresAt.UTC()
+	row, err := s.q.CreateRefreshToken(ctx, sqlite.CreateRefreshTokenParams(arg))
+	return database.RefreshToken(row), err
+}
+
+func (s sqliteQueries) DeleteEventsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	return s.q.DeleteEventsBefore(ctx, createdAt.UTC())
+}
+
+func (s sqliteQueries) DeleteJob(ctx context.Context, arg database.DeleteJobParams) error {
+	return s.q.DeleteJob(ctx, sqlite.DeleteJobParams{
+		ID:       arg.ID,
+		Attempts: int64(arg.Attempts),
+	})
+}
+
+func (s sqliteQueries) GetDeadJobs(ctx context.Context) ([]database.Job, error) {
+	rows, err := s.q.GetDeadJobs(ctx)
+	return convertRows(rows, convertJob), err
+}
+
+func (s sqliteQueries) GetEventsAfter(ctx context.Context, arg database.GetEventsAfterParams) ([]database.Event, error) {
+	rows, err := s.q.GetEventsAfter(ctx, sqlite.GetEventsAfterParams{
+		ID:    arg.ID,
+		Limit: int64(arg.Limit),
+	})
+	return convertRows(rows, func(row sqlite.Event) database.Event { return database.Event(row) }), err
+}
+
+func (s sqliteQueries) GetNotifications(ctx context.Context, arg database.GetNotificationsParams) ([]database.GetNotificationsRow, error) {
+	rows, err := s.q.GetNotifications(ctx, sqlite.GetNotificationsParams{
+		UserID:     arg.UserID,
+		UnreadOnly: arg.UnreadOnly,
+		BeforeTime: arg.BeforeTime.UTC(),
+		BeforeID:   arg.BeforeID,
+		MaxResults: int64(arg.MaxResults),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := make([]database.GetNotificationsRow, 0, len(rows))
+	for _, row := range rows {
+		// recent_actors comes back comma-separated
+		recent := []uuid.UUID{}
+		if row.RecentActors != "" {
+			for _, id := range strings.Split(row.RecentActors, ",") {
+				actor, err := uuid.Parse(id)
+				if err != nil {
+					return nil, err
+				}
+				recent = append(recent, actor)
+			}
+		}
+		notifications = append(notifications, database.GetNotificationsRow{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			UserID:       row.UserID,
+			Type:         row.Type,
+			SubjectID:    row.SubjectID,
+			ReadAt:       row.ReadAt,
+			ActorCount:   row.ActorCount,
+			RecentActors: recent,
+		})
+	}
+	return notifications, nil
+}
+
+func (s sqliteQueries) KillJob(ctx context.Context, arg database.KillJobParams) error {
+	return s.q.KillJob(ctx, sqlite.KillJobParams{
+		ID:        arg.ID,
+		Attempts:  int64(arg.Attempts),
+		LastError: arg.LastError,
+	})
+}
+
+func (s sqliteQueries) MarkConversationRead(ctx context.Context, arg database.MarkConversationReadParams) (int64, error) {
+	return s.q.MarkConversationRead(ctx, sqlite.MarkConversationReadParams{
+		MessageID:      arg.MessageID,
+		ConversationID: arg.ConversationID,
+		UserID:         arg.UserID,
+	})
+}
+
+func (s sqliteQueries) RequeueDeadJob(ctx context.Context, id uuid.UUID) (database.Job, error) {
+	row, err := s.q.RequeueDeadJob(ctx, id)
+	return convertJob(row), err
+}
+
+func (s sqliteQueries) RetryJob(ctx context.Context, arg database.RetryJobParams) error {
+	return s.q.RetryJob(ctx, sqlite.RetryJobParams{
+		ID:        arg.ID,
+		Attempts:  int64(arg.Attempts),
+		RunAt:     arg.RunAt.UTC(),
+		LastError: arg.LastError,
+	})
+}
+
+func (s sqliteQueries) UpdateDraft(ctx context.Context, arg database.UpdateDraftParams) (database.Chirp, error) {
+	arg.PublishAt.Time = arg.PublishAt.Time.UTC()
+	row, err := s.q.UpdateDraft(ctx, sqlite.UpdateDraftParams(arg))
+	return database.Chirp(row), err
+}
+
+func (s sqliteQueries) UpdateUserRed(ctx context.Context, arg database.UpdateUserRedParams) (database.User, error) {
+	arg.ChirpyRedExpiresAt.Time = arg.ChirpyRedExpiresAt.Time.UTC()
+	row, err := s.q.UpdateUserRed(ctx, sqlite.UpdateUserRedParams(arg))
+	return database.User(row), err
+}
+
+func (s sqliteQueries) AddConversationParticipant(ctx context.Context, arg database.AddConversationParticipantParams) error {
+	return s.q.AddConversationParticipant(ctx, sqlite.AddConversationParticipantParams(arg))
+}
+
+func (s sqliteQueries) AddNotificationActor(ctx context.Context, arg database.AddNotificationActorParams) error {
+	return s.q.AddNotificationActor(ctx, sqlite.AddNotificationActorParams(arg))
+}
+
+func (s sqliteQueries) ClearConversation(ctx context.Context, arg database.ClearConversationParams) error {
+	return s.q.ClearConversation(ctx, sqlite.ClearConversationParams(arg))
+}
+
+func (s sqliteQueries) ConversationHasBlock(ctx context.Context, arg database.ConversationHasBlockParams) (bool, error) {
+	return s.q.ConversationHasBlock(ctx, sqlite.ConversationHasBlockParams(arg))
+}
+
+func (s sqliteQueries) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.CountUnreadNotifications(ctx, userID)
+}
+
+func (s sqliteQueries) CreateBlock(ctx context.Context, arg database.CreateBlockParams) error {
+	return s.q.CreateBlock(ctx, sqlite.CreateBlockParams(arg))
+}
+
+func (s sqliteQueries) CreateConversation(ctx context.Context) (database.Conversation, error) {
+	row, err := s.q.CreateConversation(ctx)
+	return database.Conversation(row), err
+}
+
+func (s sqliteQueries) CreateEvent(ctx context.Context, arg database.CreateEventParams) (database.Event, error) {
+	row, err := s.q.CreateEvent(ctx, sqlite.CreateEventParams(arg))
+	return database.Event(row), err
+}
+
+func (s sqliteQueries) CreateMessage(ctx context.Context, arg database.CreateMessageParams) (database.Message, error) {
+	row, err := s.q.CreateMessage(ctx, sqlite.CreateMessageParams(arg))
+	return database.Message(row), err
+}
+
+func (s sqliteQueries) CreateMute(ctx context.Context, arg database.CreateMuteParams) error {
+	return s.q.CreateMute(ctx, sqlite.CreateMuteParams(arg))
+}
+
+func (s sqliteQueries) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	row, err := s.q.CreateUser(ctx, sqlite.CreateUserParams(arg))
+	return database.User(row), err
+}
+
+func (s sqliteQueries) DeleteBlock(ctx context.Context, arg database.DeleteBlockParams) (int64, error) {
+	return s.q.DeleteBlock(ctx, sqlite.DeleteBlockParams(arg))
+}
+
+func (s sqliteQueries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteChirp(ctx, id)
+}
+
+func (s sqliteQueries) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	return s.q.DeleteExpiredRefreshTokens(ctx)
+}
+
+func (s sqliteQueries) DeleteFullRateLimits(ctx context.Context, fullAt int64) (int64, error) {
+	return s.q.DeleteFullRateLimits(ctx, fullAt)
+}
+
+func (s sqliteQueries) DeleteMute(ctx context.Context, arg database.DeleteMuteParams) (int64, error) {
+	return s.q.DeleteMute(ctx, sqlite.DeleteMuteParams(arg))
+}
+
+func (s sqliteQueries) DeleteUserChirps(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return s.q.DeleteUserChirps(ctx, userID)
+}
+
+func (s sqliteQueries) ExpireChirpyRed(ctx context.Context) (int64, error) {
+	return s.q.ExpireChirpyRed(ctx)
+}
+
+func (s sqliteQueries) FindDirectConversation(ctx context.Context, arg database.FindDirectConversationParams) (database.Conversation, error) {
+	row, err := s.q.FindDirectConversation(ctx, sqlite.FindDirectConversationParams(arg))
+	return database.Conversation(row), err
+}
+
+func (s sqliteQueries) GetAuthorChirps(ctx context.Context, arg database.GetAuthorChirpsParams) ([]database.Chirp, error) {
+	rows, err := s.q.GetAuthorChirps(ctx, sqlite.GetAuthorChirpsParams(arg))
+	return convertRows(rows, func(row sqlite.Chirp) database.Chirp { return database.Chirp(row) }), err
+}
+
+func (s sqliteQueries) GetAuthorsChirps(ctx context.Context, arg database.GetAuthorsChirpsParams) ([]database.Chirp, error) {
+	rows, err := s.q.GetAuthorsChirps(ctx, sqlite.GetAuthorsChirpsParams{
+		ViewerID:    arg.ViewerID,
+		UserIds:     arg.UserIds,
+		NewestFirst: arg.NewestFirst,
+		PerAuthor:   int64(arg.PerAuthor),
+	})
+	return convertRows(rows, func(row sqlite.Chirp) database.Chirp { return database.Chirp(row) }), err
+}
+
+func (s sqliteQueries) GetBlocks(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error) {
+	rows, err := s.q.GetBlocks(ctx, blockerID)
+	return convertRows(rows, func(row sqlite.Block) database.Block { return database.Block(row) }), err
+}
+
+func (s sqliteQueries) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	row, err := s.q.GetChirp(ctx, id)
+	return database.Chirp(row), err
+}
+
+func (s sqliteQueries) GetChirps(ctx context.Context, viewerID uuid.UUID) ([]database.Chirp, error) {
+	rows, err := s.q.GetChirps(ctx, viewerID)
+	return convertRows(rows, func(row sqlite.Chirp) database.Chirp { return database.Chirp(row) }), err
+}
+
+func (s sqliteQueries) GetConversationMessages(ctx context.Context, arg database.GetConversationMessagesParams) ([]database.Message, error) {
+	rows, err := s.q.GetConversationMessages(ctx, sqlite.GetConversationMessagesParams(arg))
+	return convertRows(rows, func(row sqlite.Message) database.Message { return database.Message(row) }), err
+}
+
+func (s sqliteQueries) GetConversationParticipant(ctx context.Context, arg database.GetConversationParticipantParams) (database.ConversationParticipant, error) {
+	row, err := s.q.GetConversationParticipant(ctx, sqlite.GetConversationParticipantParams(arg))
+	return database.ConversationParticipant(row), err
+}
+
+func (s sqliteQueries) GetConversationsParticipants(ctx context.Context, conversationIds []uuid.UUID) ([]database.ConversationParticipant, error) {
+	rows, err := s.q.GetConversationsParticipants(ctx, conversationIds)
+	return convertRows(rows, func(row sqlite.ConversationParticipant) database.ConversationParticipant {
+		return database.ConversationParticipant(row)
+	}), err
+}
+
+func (s sqliteQueries) GetDrafts(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	rows, err := s.q.GetDrafts(ctx, userID)
+	return convertRows(rows, func(row sqlite.Chirp) database.Chirp { return database.Chirp(row) }), err
+}
+
+func (s sqliteQueries) GetHiddenAuthors(ctx context.Context, blockerID uuid.UUID) ([]uuid.UUID, error) {
+	return s.q.GetHiddenAuthors(ctx, blockerID)
+}
+
+func (s sqliteQueries) GetLatestEventID(ctx context.Context) (int64, error) {
+	return s.q.GetLatestEventID(ctx)
+}
+
+func (s sqliteQueries) GetMutes(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error) {
+	rows, err := s.q.GetMutes(ctx, muterID)
+	return convertRows(rows, func(row sqlite.Mute) database.Mute { return database.Mute(row) }), err
+}
+
+func (s sqliteQueries) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	rows, err := s.q.GetNotificationPreferences(ctx, userID)
+	return convertRows(rows, func(row sqlite.NotificationPreference) database.NotificationPreference {
+		return database.NotificationPreference(row)
+	}), err
+}
+
+func (s sqliteQueries) GetRateLimit(ctx context.Context, key string) (int64, error) {
+	return s.q.GetRateLimit(ctx, key)
+}
+
+func (s sqliteQueries) GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	row, err := s.q.GetRefreshToken(ctx, token)
+	return database.RefreshToken(row), err
+}
+
+func (s sqliteQueries) GetRefreshTokenFromUser(ctx context.Context, id uuid.UUID) (database.RefreshToken, error) {
+	row, err := s.q.GetRefreshTokenFromUser(ctx, id)
+	return database.RefreshToken(row), err
+}
+
+func (s sqliteQueries) GetUser(ctx context.Context, email string) (database.User, error) {
+	row, err := s.q.GetUser(ctx, email)
+	return database.User(row), err
+}
+
+func (s sqliteQueries) GetUserConversations(ctx context.Context, userID uuid.UUID) ([]database.GetUserConversationsRow, error) {
+	rows, err := s.q.GetUserConversations(ctx, userID)
+	return convertRows(rows, func(row sqlite.GetUserConversationsRow) database.GetUserConversationsRow {
+		return database.GetUserConversationsRow(row)
+	}), err
+}
+
+func (s sqliteQueries) GetUserFromID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	row, err := s.q.GetUserFromID(ctx, id)
+	return database.User(row), err
+}
+
+func (s sqliteQueries) GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error) {
+	row, err := s.q.GetUserFromRefreshToken(ctx, token)
+	return database.User(row), err
+}
+
+func (s sqliteQueries) GetUsersFromIDs(ctx context.Context, ids []uuid.UUID) ([]database.User, error) {
+	rows, err := s.q.GetUsersFromIDs(ctx, ids)
+	return convertRows(rows, func(row sqlite.User) database.User { return database.User(row) }), err
+}
+
+func (s sqliteQueries) GetVisibleChirp(ctx context.Context, arg database.GetVisibleChirpParams) (database.Chirp, error) {
+	row, err := s.q.GetVisibleChirp(ctx, sqlite.GetVisibleChirpParams(arg))
+	return database.Chirp(row), err
+}
+
+func (s sqliteQueries) IsBlocked(ctx context.Context, arg database.IsBlockedParams) (bool, error) {
+	return s.q.IsBlocked(ctx, sqlite.IsBlockedParams(arg))
+}
+
+func (s sqliteQueries) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) error {
+	return s.q.MarkAllNotificationsRead(ctx, userID)
+}
+
+func (s sqliteQueries) MarkNotificationRead(ctx context.Context, arg database.MarkNotificationReadParams) (int64, error) {
+	return s.q.MarkNotificationRead(ctx, sqlite.MarkNotificationReadParams(arg))
+}
+
+func (s sqliteQueries) NotificationEnabled(ctx context.Context, arg database.NotificationEnabledParams) (bool, error) {
+	return s.q.NotificationEnabled(ctx, sqlite.NotificationEnabledParams(arg))
+}
+
+func (s sqliteQueries) PublishDueChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	row, err := s.q.PublishDueChirp(ctx, id)
+	return database.Chirp(row), err
+}
+
+func (s sqliteQueries) ResetConversations(ctx context.Context) error {
+	return s.q.ResetConversations(ctx)
+}
+
+func (s sqliteQueries) ResetUsers(ctx context.Context) error {
+	return s.q.ResetUsers(ctx)
+}
+
+func (s sqliteQueries) RevokeRefreshToken(ctx context.Context, token string) error {
+	return s.q.RevokeRefreshToken(ctx, token)
+}
+
+func (s sqliteQueries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.RevokeUserRefreshTokens(ctx, userID)
+}
+
+func (s sqliteQueries) SetNotificationPreference(ctx context.Context, arg database.SetNotificationPreferenceParams) error {
+	return s.q.SetNotificationPreference(ctx, sqlite.SetNotificationPreferenceParams(arg))
+}
+
+func (s sqliteQueries) SetUserRed(ctx context.Context, arg database.SetUserRedParams) (database.User, error) {
+	row, err := s.q.SetUserRed(ctx, sqlite.SetUserRedParams(arg))
+	return database.User(row), err
+}
+
+func (s sqliteQueries) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error) {
+	row, err := s.q.SetUserRole(ctx, sqlite.SetUserRoleParams(arg))
+	return database.User(row), err
+}
+
+func (s sqliteQueries) TakeRateLimit(ctx context.Context, arg database.TakeRateLimitParams) (int64, error) {
+	return s.q.TakeRateLimit(ctx, sqlite.TakeRateLimitParams(arg))
+}
+
+func (s sqliteQueries) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	return s.q.TouchConversation(ctx, id)
+}
+
+func (s sqliteQueries) UpdateUserEmail(ctx context.Context, arg database.UpdateUserEmailParams) (database.User, error) {
+	row, err := s.q.UpdateUserEmail(ctx, sqlite.UpdateUserEmailParams(arg))
+	return database.User(row), err
+}
+
+func (s sqliteQueries) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	row, err := s.q.UpdateUserPassword(ctx, sqlite.UpdateUserPasswordParams(arg))
+	return database.User(row), err
+}
+
+func (s sqliteQueries) UpsertNotification(ctx context.Context, arg database.UpsertNotificationParams) (database.Notification, error) {
+	row, err := s.q.UpsertNotification(ctx, sqlite.UpsertNotificationParams(arg))
+	return database.Notification(row), err
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+)
+
+// Store is everything the handlers need from persistence: the sqlc queries
+// plus transactions. Postgres is the production implementation, SQLite is
+// there for local development and small deployments, and the memory package
+// provides one for tests that don't have a database.
+type Store interface {
+	database.Querier
+
+	// InTx runs fn inside a transaction. The transaction commits when fn
+	// returns nil and rolls back otherwise.
+	InTx(ctx context.Context, fn func(q database.Querier) error) error
+}
+
+// ErrUniqueViolation is wrapped by stores whose driver has no error of its
+// own for a write that breaks a unique constraint, such as the memory store.
+var ErrUniqueViolation = errors.New("unique constraint violation")
+
+// IsUniqueViolation reports whether err is a write breaking a unique
+// constraint, from any store, so callers can tell a conflict from a failure.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Name() == "unique_violation"
+	}
+	return isSQLiteUniqueViolation(err) || errors.Is(err, ErrUniqueViolation)
+}
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Driver picks the database driver from the scheme of dbURL: postgres:// or
+// postgresql:// for Postgres, sqlite:// for SQLite.
+func Driver(dbURL string) (string, error) {
+	scheme, _, ok := strings.Cut(dbURL, "://")
+	if !ok {
+		return "", fmt.Errorf("database URL has no scheme")
+	}
+	switch scheme {
+	case "postgres", "postgresql":
+		return DriverPostgres, nil
+	case "sqlite":
+		return DriverSQLite, nil
+	}
+	return "", fmt.Errorf("unsupported database scheme %q", scheme)
+}
+
+// Open connects to dbURL using the driver its scheme selects. SQLite URLs
+// name a file, such as sqlite://chirpy.db or sqlite:///var/lib/chirpy.db.
+func Open(dbURL string) (Store, error) {
+	driver, err := Driver(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	db, err := OpenDB(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	return New(db, driver), nil
+}
+
+// OpenDB opens the connection pool behind Open, for callers such as the
+// migrator that need the *sql.DB itself.
+func OpenDB(dbURL string) (*sql.DB, error) {
+	driver, err := Driver(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	if driver == DriverSQLite {
+		return openSQLite(strings.TrimPrefix(dbURL, "sqlite://"))
+	}
+	return sql.Open("postgres", dbURL)
+}
+
+// New wraps a pool opened by OpenDB in the Store for its driver.
+func New(db *sql.DB, driver string) Store {
+	if driver == DriverSQLite {
+		return NewSQLite(db)
+	}
+	return NewPostgres(db)
+}
+
+type postgres struct {
+	*database.Queries
+	db *sql.DB
+}
+
+func NewPostgres(db *sql.DB) Store {
+	return &postgres{
+		Queries: database.New(instrumentedDB{db, semconv.DBSystemNamePostgreSQL}),
+		db:      db,
+	}
+}
+
+func (p *postgres) InTx(ctx context.Context, fn func(q database.Querier) error) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(database.New(instrumentedDB{tx, semconv.DBSystemNamePostgreSQL}))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
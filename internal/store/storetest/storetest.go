@@ -0,0 +1,1126 @@
+// Package storetest is a conformance suite for store.Store implementations.
+// Every backend runs the same tests, so a query that behaves differently on
+// one engine fails here rather than in production.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store"
+	"github.com/google/uuid"
+)
+
+// Run runs the suite. open must return an empty, migrated store; it is called
+// once per test.
+func Run(t *testing.T, open func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"Users", testUsers},
+		{"RefreshTokens", testRefreshTokens},
+		{"Chirps", testChirps},
+		{"Drafts", testDrafts},
+		{"Blocks", testBlocks},
+		{"Conversations", testConversations},
+		{"Events", testEvents},
+		{"Notifications", testNotifications},
+		{"NotificationPreferences", testNotificationPreferences},
+		{"Jobs", testJobs},
+		{"RateLimits", testRateLimits},
+		{"Transactions", testTransactions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+// firstPage is the cursor for the newest notifications, as the handler uses.
+var firstPage = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// must returns v, failing the test if err is set. Go only spreads a
+// multi-value call into a call of its own, hence must(s.GetUser(...))(t).
+func must[T any](v T, err error) func(t *testing.T) T {
+	return func(t *testing.T) T {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return v
+	}
+}
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func wantNoRows(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("got error %v, want sql.ErrNoRows", err)
+	}
+}
+
+func createUser(t *testing.T, s store.Store, email string) database.User {
+	t.Helper()
+	return must(s.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: "hash",
+	}))(t)
+}
+
+func createChirp(t *testing.T, s store.Store, user uuid.UUID, body string) database.Chirp {
+	t.Helper()
+	return must(s.CreateChirp(context.Background(), database.CreateChirpParams{
+		Body:   body,
+		UserID: user,
+		Status: "published",
+	}))(t)
+}
+
+func chirpIDs(chirps []database.Chirp) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	return ids
+}
+
+func sameIDs(t *testing.T, got, want []uuid.UUID) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Errorf("got IDs %v, want %v", got, want)
+	}
+}
+
+func testUsers(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	user := createUser(t, s, "walt@breakingbad.com")
+	if user.ID == uuid.Nil || user.CreatedAt.IsZero() || user.IsChirpyRed || user.Role != "user" {
+		t.Errorf("unexpected new user: %+v", user)
+	}
+
+	_, err := s.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+	if !store.IsUniqueViolation(err) {
+		t.Errorf("creating two users with the same email got %v, want a unique violation", err)
+	}
+
+	byEmail := must(s.GetUser(ctx, "walt@breakingbad.com"))(t)
+	if byEmail.ID != user.ID || !byEmail.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("GetUser() = %+v, want %+v", byEmail, user)
+	}
+	_, err = s.GetUser(ctx, "jesse@breakingbad.com")
+	wantNoRows(t, err)
+	_, err = s.GetUserFromID(ctx, uuid.New())
+	wantNoRows(t, err)
+
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+	var ids []uuid.UUID
+	for _, u := range must(s.GetUsersFromIDs(ctx, []uuid.UUID{jesse.ID, uuid.New(), user.ID}))(t) {
+		ids = append(ids, u.ID)
+	}
+	slices.SortFunc(ids, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	want := []uuid.UUID{user.ID, jesse.ID}
+	slices.SortFunc(want, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	sameIDs(t, ids, want)
+	if users := must(s.GetUsersFromIDs(ctx, []uuid.UUID{}))(t); len(users) != 0 {
+		t.Errorf("GetUsersFromIDs() with no IDs returned %d rows", len(users))
+	}
+
+	updated := must(s.UpdateUserEmail(ctx, database.UpdateUserEmailParams{
+		ID:    user.ID,
+		Email: "heisenberg@breakingbad.com",
+	}))(t)
+	if updated.Email != "heisenberg@breakingbad.com" || updated.UpdatedAt.Before(user.UpdatedAt) {
+		t.Errorf("UpdateUserEmail() = %+v", updated)
+	}
+	_, err = s.UpdateUserEmail(ctx, database.UpdateUserEmailParams{ID: user.ID, Email: "jesse@breakingbad.com"})
+	if !store.IsUniqueViolation(err) {
+		t.Errorf("taking another user's email got %v, want a unique violation", err)
+	}
+
+	updated = must(s.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             user.ID,
+		HashedPassword: "new-hash",
+	}))(t)
+	if updated.HashedPassword != "new-hash" {
+		t.Errorf("got hashed password %q, want %q", updated.HashedPassword, "new-hash")
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	updated = must(s.UpdateUserRed(ctx, database.UpdateUserRedParams{
+		ID:                 user.ID,
+		ChirpyRedExpiresAt: sql.NullTime{Time: expiresAt, Valid: true},
+	}))(t)
+	if !updated.IsChirpyRed || !updated.ChirpyRedExpiresAt.Valid {
+		t.Errorf("UpdateUserRed() = %+v", updated)
+	}
+	if got := updated.ChirpyRedExpiresAt.Time; got.Sub(expiresAt).Abs() > time.Millisecond {
+		t.Errorf("got expiry %v, want %v", got, expiresAt)
+	}
+	if expired := must(s.ExpireChirpyRed(ctx))(t); expired != 0 {
+		t.Errorf("ExpireChirpyRed() expired %d live subscriptions", expired)
+	}
+	byID := must(s.GetUserFromID(ctx, user.ID))(t)
+	if !byID.IsChirpyRed || byID.Email != "heisenberg@breakingbad.com" {
+		t.Errorf("GetUserFromID() = %+v", byID)
+	}
+
+	// Only subscriptions that lapsed expire; granted ones never do
+	must(s.UpdateUserRed(ctx, database.UpdateUserRedParams{
+		ID:                 user.ID,
+		ChirpyRedExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true},
+	}))(t)
+	granted := createUser(t, s, "skyler@breakingbad.com")
+	must(s.SetUserRed(ctx, database.SetUserRedParams{ID: granted.ID, IsChirpyRed: true}))(t)
+	if expired := must(s.ExpireChirpyRed(ctx))(t); expired != 1 {
+		t.Errorf("ExpireChirpyRed() = %d, want 1", expired)
+	}
+	byID = must(s.GetUserFromID(ctx, user.ID))(t)
+	if byID.IsChirpyRed || byID.ChirpyRedExpiresAt.Valid {
+		t.Errorf("expired user = %+v", byID)
+	}
+	if !must(s.GetUserFromID(ctx, granted.ID))(t).IsChirpyRed {
+		t.Error("ExpireChirpyRed() expired a granted subscription")
+	}
+
+	updated = must(s.SetUserRed(ctx, database.SetUserRedParams{ID: granted.ID, IsChirpyRed: false}))(t)
+	if updated.IsChirpyRed {
+		t.Error("SetUserRed() didn't downgrade the user")
+	}
+	updated = must(s.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: "admin"}))(t)
+	if updated.Role != "admin" {
+		t.Errorf("got role %q, want %q", updated.Role, "admin")
+	}
+	_, err = s.SetUserRole(ctx, database.SetUserRoleParams{ID: uuid.New(), Role: "admin"})
+	wantNoRows(t, err)
+}
+
+func testRefreshTokens(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "gus@pollos.com")
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name      string
+		token     string
+		expiresAt time.Time
+		revoke    bool
+		wantValid bool
+	}{
+		{
+			name:      "Valid",
+			token:     "valid",
+			expiresAt: now.Add(time.Hour),
+			wantValid: true,
+		},
+		{
+			name:      "Expired",
+			token:     "expired",
+			expiresAt: now.Add(-time.Hour),
+		},
+		{
+			name:      "Revoked",
+			token:     "revoked",
+			expiresAt: now.Add(time.Hour),
+			revoke:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := must(s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+				Token:     tt.token,
+				UserID:    user.ID,
+				ExpiresAt: tt.expiresAt,
+			}))(t)
+			if token.RevokedAt.Valid {
+				t.Error("new token is already revoked")
+			}
+			if tt.revoke {
+				check(t, s.RevokeRefreshToken(ctx, tt.token))
+				token = must(s.GetRefreshToken(ctx, tt.token))(t)
+				if !token.RevokedAt.Valid {
+					t.Error("RevokeRefreshToken() didn't set revoked_at")
+				}
+			}
+
+			got, err := s.GetUserFromRefreshToken(ctx, tt.token)
+			if !tt.wantValid {
+				wantNoRows(t, err)
+				return
+			}
+			check(t, err)
+			if got.ID != user.ID {
+				t.Errorf("got user %v, want %v", got.ID, user.ID)
+			}
+		})
+	}
+
+	token := must(s.GetRefreshTokenFromUser(ctx, user.ID))(t)
+	if token.UserID != user.ID {
+		t.Errorf("GetRefreshTokenFromUser() returned a token for %v", token.UserID)
+	}
+	_, err := s.GetRefreshToken(ctx, "missing")
+	wantNoRows(t, err)
+
+	// The already revoked token isn't counted again, and then nothing
+	// survives a purge
+	revoked := must(s.RevokeUserRefreshTokens(ctx, user.ID))(t)
+	if revoked != 2 {
+		t.Errorf("RevokeUserRefreshTokens() = %d, want 2", revoked)
+	}
+	_, err = s.GetUserFromRefreshToken(ctx, "valid")
+	wantNoRows(t, err)
+	purged := must(s.DeleteExpiredRefreshTokens(ctx))(t)
+	if purged != int64(len(tests)) {
+		t.Errorf("DeleteExpiredRefreshTokens() = %d, want %d", purged, len(tests))
+	}
+	_, err = s.GetRefreshToken(ctx, "valid")
+	wantNoRows(t, err)
+}
+
+func testChirps(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	hank := createUser(t, s, "hank@dea.gov")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+
+	first := createChirp(t, s, walt.ID, "first")
+	second := createChirp(t, s, hank.ID, "second")
+	third := createChirp(t, s, jesse.ID, "third")
+	fourth := createChirp(t, s, walt.ID, "fourth")
+
+	got := must(s.GetChirp(ctx, first.ID))(t)
+	if got.Body != "first" || got.UserID != walt.ID || !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("GetChirp() = %+v, want %+v", got, first)
+	}
+
+	// Walt blocks Hank and Jesse mutes Walt
+	check(t, s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: walt.ID, BlockedID: hank.ID}))
+	check(t, s.CreateMute(ctx, database.CreateMuteParams{MuterID: jesse.ID, MutedID: walt.ID}))
+
+	tests := []struct {
+		name   string
+		viewer uuid.UUID
+		want   []uuid.UUID
+	}{
+		{
+			name:   "Anonymous",
+			viewer: uuid.Nil,
+			want:   []uuid.UUID{first.ID, second.ID, third.ID, fourth.ID},
+		},
+		{
+			name:   "Blocker",
+			viewer: walt.ID,
+			want:   []uuid.UUID{first.ID, third.ID, fourth.ID},
+		},
+		{
+			name:   "Blocked",
+			viewer: hank.ID,
+			want:   []uuid.UUID{second.ID, third.ID},
+		},
+		{
+			name:   "Muter",
+			viewer: jesse.ID,
+			want:   []uuid.UUID{second.ID, third.ID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sameIDs(t, chirpIDs(must(s.GetChirps(ctx, tt.viewer))(t)), tt.want)
+		})
+	}
+
+	sameIDs(t, chirpIDs(must(s.GetAuthorChirps(ctx, database.GetAuthorChirpsParams{
+		UserID:   walt.ID,
+		ViewerID: uuid.Nil,
+	}))(t)), []uuid.UUID{first.ID, fourth.ID})
+	sameIDs(t, chirpIDs(must(s.GetAuthorChirps(ctx, database.GetAuthorChirpsParams{
+		UserID:   walt.ID,
+		ViewerID: hank.ID,
+	}))(t)), []uuid.UUID{})
+	sameIDs(t, chirpIDs(must(s.GetAuthorsChirps(ctx, database.GetAuthorsChirpsParams{
+		ViewerID:  uuid.Nil,
+		UserIds:   []uuid.UUID{walt.ID, jesse.ID},
+		PerAuthor: 10,
+	}))(t)), []uuid.UUID{first.ID, third.ID, fourth.ID})
+	sameIDs(t, chirpIDs(must(s.GetAuthorsChirps(ctx, database.GetAuthorsChirpsParams{
+		ViewerID:  walt.ID,
+		UserIds:   []uuid.UUID{walt.ID, hank.ID, jesse.ID},
+		PerAuthor: 10,
+	}))(t)), []uuid.UUID{first.ID, third.ID, fourth.ID})
+	sameIDs(t, chirpIDs(must(s.GetAuthorsChirps(ctx, database.GetAuthorsChirpsParams{
+		ViewerID:  jesse.ID,
+		UserIds:   []uuid.UUID{walt.ID, hank.ID},
+		PerAuthor: 10,
+	}))(t)), []uuid.UUID{second.ID})
+	sameIDs(t, chirpIDs(must(s.GetAuthorsChirps(ctx, database.GetAuthorsChirpsParams{
+		ViewerID:  uuid.Nil,
+		UserIds:   []uuid.UUID{},
+		PerAuthor: 10,
+	}))(t)), []uuid.UUID{})
+
+	// Each author's page is cut from the oldest or the newest end, and
+	// comes back oldest first either way
+	sameIDs(t, chirpIDs(must(s.GetAuthorsChirps(ctx, database.GetAuthorsChirpsParams{
+		ViewerID:  uuid.Nil,
+		UserIds:   []uuid.UUID{walt.ID, jesse.ID},
+		PerAuthor: 1,
+	}))(t)), []uuid.UUID{first.ID, third.ID})
+	sameIDs(t, chirpIDs(must(s.GetAuthorsChirps(ctx, database.GetAuthorsChirpsParams{
+		ViewerID:    uuid.Nil,
+		UserIds:     []uuid.UUID{walt.ID, jesse.ID},
+		NewestFirst: true,
+		PerAuthor:   1,
+	}))(t)), []uuid.UUID{third.ID, fourth.ID})
+
+	// Blocks hide single chirps too, but mutes don't
+	_, err := s.GetVisibleChirp(ctx, database.GetVisibleChirpParams{ID: second.ID, ViewerID: walt.ID})
+	wantNoRows(t, err)
+	must(s.GetVisibleChirp(ctx, database.GetVisibleChirpParams{ID: first.ID, ViewerID: jesse.ID}))(t)
+
+	check(t, s.DeleteChirp(ctx, first.ID))
+	_, err = s.GetChirp(ctx, first.ID)
+	wantNoRows(t, err)
+
+	sameIDs(t, must(s.DeleteUserChirps(ctx, walt.ID))(t), []uuid.UUID{fourth.ID})
+	_, err = s.GetChirp(ctx, fourth.ID)
+	wantNoRows(t, err)
+	must(s.GetChirp(ctx, third.ID))(t)
+
+	// Deleting users cascades to their chirps
+	check(t, s.ResetUsers(ctx))
+	_, err = s.GetChirp(ctx, second.ID)
+	wantNoRows(t, err)
+}
+
+func testDrafts(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	hank := createUser(t, s, "hank@dea.gov")
+
+	published := createChirp(t, s, walt.ID, "published")
+	draft := must(s.CreateChirp(ctx, database.CreateChirpParams{
+		Body:   "draft",
+		UserID: walt.ID,
+		Status: "draft",
+	}))(t)
+	due := must(s.CreateChirp(ctx, database.CreateChirpParams{
+		Body:      "due",
+		UserID:    walt.ID,
+		Status:    "scheduled",
+		PublishAt: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+	}))(t)
+	later := must(s.CreateChirp(ctx, database.CreateChirpParams{
+		Body:      "later",
+		UserID:    walt.ID,
+		Status:    "scheduled",
+		PublishAt: sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+	}))(t)
+
+	// Unpublished chirps are only visible to their author, as drafts
+	sameIDs(t, chirpIDs(must(s.GetChirps(ctx, uuid.Nil))(t)), []uuid.UUID{published.ID})
+	sameIDs(t, chirpIDs(must(s.GetAuthorChirps(ctx, database.GetAuthorChirpsParams{
+		UserID: walt.ID,
+	}))(t)), []uuid.UUID{published.ID})
+	_, err := s.GetVisibleChirp(ctx, database.GetVisibleChirpParams{ID: draft.ID})
+	wantNoRows(t, err)
+	sameIDs(t, chirpIDs(must(s.GetDrafts(ctx, walt.ID))(t)), []uuid.UUID{draft.ID, due.ID, later.ID})
+	sameIDs(t, chirpIDs(must(s.GetDrafts(ctx, hank.ID))(t)), []uuid.UUID{})
+
+	// Only a due, scheduled chirp publishes, and only once
+	got := must(s.PublishDueChirp(ctx, due.ID))(t)
+	if got.Status != "published" || !got.CreatedAt.After(later.CreatedAt) {
+		t.Errorf("PublishDueChirp() = %+v, want published now", got)
+	}
+	for _, id := range []uuid.UUID{due.ID, later.ID, draft.ID, published.ID} {
+		_, err = s.PublishDueChirp(ctx, id)
+		wantNoRows(t, err)
+	}
+
+	// Rescheduling a draft, then publishing it outright
+	edited := must(s.UpdateDraft(ctx, database.UpdateDraftParams{
+		ID:        draft.ID,
+		Body:      "rescheduled",
+		Status:    "scheduled",
+		PublishAt: sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true},
+	}))(t)
+	if edited.Body != "rescheduled" || !edited.CreatedAt.Equal(draft.CreatedAt) {
+		t.Errorf("UpdateDraft() = %+v", edited)
+	}
+	edited = must(s.UpdateDraft(ctx, database.UpdateDraftParams{
+		ID:     draft.ID,
+		Body:   "out now",
+		Status: "published",
+	}))(t)
+	if edited.PublishAt.Valid || !edited.CreatedAt.After(got.CreatedAt) {
+		t.Errorf("UpdateDraft() = %+v, want published now", edited)
+	}
+	_, err = s.UpdateDraft(ctx, database.UpdateDraftParams{ID: draft.ID, Body: "too late", Status: "draft"})
+	wantNoRows(t, err)
+	_, err = s.PublishDueChirp(ctx, draft.ID)
+	wantNoRows(t, err)
+
+	sameIDs(t, chirpIDs(must(s.GetChirps(ctx, uuid.Nil))(t)), []uuid.UUID{published.ID, due.ID, draft.ID})
+	sameIDs(t, chirpIDs(must(s.GetDrafts(ctx, walt.ID))(t)), []uuid.UUID{later.ID})
+}
+
+func testBlocks(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	hank := createUser(t, s, "hank@dea.gov")
+	skyler := createUser(t, s, "skyler@breakingbad.com")
+	gus := createUser(t, s, "gus@pollos.com")
+
+	// Blocking twice is a no-op
+	check(t, s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: walt.ID, BlockedID: hank.ID}))
+	check(t, s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: walt.ID, BlockedID: hank.ID}))
+	check(t, s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: gus.ID, BlockedID: walt.ID}))
+	check(t, s.CreateMute(ctx, database.CreateMuteParams{MuterID: walt.ID, MutedID: skyler.ID}))
+
+	blocks := must(s.GetBlocks(ctx, walt.ID))(t)
+	if len(blocks) != 1 || blocks[0].BlockedID != hank.ID {
+		t.Errorf("GetBlocks() = %+v, want only %v", blocks, hank.ID)
+	}
+	mutes := must(s.GetMutes(ctx, walt.ID))(t)
+	if len(mutes) != 1 || mutes[0].MutedID != skyler.ID {
+		t.Errorf("GetMutes() = %+v, want only %v", mutes, skyler.ID)
+	}
+
+	tests := []struct {
+		name string
+		a, b uuid.UUID
+		want bool
+	}{
+		{"Blocker first", walt.ID, hank.ID, true},
+		{"Blocked first", hank.ID, walt.ID, true},
+		{"Mutes aren't blocks", walt.ID, skyler.ID, false},
+		{"Strangers", hank.ID, skyler.ID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := must(s.IsBlocked(ctx, database.IsBlockedParams{UserA: tt.a, UserB: tt.b}))(t)
+			if got != tt.want {
+				t.Errorf("IsBlocked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	hidden := must(s.GetHiddenAuthors(ctx, walt.ID))(t)
+	slices.SortFunc(hidden, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	want := []uuid.UUID{hank.ID, skyler.ID, gus.ID}
+	slices.SortFunc(want, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	sameIDs(t, hidden, want)
+
+	rows := must(s.DeleteBlock(ctx, database.DeleteBlockParams{BlockerID: walt.ID, BlockedID: hank.ID}))(t)
+	if rows != 1 {
+		t.Errorf("DeleteBlock() affected %d rows, want 1", rows)
+	}
+	rows = must(s.DeleteBlock(ctx, database.DeleteBlockParams{BlockerID: walt.ID, BlockedID: hank.ID}))(t)
+	if rows != 0 {
+		t.Errorf("second DeleteBlock() affected %d rows, want 0", rows)
+	}
+	rows = must(s.DeleteMute(ctx, database.DeleteMuteParams{MuterID: walt.ID, MutedID: skyler.ID}))(t)
+	if rows != 1 {
+		t.Errorf("DeleteMute() affected %d rows, want 1", rows)
+	}
+
+	err := s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: walt.ID, BlockedID: uuid.New()})
+	if err == nil {
+		t.Error("blocked a user that doesn't exist")
+	}
+}
+
+func testConversations(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+	mike := createUser(t, s, "mike@pollos.com")
+
+	newConversation := func(members ...uuid.UUID) database.Conversation {
+		t.Helper()
+		conversation := must(s.CreateConversation(ctx))(t)
+		for _, member := range members {
+			check(t, s.AddConversationParticipant(ctx, database.AddConversationParticipantParams{
+				ConversationID: conversation.ID,
+				UserID:         member,
+			}))
+		}
+		return conversation
+	}
+	direct := newConversation(walt.ID, jesse.ID)
+	group := newConversation(walt.ID, jesse.ID, mike.ID)
+
+	err := s.AddConversationParticipant(ctx, database.AddConversationParticipantParams{
+		ConversationID: direct.ID,
+		UserID:         walt.ID,
+	})
+	if err == nil {
+		t.Error("added the same participant twice")
+	}
+
+	found := must(s.FindDirectConversation(ctx, database.FindDirectConversationParams{UserA: jesse.ID, UserB: walt.ID}))(t)
+	if found.ID != direct.ID {
+		t.Errorf("FindDirectConversation() = %v, want %v", found.ID, direct.ID)
+	}
+	_, err = s.FindDirectConversation(ctx, database.FindDirectConversationParams{UserA: walt.ID, UserB: mike.ID})
+	wantNoRows(t, err)
+
+	participants := must(s.GetConversationsParticipants(ctx, []uuid.UUID{direct.ID, group.ID}))(t)
+	if len(participants) != 5 {
+		t.Errorf("GetConversationsParticipants() returned %d rows, want 5", len(participants))
+	}
+	participants = must(s.GetConversationsParticipants(ctx, []uuid.UUID{}))(t)
+	if len(participants) != 0 {
+		t.Errorf("GetConversationsParticipants() with no IDs returned %d rows", len(participants))
+	}
+
+	send := func(conversation uuid.UUID, sender uuid.UUID, body string) database.Message {
+		t.Helper()
+		message := must(s.CreateMessage(ctx, database.CreateMessageParams{
+			ConversationID: conversation,
+			SenderID:       sender,
+			Body:           body,
+		}))(t)
+		check(t, s.TouchConversation(ctx, conversation))
+		must(s.MarkConversationRead(ctx, database.MarkConversationReadParams{
+			ConversationID: conversation,
+			UserID:         sender,
+			MessageID:      message.ID,
+		}))(t)
+		return message
+	}
+	unread := func(user uuid.UUID) map[uuid.UUID]int64 {
+		t.Helper()
+		counts := map[uuid.UUID]int64{}
+		for _, row := range must(s.GetUserConversations(ctx, user))(t) {
+			counts[row.ID] = row.UnreadCount
+		}
+		return counts
+	}
+
+	cook := send(direct.ID, walt.ID, "We need to cook")
+	question := send(direct.ID, walt.ID, "Jesse?")
+	halfMeasures := send(group.ID, mike.ID, "No more half measures")
+
+	// The most recently active conversation comes first
+	conversations := must(s.GetUserConversations(ctx, jesse.ID))(t)
+	if len(conversations) != 2 || conversations[0].ID != group.ID {
+		t.Errorf("GetUserConversations() = %+v, want the group first", conversations)
+	}
+	if got := unread(jesse.ID); got[direct.ID] != 2 || got[group.ID] != 1 {
+		t.Errorf("got unread counts %v for the recipient", got)
+	}
+	if got := unread(walt.ID); got[direct.ID] != 0 {
+		t.Errorf("sender has %d unread in their own conversation", got[direct.ID])
+	}
+
+	// Reading goes up to the message named, and never back
+	markRead := func(message database.Message) int64 {
+		t.Helper()
+		return must(s.MarkConversationRead(ctx, database.MarkConversationReadParams{
+			ConversationID: direct.ID,
+			UserID:         jesse.ID,
+			MessageID:      message.ID,
+		}))(t)
+	}
+	if updated := markRead(cook); updated != 1 {
+		t.Errorf("MarkConversationRead() = %d, want 1", updated)
+	}
+	if got := unread(jesse.ID); got[direct.ID] != 1 {
+		t.Errorf("got %d unread after reading the first message, want 1", got[direct.ID])
+	}
+	markRead(question)
+	markRead(cook)
+	if got := unread(jesse.ID); got[direct.ID] != 0 {
+		t.Errorf("got %d unread after reading", got[direct.ID])
+	}
+	if updated := markRead(halfMeasures); updated != 0 {
+		t.Errorf("MarkConversationRead() with another conversation's message = %d, want 0", updated)
+	}
+	participant := must(s.GetConversationParticipant(ctx, database.GetConversationParticipantParams{
+		ConversationID: direct.ID,
+		UserID:         jesse.ID,
+	}))(t)
+	if !participant.LastReadAt.Valid || !participant.LastReadAt.Time.Equal(question.CreatedAt) {
+		t.Errorf("got last_read_at %v, want the newest message's %v", participant.LastReadAt, question.CreatedAt)
+	}
+
+	// Clearing hides the history and the conversation until something new arrives
+	check(t, s.ClearConversation(ctx, database.ClearConversationParams{ConversationID: direct.ID, UserID: jesse.ID}))
+	if _, ok := unread(jesse.ID)[direct.ID]; ok {
+		t.Error("cleared conversation is still listed")
+	}
+	messages := must(s.GetConversationMessages(ctx, database.GetConversationMessagesParams{
+		ConversationID: direct.ID,
+		UserID:         jesse.ID,
+	}))(t)
+	if len(messages) != 0 {
+		t.Errorf("got %d messages after clearing", len(messages))
+	}
+	latest := send(direct.ID, walt.ID, "Yo")
+	messages = must(s.GetConversationMessages(ctx, database.GetConversationMessagesParams{
+		ConversationID: direct.ID,
+		UserID:         jesse.ID,
+	}))(t)
+	if len(messages) != 1 || messages[0].ID != latest.ID {
+		t.Errorf("got %+v, want only the new message", messages)
+	}
+	if got := unread(jesse.ID); got[direct.ID] != 1 {
+		t.Errorf("got %d unread after a new message, want 1", got[direct.ID])
+	}
+
+	// The sender still sees the whole history, oldest first
+	messages = must(s.GetConversationMessages(ctx, database.GetConversationMessagesParams{
+		ConversationID: direct.ID,
+		UserID:         walt.ID,
+	}))(t)
+	if len(messages) != 3 || messages[2].ID != latest.ID {
+		t.Errorf("sender got %d messages, want 3 ending with the latest", len(messages))
+	}
+
+	check(t, s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: mike.ID, BlockedID: jesse.ID}))
+	tests := []struct {
+		name string
+		user uuid.UUID
+		want bool
+	}{
+		{"Blocked by a participant", jesse.ID, true},
+		{"Blocking a participant", mike.ID, true},
+		{"No blocks", walt.ID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := must(s.ConversationHasBlock(ctx, database.ConversationHasBlockParams{
+				UserID:         tt.user,
+				ConversationID: group.ID,
+			}))(t)
+			if got != tt.want {
+				t.Errorf("ConversationHasBlock() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	check(t, s.ResetConversations(ctx))
+	if got := unread(walt.ID); len(got) != 0 {
+		t.Errorf("got %d conversations after reset", len(got))
+	}
+}
+
+func testEvents(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "walt@breakingbad.com")
+
+	start := must(s.GetLatestEventID(ctx))(t)
+
+	payloads := []string{`{"n": 1}`, `{"n": 2}`, `{"n": 3}`}
+	var created []database.Event
+	for i, payload := range payloads {
+		recipient := uuid.NullUUID{}
+		if i == 1 {
+			recipient = uuid.NullUUID{UUID: user.ID, Valid: true}
+		}
+		created = append(created, must(s.CreateEvent(ctx, database.CreateEventParams{
+			Type:    "test",
+			UserID:  recipient,
+			ActorID: uuid.NullUUID{UUID: user.ID, Valid: true},
+			Payload: json.RawMessage(payload),
+		}))(t))
+	}
+	for i := 1; i < len(created); i++ {
+		if created[i].ID <= created[i-1].ID {
+			t.Fatalf("event IDs aren't increasing: %d then %d", created[i-1].ID, created[i].ID)
+		}
+	}
+
+	latest := must(s.GetLatestEventID(ctx))(t)
+	if latest != created[2].ID {
+		t.Errorf("GetLatestEventID() = %d, want %d", latest, created[2].ID)
+	}
+
+	events := must(s.GetEventsAfter(ctx, database.GetEventsAfterParams{ID: start, Limit: 2}))(t)
+	if len(events) != 2 || events[0].ID != created[0].ID || events[1].ID != created[1].ID {
+		t.Fatalf("GetEventsAfter() = %+v, want the first two events", events)
+	}
+	if events[0].UserID.Valid || !events[1].UserID.Valid || events[1].UserID.UUID != user.ID {
+		t.Errorf("got recipients %v and %v", events[0].UserID, events[1].UserID)
+	}
+	var payload, want any
+	check(t, json.Unmarshal(events[1].Payload, &payload))
+	check(t, json.Unmarshal([]byte(payloads[1]), &want))
+	if !reflect.DeepEqual(payload, want) {
+		t.Errorf("got payload %s, want %s", events[1].Payload, payloads[1])
+	}
+
+	events = must(s.GetEventsAfter(ctx, database.GetEventsAfterParams{ID: created[2].ID, Limit: 10}))(t)
+	if len(events) != 0 {
+		t.Errorf("got %d events after the latest", len(events))
+	}
+
+	if purged := must(s.DeleteEventsBefore(ctx, created[0].CreatedAt))(t); purged != 0 {
+		t.Errorf("DeleteEventsBefore() the first event = %d, want 0", purged)
+	}
+	if purged := must(s.DeleteEventsBefore(ctx, created[2].CreatedAt.Add(time.Second)))(t); purged != 3 {
+		t.Errorf("DeleteEventsBefore() after the last event = %d, want 3", purged)
+	}
+	events = must(s.GetEventsAfter(ctx, database.GetEventsAfterParams{ID: start, Limit: 10}))(t)
+	if len(events) != 0 {
+		t.Errorf("got %d events after purging them", len(events))
+	}
+	// IDs aren't reused, so clients resuming from a purged event miss nothing new
+	next := must(s.CreateEvent(ctx, database.CreateEventParams{Type: "test", Payload: json.RawMessage(`{}`)}))(t)
+	if next.ID <= created[2].ID {
+		t.Errorf("got ID %d after purging, want more than %d", next.ID, created[2].ID)
+	}
+}
+
+func testNotifications(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	var actors []uuid.UUID
+	for _, email := range []string{"jesse@breakingbad.com", "hank@dea.gov", "mike@pollos.com", "gus@pollos.com"} {
+		actors = append(actors, createUser(t, s, email).ID)
+	}
+	subject := uuid.New()
+
+	notify := func(actor uuid.UUID, subject uuid.UUID) database.Notification {
+		t.Helper()
+		notification := must(s.UpsertNotification(ctx, database.UpsertNotificationParams{
+			UserID:    walt.ID,
+			Type:      "like",
+			SubjectID: subject,
+		}))(t)
+		check(t, s.AddNotificationActor(ctx, database.AddNotificationActorParams{
+			NotificationID: notification.ID,
+			ActorID:        actor,
+		}))
+		return notification
+	}
+	list := func(unreadOnly bool, limit int32, beforeTime time.Time, beforeID uuid.UUID) []database.GetNotificationsRow {
+		t.Helper()
+		return must(s.GetNotifications(ctx, database.GetNotificationsParams{
+			UserID:     walt.ID,
+			UnreadOnly: unreadOnly,
+			BeforeTime: beforeTime,
+			BeforeID:   beforeID,
+			MaxResults: limit,
+		}))(t)
+	}
+
+	// Unread notifications on the same subject are grouped
+	grouped := notify(actors[0], subject)
+	for _, actor := range actors[1:] {
+		if got := notify(actor, subject); got.ID != grouped.ID {
+			t.Fatalf("got a second notification %v, want %v", got.ID, grouped.ID)
+		}
+	}
+	// A repeat actor moves to the front without being counted twice
+	notify(actors[0], subject)
+
+	rows := list(false, 10, firstPage, uuid.Max)
+	if len(rows) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(rows))
+	}
+	if rows[0].ActorCount != 4 {
+		t.Errorf("got actor count %d, want 4", rows[0].ActorCount)
+	}
+	sameIDs(t, rows[0].RecentActors, []uuid.UUID{actors[0], actors[3], actors[2]})
+
+	if got := must(s.CountUnreadNotifications(ctx, walt.ID))(t); got != 1 {
+		t.Errorf("got %d unread, want 1", got)
+	}
+
+	// Only the recipient can mark it read
+	affected := must(s.MarkNotificationRead(ctx, database.MarkNotificationReadParams{ID: grouped.ID, UserID: actors[0]}))(t)
+	if affected != 0 {
+		t.Errorf("another user marked the notification read")
+	}
+	affected = must(s.MarkNotificationRead(ctx, database.MarkNotificationReadParams{ID: grouped.ID, UserID: walt.ID}))(t)
+	if affected != 1 {
+		t.Errorf("MarkNotificationRead() affected %d rows, want 1", affected)
+	}
+
+	// Once read, the next event starts a new group
+	fresh := notify(actors[1], subject)
+	if fresh.ID == grouped.ID {
+		t.Error("new event joined a read notification")
+	}
+	notify(actors[2], uuid.New())
+	notify(actors[3], uuid.New())
+
+	unread := list(true, 10, firstPage, uuid.Max)
+	if len(unread) != 3 {
+		t.Errorf("got %d unread notifications, want 3", len(unread))
+	}
+
+	// Page through newest first
+	pageAll := func(limit int32) []uuid.UUID {
+		t.Helper()
+		var paged []uuid.UUID
+		beforeTime, beforeID := firstPage, uuid.Max
+		for {
+			page := list(false, limit, beforeTime, beforeID)
+			for _, row := range page {
+				paged = append(paged, row.ID)
+			}
+			if len(page) < int(limit) {
+				return paged
+			}
+			last := page[len(page)-1]
+			beforeTime, beforeID = last.UpdatedAt, last.ID
+		}
+	}
+	all := list(false, 10, firstPage, uuid.Max)
+	if len(all) != 4 {
+		t.Fatalf("got %d notifications, want 4", len(all))
+	}
+	var allIDs []uuid.UUID
+	for i, row := range all {
+		allIDs = append(allIDs, row.ID)
+		if i > 0 && row.UpdatedAt.After(all[i-1].UpdatedAt) {
+			t.Errorf("notifications aren't newest first")
+		}
+	}
+	sameIDs(t, pageAll(2), allIDs)
+
+	// NOW() is fixed for a transaction on Postgres, so notifications created
+	// together can share updated_at; the id orders them
+	check(t, s.InTx(ctx, func(q database.Querier) error {
+		for range 3 {
+			_, err := q.UpsertNotification(ctx, database.UpsertNotificationParams{
+				UserID:    walt.ID,
+				Type:      "mention",
+				SubjectID: uuid.New(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	allIDs = nil
+	for _, row := range list(false, 10, firstPage, uuid.Max) {
+		allIDs = append(allIDs, row.ID)
+	}
+	if len(allIDs) != 7 {
+		t.Fatalf("got %d notifications, want 7", len(allIDs))
+	}
+	sameIDs(t, pageAll(1), allIDs)
+
+	check(t, s.MarkAllNotificationsRead(ctx, walt.ID))
+	if got := must(s.CountUnreadNotifications(ctx, walt.ID))(t); got != 0 {
+		t.Errorf("got %d unread after marking all read", got)
+	}
+}
+
+func testNotificationPreferences(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "walt@breakingbad.com")
+
+	enabled := func(typ string) bool {
+		t.Helper()
+		return must(s.NotificationEnabled(ctx, database.NotificationEnabledParams{UserID: user.ID, Type: typ}))(t)
+	}
+	set := func(typ string, on bool) {
+		t.Helper()
+		check(t, s.SetNotificationPreference(ctx, database.SetNotificationPreferenceParams{
+			UserID:  user.ID,
+			Type:    typ,
+			Enabled: on,
+		}))
+	}
+
+	if !enabled("like") {
+		t.Error("notifications are off before any preference is set")
+	}
+	set("like", false)
+	set("message", false)
+	set("message", true)
+	if enabled("like") || !enabled("message") {
+		t.Errorf("got like=%v message=%v, want false and true", enabled("like"), enabled("message"))
+	}
+
+	preferences := must(s.GetNotificationPreferences(ctx, user.ID))(t)
+	if len(preferences) != 2 || preferences[0].Type != "like" || preferences[1].Type != "message" {
+		t.Errorf("GetNotificationPreferences() = %+v", preferences)
+	}
+}
+
+func testJobs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	lease := sql.NullTime{Time: now.Add(time.Minute), Valid: true}
+
+	enqueue := func(kind string, runAt time.Time, key string) {
+		t.Helper()
+		check(t, s.CreateJob(ctx, database.CreateJobParams{
+			Kind:        kind,
+			Payload:     json.RawMessage(`{"n":1}`),
+			MaxAttempts: 3,
+			RunAt:       runAt,
+			UniqueKey:   sql.NullString{String: key, Valid: key != ""},
+		}))
+	}
+	enqueue("later", now.Add(time.Hour), "")
+	enqueue("second", now.Add(-time.Minute), "")
+	enqueue("first", now.Add(-time.Hour), "daily@1")
+	enqueue("duplicate", now.Add(-2*time.Hour), "daily@1")
+
+	// Due jobs come out oldest first, each only once
+	first := must(s.ClaimJob(ctx, lease))(t)
+	if first.Kind != "first" || first.State != "running" || first.Attempts != 1 {
+		t.Errorf("first claim = %+v", first)
+	}
+	var payload map[string]int
+	check(t, json.Unmarshal(first.Payload, &payload))
+	if payload["n"] != 1 {
+		t.Errorf("got payload %s", first.Payload)
+	}
+	second := must(s.ClaimJob(ctx, lease))(t)
+	if second.Kind != "second" {
+		t.Errorf("second claim = %+v", second)
+	}
+	_, err := s.ClaimJob(ctx, lease)
+	wantNoRows(t, err)
+
+	// A retry runs again once its time comes
+	check(t, s.RetryJob(ctx, database.RetryJobParams{
+		ID:        second.ID,
+		Attempts:  second.Attempts,
+		RunAt:     now.Add(-time.Second),
+		LastError: sql.NullString{String: "boom", Valid: true},
+	}))
+	retried := must(s.ClaimJob(ctx, lease))(t)
+	if retried.ID != second.ID || retried.Attempts != 2 || retried.LastError.String != "boom" {
+		t.Errorf("retried claim = %+v", retried)
+	}
+
+	// A worker whose lease lapsed loses the job to the next claim, and can't
+	// finish it afterwards
+	check(t, s.RetryJob(ctx, database.RetryJobParams{ID: retried.ID, Attempts: retried.Attempts, RunAt: now.Add(-time.Second)}))
+	stale := must(s.ClaimJob(ctx, sql.NullTime{Time: now.Add(-time.Second), Valid: true}))(t)
+	reclaimed := must(s.ClaimJob(ctx, lease))(t)
+	if reclaimed.ID != stale.ID || reclaimed.Attempts != stale.Attempts+1 {
+		t.Errorf("reclaimed %+v, want job %v again", reclaimed, stale.ID)
+	}
+	check(t, s.DeleteJob(ctx, database.DeleteJobParams{ID: stale.ID, Attempts: stale.Attempts}))
+
+	check(t, s.KillJob(ctx, database.KillJobParams{
+		ID:        reclaimed.ID,
+		Attempts:  reclaimed.Attempts,
+		LastError: sql.NullString{String: "gave up", Valid: true},
+	}))
+	dead := must(s.GetDeadJobs(ctx))(t)
+	if len(dead) != 1 || dead[0].ID != reclaimed.ID || dead[0].LastError.String != "gave up" {
+		t.Fatalf("GetDeadJobs() = %+v", dead)
+	}
+	_, err = s.ClaimJob(ctx, lease)
+	wantNoRows(t, err)
+
+	requeued := must(s.RequeueDeadJob(ctx, reclaimed.ID))(t)
+	if requeued.State != "pending" || requeued.Attempts != 0 || requeued.LastError.Valid {
+		t.Errorf("RequeueDeadJob() = %+v", requeued)
+	}
+	_, err = s.RequeueDeadJob(ctx, first.ID)
+	wantNoRows(t, err)
+
+	// Deleting the job frees its unique key
+	check(t, s.DeleteJob(ctx, database.DeleteJobParams{ID: first.ID, Attempts: first.Attempts}))
+	enqueue("first again", now.Add(-time.Hour), "daily@1")
+	again := must(s.ClaimJob(ctx, lease))(t)
+	if again.Kind != "first again" {
+		t.Errorf("claimed %q, want the re-enqueued job", again.Kind)
+	}
+}
+
+func testRateLimits(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	// A bucket of three tokens, each refilled after 10 units
+	take := func(key string, now int64) (int64, error) {
+		return s.TakeRateLimit(ctx, database.TakeRateLimitParams{
+			Key:      key,
+			Now:      now,
+			Cost:     10,
+			Capacity: 30,
+		})
+	}
+
+	_, err := s.GetRateLimit(ctx, "ip:1")
+	wantNoRows(t, err)
+	for i, want := range []int64{1010, 1020, 1030} {
+		got := must(take("ip:1", 1000))(t)
+		if got != want {
+			t.Errorf("take %d: full at %d, want %d", i+1, got, want)
+		}
+	}
+	// Empty: refused, and the bucket is left as it was
+	_, err = take("ip:1", 1000)
+	wantNoRows(t, err)
+	if got := must(s.GetRateLimit(ctx, "ip:1"))(t); got != 1030 {
+		t.Errorf("refused take moved the bucket to %d", got)
+	}
+	// Other keys have their own buckets
+	must(take("ip:2", 1000))(t)
+
+	// One token back after 10 units, and a full bucket after 30
+	if got := must(take("ip:1", 1010))(t); got != 1040 {
+		t.Errorf("take after refill: full at %d, want 1040", got)
+	}
+	if got := must(take("ip:1", 2000))(t); got != 2010 {
+		t.Errorf("take after a long wait: full at %d, want 2010", got)
+	}
+
+	// Full buckets are cleaned up
+	if n := must(s.DeleteFullRateLimits(ctx, 1010))(t); n != 1 {
+		t.Errorf("deleted %d full buckets, want 1", n)
+	}
+	_, err = s.GetRateLimit(ctx, "ip:2")
+	wantNoRows(t, err)
+	must(s.GetRateLimit(ctx, "ip:1"))(t)
+}
+
+func testTransactions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	err := s.InTx(ctx, func(q database.Querier) error {
+		_, err := q.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+		if err != nil {
+			return err
+		}
+		// Writes are visible inside the transaction
+		_, err = q.GetUser(ctx, "walt@breakingbad.com")
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("InTx() error = %v, want %v", err, errAbort)
+	}
+	_, err = s.GetUser(ctx, "walt@breakingbad.com")
+	wantNoRows(t, err)
+
+	check(t, s.InTx(ctx, func(q database.Querier) error {
+		_, err := q.CreateUser(ctx, database.CreateUserParams{Email: "walt@breakingbad.com"})
+		return err
+	}))
+	must(s.GetUser(ctx, "walt@breakingbad.com"))(t)
+}
@@ -0,0 +1,116 @@
+// Package tracing sets up OpenTelemetry tracing for Chirpy and holds the
+// helpers the server and the store use to start spans.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName names Chirpy in traces, unless OTEL_SERVICE_NAME overrides it.
+const ServiceName = "chirpy"
+
+const instrumentation = "github.com/Brandon-Butterbaugh/Chirbooty.git"
+
+// propagator reads and writes W3C traceparent, tracestate and baggage
+// headers.
+var propagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// Setup installs the global tracer provider and the W3C trace context and
+// baggage propagators. OTEL_TRACES_EXPORTER picks where spans go:
+//
+//   - "none" or unset: nowhere, though trace IDs from incoming requests
+//     still reach the logs
+//   - "otlp": an OTLP collector over HTTP, configured with the standard
+//     OTEL_EXPORTER_OTLP_* variables
+//   - "console": stdout, as indented JSON, for local use
+//
+// The returned function flushes buffered spans and should run before exit.
+func Setup(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagator)
+
+	var exporter sdktrace.SpanExporter
+	name := os.Getenv("OTEL_TRACES_EXPORTER")
+	switch name {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create %s exporter: %w", name, err)
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// Merge again so OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES win
+	// over our defaults
+	res, err = resource.Merge(res, resource.Environment())
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns Chirpy's tracer from the global provider. It's looked up on
+// every call, so tests can swap the provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Extract returns ctx with the trace context and baggage header carries, so
+// spans started from it continue the caller's trace. It doesn't depend on
+// Setup having run.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Start starts a span for an internal operation, such as hashing a password.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on span, if there is one, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// SetUser records the authenticated user on the span in ctx.
+func SetUser(ctx context.Context, id string) {
+	trace.SpanFromContext(ctx).SetAttributes(semconv.UserID(id))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/jobs"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	jobPurgeRefreshTokens = "refresh_tokens.purge"
+	jobExpireChirpyRed    = "chirpy_red.expire"
+	jobPurgeEvents        = "events.purge"
+	jobPublishChirp       = "chirp.publish"
+)
+
+type publishChirpPayload struct {
+	ID uuid.UUID `json:"id"`
+}
+
+// schedulePublish enqueues the job that publishes a scheduled chirp when it's
+// due. Pass the transaction that saved the chirp, so neither exists without
+// the other.
+func schedulePublish(ctx context.Context, q database.Querier, chirp database.Chirp) error {
+	if chirp.Status != chirpScheduled {
+		return nil
+	}
+	return jobs.Enqueue(ctx, q, jobPublishChirp, publishChirpPayload{ID: chirp.ID}, chirp.PublishAt.Time)
+}
+
+// registerJobs sets up the background work every server instance shares.
+func registerJobs(runner *jobs.Runner, db database.Querier, hub *events.Hub) error {
+	// Scheduled chirps go out when they're due. Rescheduling leaves the old
+	// job behind, and it finds nothing to publish.
+	runner.Handle(jobPublishChirp, func(ctx context.Context, payload json.RawMessage) error {
+		var p publishChirpPayload
+		err := json.Unmarshal(payload, &p)
+		if err != nil {
+			return err
+		}
+		chirp, err := db.PublishDueChirp(ctx, p.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// The chirp is out, so a retry would find nothing to do; log rather
+		// than fail
+		err = hub.Publish(ctx, events.ChirpCreated, chirp.UserID, uuid.Nil, chirpResponse(chirp))
+		if err != nil {
+			logging.FromContext(ctx).Error("Couldn't publish chirp event", "err", err)
+		}
+		return nil
+	})
+
+	// Expired and revoked refresh tokens are never accepted again
+	err := runner.Cron(jobPurgeRefreshTokens, "@hourly", func(ctx context.Context, _ json.RawMessage) error {
+		purged, err := db.DeleteExpiredRefreshTokens(ctx)
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			logging.FromContext(ctx).Info("Purged dead refresh tokens", "count", purged)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Streams only resume from recent events, so older ones go
+	err = runner.Cron(jobPurgeEvents, "@hourly", func(ctx context.Context, _ json.RawMessage) error {
+		purged, err := hub.Purge(ctx)
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			logging.FromContext(ctx).Info("Purged old events", "count", purged)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Red lapses when Polka stops sending renewals
+	return runner.Cron(jobExpireChirpyRed, "*/10 * * * *", func(ctx context.Context, _ json.RawMessage) error {
+		expired, err := db.ExpireChirpyRed(ctx)
+		if err != nil {
+			return err
+		}
+		if expired > 0 {
+			logging.FromContext(ctx).Info("Expired Chirpy Red", "users", expired)
+		}
+		return nil
+	})
+}
@@ -2,30 +2,163 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
 )
 
-func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
-	if err != nil {
-		log.Println(err)
+// apiError is a kind of failure, with the status it's served with. Clients
+// branch on the code, so a code never changes once it has shipped; the
+// detail in each response is for people and may.
+type apiError struct {
+	status int
+	code   string
+	title  string
+}
+
+var (
+	errMalformedBody    = apiError{http.StatusBadRequest, "malformed_body", "Request body isn't valid JSON"}
+	errInvalidID        = apiError{http.StatusBadRequest, "invalid_id", "Invalid ID"}
+	errInvalidParameter = apiError{http.StatusBadRequest, "invalid_parameter", "Invalid query parameter"}
+	errValidation       = apiError{http.StatusBadRequest, "validation_failed", "Request failed validation"}
+	errUnauthorized     = apiError{http.StatusUnauthorized, "unauthorized", "Authentication required"}
+	errBadCredentials   = apiError{http.StatusUnauthorized, "invalid_credentials", "Incorrect email or password"}
+	errForbidden        = apiError{http.StatusForbidden, "forbidden", "Not allowed"}
+	errNotFound         = apiError{http.StatusNotFound, "not_found", "Not found"}
+	errConflict         = apiError{http.StatusConflict, "conflict", "Conflicts with the current state"}
+	errRateLimited      = apiError{http.StatusTooManyRequests, "rate_limited", "Too many requests"}
+	errInternal         = apiError{http.StatusInternalServerError, "internal_error", "Internal server error"}
+)
+
+// problemTypePrefix namespaces problem types. They identify the error and
+// aren't meant to be fetched.
+const problemTypePrefix = "urn:chirpy:problem:"
+
+// problem is an RFC 9457 problem details response.
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
+}
+
+// fieldError is one thing wrong with the request body. Pointer is an RFC
+// 6901 JSON Pointer to the offending value.
+type fieldError struct {
+	Pointer string `json:"pointer"`
+	Code    string `json:"code"`
+	Detail  string `json:"detail"`
+}
+
+// requestError is why a request failed, returned by the logic the HTTP and
+// gRPC APIs share so that each can report it its own way.
+type requestError struct {
+	kind   apiError
+	detail string
+	// err is logged, never sent
+	err    error
+	fields []fieldError
+}
+
+func (e *requestError) Error() string {
+	if e.err != nil {
+		return e.detail + ": " + e.err.Error()
+	}
+	return e.detail
+}
+
+func (e *requestError) Unwrap() error { return e.err }
+
+func failure(kind apiError, detail string, err error) error {
+	return &requestError{kind: kind, detail: detail, err: err}
+}
+
+// invalid rejects a request's parameters, saying what's wrong with each.
+func invalid(errs ...fieldError) error {
+	details := make([]string, len(errs))
+	for i, e := range errs {
+		details[i] = e.Detail
+	}
+	return &requestError{kind: errValidation, detail: strings.Join(details, "; "), fields: errs}
+}
+
+// respondWithFailure writes an error from the shared logic as problem+json.
+// Anything but a *requestError is a 500.
+func respondWithFailure(w http.ResponseWriter, r *http.Request, err error) {
+	var reqErr *requestError
+	if !errors.As(err, &reqErr) {
+		respondWithError(w, r, errInternal, "Unexpected error", err)
+		return
 	}
-	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
+	if reqErr.fields != nil {
+		respondWithInvalid(w, r, reqErr.fields...)
+		return
+	}
+	respondWithError(w, r, reqErr.kind, reqErr.detail, reqErr.err)
+}
+
+// respondWithError writes kind as problem+json. err is logged, never sent.
+func respondWithError(w http.ResponseWriter, r *http.Request, kind apiError, detail string, err error) {
+	logger := logging.FromContext(r.Context())
+	if kind.status > 499 {
+		logger.Error("Responding with 5XX error", "msg", detail, "err", err)
+	} else if err != nil {
+		logger.Info(detail, "err", err)
 	}
-	type errorResponse struct {
-		Error string `json:"error"`
+	respondWithProblem(w, r, kind, detail, nil)
+}
+
+// respondWithInvalid rejects a request body, saying what's wrong with each
+// field.
+func respondWithInvalid(w http.ResponseWriter, r *http.Request, errs ...fieldError) {
+	details := make([]string, len(errs))
+	for i, e := range errs {
+		details[i] = e.Detail
 	}
-	respondWithJSON(w, code, errorResponse{
-		Error: msg,
+	respondWithProblem(w, r, errValidation, strings.Join(details, "; "), errs)
+}
+
+func respondWithProblem(w http.ResponseWriter, r *http.Request, kind apiError, detail string, errs []fieldError) {
+	writeJSON(w, kind.status, "application/problem+json", problem{
+		Type:     problemTypePrefix + kind.code,
+		Title:    kind.title,
+		Status:   kind.status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     kind.code,
+		// logRequests has set it on the response by now
+		RequestID: w.Header().Get(requestIDHeader),
+		Errors:    errs,
 	})
 }
 
+// pointer builds a JSON Pointer from unescaped reference tokens.
+func pointer(tokens ...string) string {
+	var b strings.Builder
+	escape := strings.NewReplacer("~", "~0", "/", "~1")
+	for _, token := range tokens {
+		b.WriteByte('/')
+		escape.WriteString(&b, token)
+	}
+	return b.String()
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, code, "application/json", payload)
+}
+
+func writeJSON(w http.ResponseWriter, code int, contentType string, payload any) {
+	w.Header().Set("Content-Type", contentType)
 	dat, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
+		slog.Error("Error marshalling JSON", "err", err)
 		w.WriteHeader(500)
 		return
 	}
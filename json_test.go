@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProblemDetails(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signUp("walt@breakingbad.com")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+		wantErrors []fieldError
+	}{
+		{
+			name:       "Malformed body",
+			method:     "POST",
+			path:       "/api/chirps",
+			body:       `{"body": `,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "malformed_body",
+		},
+		{
+			name:       "Invalid ID",
+			method:     "GET",
+			path:       "/api/chirps/not-a-uuid",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_id",
+		},
+		{
+			name:       "Invalid field",
+			method:     "POST",
+			path:       "/api/chirps",
+			body:       `{"body": "` + strings.Repeat("a", maxBodyLength+1) + `"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_failed",
+			wantErrors: []fieldError{{Pointer: "/body", Code: "too_long", Detail: "body must be at most 140 characters"}},
+		},
+		{
+			name:       "Not found",
+			method:     "GET",
+			path:       "/api/chirps/3311741c-680c-4546-99f3-fc9efac2036c",
+			wantStatus: http.StatusNotFound,
+			wantCode:   "not_found",
+		},
+		{
+			name:       "Wrong password",
+			method:     "POST",
+			path:       "/api/login",
+			body:       `{"email": "walt@breakingbad.com", "password": "wrong"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "invalid_credentials",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+user.Token)
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("got Content-Type %q, want application/problem+json", got)
+			}
+			got := expect[problem](t, resp, tt.wantStatus)
+			if got.Code != tt.wantCode || got.Type != problemTypePrefix+tt.wantCode {
+				t.Errorf("got code %q and type %q, want %q", got.Code, got.Type, tt.wantCode)
+			}
+			if got.Status != tt.wantStatus || got.Instance != tt.path {
+				t.Errorf("got status %d and instance %q, want %d and %q", got.Status, got.Instance, tt.wantStatus, tt.path)
+			}
+			if got.RequestID == "" || got.RequestID != resp.Header.Get(requestIDHeader) {
+				t.Errorf("got request ID %q, want %q", got.RequestID, resp.Header.Get(requestIDHeader))
+			}
+			if !reflect.DeepEqual(got.Errors, tt.wantErrors) {
+				t.Errorf("got errors %+v, want %+v", got.Errors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestPointer(t *testing.T) {
+	tests := []struct {
+		tokens []string
+		want   string
+	}{
+		{tokens: nil, want: ""},
+		{tokens: []string{"participant_ids", "0"}, want: "/participant_ids/0"},
+		{tokens: []string{"a/b", "m~n"}, want: "/a~1b/m~0n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := pointer(tt.tokens...); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,94 +1,383 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
-	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/config"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/jobs"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/metrics"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/notifications"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/ratelimit"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/tracing"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
+	database      store.Store
+	events        *events.Hub
+	notifications *notifications.Service
+	metrics       *metrics.Metrics
+	platform      string
+	secret        string
+	polkaKey      string
+
+	// fileserverHits counts visits to the web app since the last reset, for
+	// the admin dashboard; the Prometheus counter never goes down
 	fileserverHits atomic.Int32
-	database       *database.Queries
-	platform       string
-	secret         string
-	polkaKey       string
+
+	// readyChecks must all pass for /readyz to report ready
+	readyChecks []readyCheck
+	// shuttingDown fails /readyz once we've been told to stop, so load
+	// balancers move traffic elsewhere while requests in flight finish
+	shuttingDown atomic.Bool
+
+	// limiter rate limits writes; nil turns rate limiting off
+	limiter *ratelimit.Limiter
+	// trustForwardedFor takes client IPs from X-Forwarded-For, for when
+	// we're behind a proxy
+	trustForwardedFor bool
+	// chirpyRedPeriod is how long a Polka upgrade lasts; zero never lapses
+	chirpyRedPeriod time.Duration
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, resp *http.Request) {
 		cfg.fileserverHits.Add(1)
+		cfg.metrics.FileserverHits.Inc()
 		next.ServeHTTP(w, resp)
 	})
 
 }
 
+// routeMux is a ServeMux that remembers its patterns, so tests can check
+// them against the OpenAPI document.
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.Handle(pattern, handler)
+}
+
+func (m *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// routes registers every handler. It's separate from main so tests can serve
+// the same mux.
+func (cfg *apiConfig) routes(filepathRoot string) http.Handler {
+	return cfg.logRequests(cfg.mux(filepathRoot))
+}
+
+// mux routes requests to handlers. Every route but /app/ belongs in
+// openapi.json. Routes under /api are versioned; see versions.go.
+func (cfg *apiConfig) mux(filepathRoot string) *routeMux {
+	mux := &routeMux{ServeMux: http.NewServeMux()}
+	handler := http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))
+
+	mux.Handle("/app/", cfg.middlewareMetricsInc(handler))
+	mux.handleAPIFunc("GET /api/healthz", health)
+	mux.HandleFunc("GET /livez", health)
+	mux.HandleFunc("GET /readyz", cfg.ready)
+	mux.HandleFunc("GET /admin/metrics", cfg.Metrics)
+	mux.Handle("GET /metrics", cfg.metrics.Handler())
+	mux.HandleFunc("POST /admin/reset", cfg.Reset)
+	mux.handleRedesigned("POST /api/users",
+		cfg.deprecated(cfg.rateLimit(signupPolicy, cfg.newUser)),
+		cfg.rateLimit(signupPolicy, cfg.newUserV2))
+	mux.handleRedesigned("PUT /api/users",
+		cfg.deprecated(http.HandlerFunc(cfg.updateUser)),
+		http.HandlerFunc(cfg.updateUserV2))
+	mux.handleAPI("POST /api/chirps", cfg.rateLimit(chirpPolicy, cfg.newChirp))
+	mux.handleAPIFunc("GET /api/chirps", cfg.getChirps)
+	mux.handleAPIFunc("GET /api/chirps/{chirpID}", cfg.getChirp)
+	mux.handleAPI("PUT /api/chirps/{chirpID}", cfg.rateLimit(chirpPolicy, cfg.updateDraft))
+	mux.handleAPIFunc("DELETE /api/chirps/{chirpID}", cfg.deleteChirp)
+	mux.handleAPIFunc("GET /api/drafts", cfg.getDrafts)
+	mux.handleRedesigned("POST /api/login",
+		cfg.deprecated(cfg.rateLimit(loginPolicy, cfg.login)),
+		cfg.rateLimit(loginPolicy, cfg.loginV2))
+	mux.handleAPIFunc("POST /api/refresh", cfg.refresh)
+	mux.handleAPIFunc("POST /api/revoke", cfg.revoke)
+	mux.handleAPIFunc("POST /api/polka/webhooks", cfg.upgrade)
+	mux.handleAPIFunc("POST /api/blocks", cfg.blockUser)
+	mux.handleAPIFunc("GET /api/blocks", cfg.getBlocks)
+	mux.handleAPIFunc("DELETE /api/blocks/{userID}", cfg.unblockUser)
+	mux.handleAPIFunc("POST /api/mutes", cfg.muteUser)
+	mux.handleAPIFunc("GET /api/mutes", cfg.getMutes)
+	mux.handleAPIFunc("DELETE /api/mutes/{userID}", cfg.unmuteUser)
+	mux.handleAPI("POST /api/conversations", cfg.rateLimit(messagePolicy, cfg.createConversation))
+	mux.handleAPIFunc("GET /api/conversations", cfg.getConversations)
+	mux.handleAPIFunc("DELETE /api/conversations/{conversationID}", cfg.deleteConversation)
+	mux.handleAPIFunc("GET /api/conversations/{conversationID}/messages", cfg.getMessages)
+	mux.handleAPI("POST /api/conversations/{conversationID}/messages", cfg.rateLimit(messagePolicy, cfg.sendMessage))
+	mux.handleAPIFunc("POST /api/conversations/{conversationID}/read", cfg.markConversationRead)
+	mux.handleAPIFunc("GET /api/notifications", cfg.getNotifications)
+	mux.handleAPIFunc("POST /api/notifications/read", cfg.markAllNotificationsRead)
+	mux.handleAPIFunc("POST /api/notifications/{notificationID}/read", cfg.markNotificationRead)
+	mux.handleAPIFunc("GET /api/notifications/preferences", cfg.getNotificationPreferences)
+	mux.handleAPIFunc("PUT /api/notifications/preferences", cfg.updateNotificationPreferences)
+	mux.handleAPIFunc("GET /api/stream", cfg.stream)
+	mux.handleAPIFunc("GET /api/ws", cfg.websocket)
+	mux.handleAPIFunc("POST /api/graphql", cfg.graphql)
+	mux.handleAPIFunc("GET /api/openapi.json", serveOpenAPI)
+
+	return mux
+}
+
+// newLogger writes JSON lines to stderr. Debug includes every database
+// query.
+func newLogger(level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+}
+
+// fatal logs and exits, for errors that leave nothing to serve.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// startupPingTimeout bounds the first database ping, so a server pointed at
+// an unreachable database fails fast instead of hanging.
+const startupPingTimeout = 10 * time.Second
+
+// rateLimitPruneInterval is how often buckets that have filled up again are
+// deleted.
+const rateLimitPruneInterval = 10 * time.Minute
+
 func main() {
 	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		log.Fatal("DB_URL must be set")
+	conf, err := config.Load(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
-		fmt.Printf("Error writing response: %v\n", err)
+		fatal("Invalid configuration", "err", err)
 	}
-	dbQueries := database.New(db)
+	logger := newLogger(conf.LogLevel)
+	// The log package writes through it too, so nothing logs plain text
+	slog.SetDefault(logger)
+	slog.Debug("Loaded configuration", "config", conf)
 
-	platform := os.Getenv("PLATFORM")
-	if platform == "" {
-		log.Fatal("PLATFORM must be set")
+	driver, err := store.Driver(conf.DBURL)
+	if err != nil {
+		fatal("Invalid DB_URL", "err", err)
+	}
+	db, err := store.OpenDB(conf.DBURL)
+	if err != nil {
+		fatal("Couldn't open database", "err", err)
+	}
+	// sql.Open doesn't connect, so without this a bad DB_URL or a database
+	// that's down would only show up on the first request
+	pingCtx, cancel := context.WithTimeout(context.Background(), startupPingTimeout)
+	err = db.PingContext(pingCtx)
+	cancel()
+	if err != nil {
+		fatal("Couldn't connect to database", "err", err)
+	}
+	migrator, err := newMigrator(db, driver)
+	if err != nil {
+		fatal("Couldn't load migrations", "err", err)
 	}
 
-	secret := os.Getenv("SECRET")
-	if secret == "" {
-		log.Fatal("SECRET must be set")
+	if len(conf.Args) > 0 {
+		switch conf.Args[0] {
+		case "migrate":
+			err = runMigrate(context.Background(), migrator, conf.Args[1:], os.Stdout)
+		case "admin":
+			// Never touch a database this build doesn't understand
+			err = migrator.Check(context.Background())
+			if err != nil {
+				fatal("Database schema doesn't match this build", "err", err)
+			}
+			dbStore := store.New(db, driver)
+			admin := &adminCLI{
+				store:  dbStore,
+				events: events.NewHub(dbStore),
+				in:     os.Stdin,
+				out:    os.Stdout,
+				errOut: os.Stderr,
+			}
+			err = admin.run(context.Background(), conf.Args[1:])
+		default:
+			err = fmt.Errorf("unknown command %q", conf.Args[0])
+		}
+		if err != nil {
+			fatal("Command failed", "err", err)
+		}
+		return
 	}
 
-	pKey := os.Getenv("POLKA_KEY")
-	if pKey == "" {
-		log.Fatal("POLKA_KEY must be set")
+	// Serving against the wrong schema fails in confusing ways on the first
+	// request that touches a changed table, so check before listening
+	if conf.MigrateOnStart {
+		_, err = migrator.Up(context.Background())
+		if err != nil {
+			fatal("Couldn't migrate database", "err", err)
+		}
+	} else {
+		err = migrator.Check(context.Background())
+		if err != nil {
+			fatal("Database schema doesn't match this build; run `chirpy migrate up` or set MIGRATE_ON_START=true", "err", err)
+		}
 	}
 
-	const filepathRoot = "."
-	const port = "8080"
-	mux := http.NewServeMux()
+	dbStore := store.New(db, driver)
+	hub := events.NewHub(dbStore)
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		fatal("Couldn't configure tracing", "err", err)
+	}
+	m := metrics.New()
+	m.RegisterDB(db)
+	var limiter *ratelimit.Limiter
+	switch conf.RateLimit {
+	case config.RateLimitMemory:
+		limiter = ratelimit.New(ratelimit.NewMemoryStore())
+	case config.RateLimitDatabase:
+		// Shared by every instance, so limits hold however requests are
+		// balanced between them
+		limiter = ratelimit.New(dbStore)
+	}
+	cfg := &apiConfig{
+		database:      dbStore,
+		events:        hub,
+		notifications: notifications.NewService(dbStore, hub),
+		metrics:       m,
+		platform:      conf.Platform,
+		secret:        conf.Secret,
+		polkaKey:      conf.PolkaKey,
+		readyChecks: []readyCheck{
+			{name: "database", check: db.PingContext},
+			{name: "migrations", check: migrator.Check},
+		},
+		limiter:           limiter,
+		trustForwardedFor: conf.TrustForwardedFor,
+		chirpyRedPeriod:   conf.ChirpyRedPeriod,
+	}
 	serv := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		Addr:              ":" + strconv.Itoa(conf.Port),
+		Handler:           cfg.routes(conf.FileRoot),
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		ReadTimeout:       conf.ReadTimeout,
+		WriteTimeout:      conf.WriteTimeout,
+		IdleTimeout:       conf.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+	runner := jobs.NewRunner(dbStore)
+	err = registerJobs(runner, dbStore, hub)
+	if err != nil {
+		fatal("Couldn't register jobs", "err", err)
 	}
 
-	cfg := &apiConfig{
-		fileserverHits: atomic.Int32{},
-		database:       dbQueries,
-		platform:       platform,
-		secret:         secret,
-		polkaKey:       pKey,
+	// The hub and the job runner stop separately: the hub first, since open
+	// event streams would otherwise hold up the server's shutdown, and the
+	// runner last, since requests in flight may still enqueue jobs
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	runnerCtx, stopRunner := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Go(func() { runner.Run(runnerCtx) })
+	if limiter != nil {
+		workers.Go(func() { limiter.Run(runnerCtx, rateLimitPruneInterval) })
 	}
-	handler := http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))
+	workers.Go(func() {
+		var err error
+		if driver == store.DriverSQLite {
+			err = hub.Poll(hubCtx, time.Second)
+		} else {
+			err = hub.Run(hubCtx, conf.DBURL)
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.Error("Event hub stopped", "err", err)
+		}
+	})
 
-	mux.Handle("/app/", cfg.middlewareMetricsInc(handler))
-	mux.HandleFunc("GET /api/healthz", health)
-	mux.HandleFunc("GET /admin/metrics", cfg.Metrics)
-	mux.HandleFunc("POST /admin/reset", cfg.Reset)
-	mux.HandleFunc("POST /api/users", cfg.newUser)
-	mux.HandleFunc("PUT /api/users", cfg.updateUser)
-	mux.HandleFunc("POST /api/chirps", cfg.newChirp)
-	mux.HandleFunc("GET /api/chirps", cfg.getChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.getChirp)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.deleteChirp)
-	mux.HandleFunc("POST /api/login", cfg.login)
-	mux.HandleFunc("POST /api/refresh", cfg.refresh)
-	mux.HandleFunc("POST /api/revoke", cfg.revoke)
-	mux.HandleFunc("POST /api/polka/webhooks", cfg.upgrade)
-
-	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
-	log.Fatal(serv.ListenAndServe())
+	// The gRPC API shares the handlers' logic but not their server, whose
+	// write timeout would cut WatchChirps streams off
+	grpcListener, err := net.Listen("tcp", ":"+strconv.Itoa(conf.GRPCPort))
+	if err != nil {
+		fatal("Couldn't listen for gRPC", "err", err)
+	}
+	grpcServ := cfg.grpcServer()
+
+	stopped := make(chan error, 2)
+	go func() {
+		slog.Info("Serving", "files", conf.FileRoot, "port", conf.Port)
+		stopped <- serv.ListenAndServe()
+	}()
+	go func() {
+		slog.Info("Serving gRPC", "port", conf.GRPCPort)
+		stopped <- grpcServ.Serve(grpcListener)
+	}()
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	select {
+	case err = <-stopped:
+		// Send whatever spans are still buffered before exiting
+		shutdownTracing(context.Background())
+		fatal("Server stopped", "err", err)
+	case <-signals.Done():
+	}
+	// A second signal kills us straight away
+	stopSignals()
+
+	slog.Info("Shutting down", "timeout", conf.ShutdownTimeout)
+	cfg.shuttingDown.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
 
+	stopHub()
+	err = serv.Shutdown(ctx)
+	if err != nil {
+		slog.Error("Requests still in flight at shutdown", "err", err)
+	}
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServ.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		slog.Error("RPCs still in flight at shutdown")
+		grpcServ.Stop()
+	}
+	stopRunner()
+	drained := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		// Their leases lapse and another instance retries them
+		slog.Error("Jobs still running at shutdown")
+	}
+
+	err = shutdownTracing(ctx)
+	if err != nil {
+		slog.Error("Couldn't flush traces", "err", err)
+	}
+	db.Close()
+	slog.Info("Stopped")
 }
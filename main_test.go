@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/metrics"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/notifications"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store/memory"
+)
+
+const (
+	testSecret   = "test-secret"
+	testPolkaKey = "test-polka-key"
+	testPassword = "correctPassword123!"
+)
+
+// testServer serves the real routes over an in-memory store.
+type testServer struct {
+	*httptest.Server
+	t     *testing.T
+	store *memory.Store
+	cfg   *apiConfig
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	s := memory.New()
+	hub := events.NewHub(s)
+	cfg := &apiConfig{
+		database:      s,
+		events:        hub,
+		notifications: notifications.NewService(s, hub),
+		metrics:       metrics.New(),
+		platform:      "dev",
+		secret:        testSecret,
+		polkaKey:      testPolkaKey,
+	}
+	srv := httptest.NewServer(cfg.routes("."))
+	t.Cleanup(srv.Close)
+	return &testServer{Server: srv, t: t, store: s, cfg: cfg}
+}
+
+// do sends a request with an optional bearer token and JSON body.
+func (ts *testServer) do(method, path, token string, body any) *http.Response {
+	ts.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		reader = jsonBody(ts.t, body)
+	}
+	req, err := http.NewRequest(method, ts.URL+path, reader)
+	if err != nil {
+		ts.t.Fatalf("Couldn't create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		ts.t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	ts.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func jsonBody(t *testing.T, body any) io.Reader {
+	t.Helper()
+	dat, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Couldn't marshal body: %v", err)
+	}
+	return bytes.NewReader(dat)
+}
+
+// expect checks the status code and decodes the body into T.
+func expect[T any](t *testing.T, resp *http.Response, code int) T {
+	t.Helper()
+	var out T
+	if resp.StatusCode != code {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("%s %s: got status %d, want %d: %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, code, body)
+	}
+	if code == http.StatusNoContent {
+		return out
+	}
+	err := json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		t.Fatalf("Couldn't decode response: %v", err)
+	}
+	return out
+}
+
+// signUp creates a user and logs them in, returning the login response.
+func (ts *testServer) signUp(email string) User {
+	ts.t.Helper()
+	credentials := map[string]string{"email": email, "password": testPassword}
+	expect[User](ts.t, ts.do("POST", "/api/users", "", credentials), http.StatusCreated)
+	return expect[User](ts.t, ts.do("POST", "/api/login", "", credentials), http.StatusOK)
+}
+
+func (ts *testServer) chirp(token, body string) Chirp {
+	ts.t.Helper()
+	return expect[Chirp](ts.t, ts.do("POST", "/api/chirps", token, map[string]string{"body": body}), http.StatusCreated)
+}
+
+func TestHealthz(t *testing.T) {
+	ts := newTestServer(t)
+	for _, path := range []string{"/api/healthz", "/livez"} {
+		resp := ts.do("GET", path, "", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s got status %d, want %d", path, resp.StatusCode, http.StatusOK)
+		}
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	type response struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	ok := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name         string
+		checks       []readyCheck
+		shuttingDown bool
+		wantCode     int
+		want         response
+	}{
+		{
+			name:     "Ready",
+			checks:   []readyCheck{{"database", ok}, {"migrations", ok}},
+			wantCode: http.StatusOK,
+			want:     response{Status: "ok", Checks: map[string]string{"database": "ok", "migrations": "ok"}},
+		},
+		{
+			name:     "Database down",
+			checks:   []readyCheck{{"database", down}, {"migrations", ok}},
+			wantCode: http.StatusServiceUnavailable,
+			want:     response{Status: "unavailable", Checks: map[string]string{"database": "failed", "migrations": "ok"}},
+		},
+		{
+			name:         "Shutting down",
+			checks:       []readyCheck{{"database", ok}},
+			shuttingDown: true,
+			wantCode:     http.StatusServiceUnavailable,
+			want:         response{Status: "shutting down", Checks: map[string]string{"database": "ok"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			ts.cfg.readyChecks = tt.checks
+			ts.cfg.shuttingDown.Store(tt.shuttingDown)
+
+			got := expect[response](t, ts.do("GET", "/readyz", "", nil), tt.wantCode)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
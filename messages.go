@@ -0,0 +1,403 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/notifications"
+	"github.com/google/uuid"
+)
+
+// maxConversationSize caps group conversations, including the creator.
+const maxConversationSize = 8
+
+type Conversation struct {
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Participants []Participant `json:"participants"`
+	UnreadCount  int64         `json:"unread_count"`
+}
+
+// Participant carries the read receipt for one member of a conversation.
+type Participant struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+}
+
+func (cfg *apiConfig) createConversation(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		ParticipantIDs []string `json:"participant_ids" validate:"required,uuid"`
+	}
+	params, ok := decode[parameters](w, r)
+	if !ok {
+		return
+	}
+
+	// Collect the other participants, dropping duplicates and the caller
+	seen := map[uuid.UUID]bool{id: true}
+	members := []uuid.UUID{id}
+	for _, raw := range params.ParticipantIDs {
+		other := uuid.MustParse(raw)
+		if seen[other] {
+			continue
+		}
+		seen[other] = true
+		members = append(members, other)
+	}
+	if len(members) < 2 {
+		respondWithInvalid(w, r, fieldError{Pointer: "/participant_ids", Code: "too_few", Detail: "Conversation needs another participant"})
+		return
+	}
+	if len(members) > maxConversationSize {
+		respondWithInvalid(w, r, fieldError{Pointer: "/participant_ids", Code: "too_many", Detail: fmt.Sprintf("Conversation can't have more than %d participants", maxConversationSize)})
+		return
+	}
+
+	// Every participant must exist and have no block with the caller
+	for _, other := range members[1:] {
+		_, err := cfg.database.GetUserFromID(r.Context(), other)
+		if err != nil {
+			respondWithError(w, r, errNotFound, "Couldn't find user", err)
+			return
+		}
+		blocked, err := cfg.database.IsBlocked(r.Context(), database.IsBlockedParams{
+			UserA: id,
+			UserB: other,
+		})
+		if err != nil {
+			respondWithError(w, r, errInternal, "Couldn't check blocks", err)
+			return
+		}
+		if blocked {
+			respondWithError(w, r, errForbidden, "Can't message this user", nil)
+			return
+		}
+	}
+
+	// One-to-one conversations are reused rather than duplicated
+	if len(members) == 2 {
+		existing, err := cfg.database.FindDirectConversation(r.Context(), database.FindDirectConversationParams{
+			UserA: members[0],
+			UserB: members[1],
+		})
+		if err == nil {
+			cfg.respondWithConversation(w, r, existing, http.StatusOK)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, errInternal, "Couldn't find conversation", err)
+			return
+		}
+	}
+
+	var conversation database.Conversation
+	err := cfg.database.InTx(r.Context(), func(q database.Querier) error {
+		var err error
+		conversation, err = q.CreateConversation(r.Context())
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			err = q.AddConversationParticipant(r.Context(), database.AddConversationParticipantParams{
+				ConversationID: conversation.ID,
+				UserID:         member,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't create conversation", err)
+		return
+	}
+
+	cfg.respondWithConversation(w, r, conversation, http.StatusCreated)
+}
+
+func (cfg *apiConfig) getConversations(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	conversations, err := cfg.database.GetUserConversations(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't get conversations", err)
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(conversations))
+	for _, conversation := range conversations {
+		ids = append(ids, conversation.ID)
+	}
+	participants, err := cfg.getParticipants(r, ids)
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't get participants", err)
+		return
+	}
+
+	respBody := []Conversation{}
+	for _, conversation := range conversations {
+		respBody = append(respBody, Conversation{
+			ID:           conversation.ID,
+			CreatedAt:    conversation.CreatedAt,
+			UpdatedAt:    conversation.UpdatedAt,
+			Participants: participants[conversation.ID],
+			UnreadCount:  conversation.UnreadCount,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, respBody)
+}
+
+func (cfg *apiConfig) deleteConversation(w http.ResponseWriter, r *http.Request) {
+	id, conversationID, ok := cfg.conversationMember(w, r)
+	if !ok {
+		return
+	}
+
+	// Deleting only clears the history for the caller
+	err := cfg.database.ClearConversation(r.Context(), database.ClearConversationParams{
+		ConversationID: conversationID,
+		UserID:         id,
+	})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't delete conversation", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getMessages(w http.ResponseWriter, r *http.Request) {
+	id, conversationID, ok := cfg.conversationMember(w, r)
+	if !ok {
+		return
+	}
+
+	messages, err := cfg.database.GetConversationMessages(r.Context(), database.GetConversationMessagesParams{
+		ConversationID: conversationID,
+		UserID:         id,
+	})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't get messages", err)
+		return
+	}
+
+	respBody := []Message{}
+	for _, message := range messages {
+		respBody = append(respBody, Message{
+			ID:             message.ID,
+			CreatedAt:      message.CreatedAt,
+			UpdatedAt:      message.UpdatedAt,
+			ConversationID: message.ConversationID,
+			SenderID:       message.SenderID,
+			Body:           message.Body,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, respBody)
+}
+
+func (cfg *apiConfig) sendMessage(w http.ResponseWriter, r *http.Request) {
+	id, conversationID, ok := cfg.conversationMember(w, r)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		Body string `json:"body" validate:"required,max=140"`
+	}
+	params, ok := decode[parameters](w, r)
+	if !ok {
+		return
+	}
+	cleanBody := cleanProfanity(params.Body)
+
+	// A block with any participant stops the sender from posting
+	blocked, err := cfg.database.ConversationHasBlock(r.Context(), database.ConversationHasBlockParams{
+		UserID:         id,
+		ConversationID: conversationID,
+	})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't check blocks", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, r, errForbidden, "Can't message this conversation", nil)
+		return
+	}
+
+	var message database.Message
+	err = cfg.database.InTx(r.Context(), func(q database.Querier) error {
+		var err error
+		message, err = q.CreateMessage(r.Context(), database.CreateMessageParams{
+			ConversationID: conversationID,
+			SenderID:       id,
+			Body:           cleanBody,
+		})
+		if err != nil {
+			return err
+		}
+		err = q.TouchConversation(r.Context(), conversationID)
+		if err != nil {
+			return err
+		}
+		// The sender has read everything up to their own message
+		_, err = q.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+			ConversationID: conversationID,
+			UserID:         id,
+			MessageID:      message.ID,
+		})
+		return err
+	})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't send message", err)
+		return
+	}
+
+	respBody := Message{
+		ID:             message.ID,
+		CreatedAt:      message.CreatedAt,
+		UpdatedAt:      message.UpdatedAt,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		Body:           message.Body,
+	}
+
+	// Let the other participants know in real time
+	participants, err := cfg.database.GetConversationsParticipants(r.Context(), []uuid.UUID{conversationID})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Couldn't get participants", "err", err)
+	}
+	for _, participant := range participants {
+		if participant.UserID == id {
+			continue
+		}
+		err = cfg.events.Publish(r.Context(), events.MessageCreated, id, participant.UserID, respBody)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Couldn't publish message event", "err", err)
+		}
+		err = cfg.notifications.Notify(r.Context(), participant.UserID, id, notifications.TypeMessage, conversationID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Couldn't record notification", "err", err)
+		}
+	}
+
+	respondWithJSON(w, http.StatusCreated, respBody)
+}
+
+func (cfg *apiConfig) markConversationRead(w http.ResponseWriter, r *http.Request) {
+	id, conversationID, ok := cfg.conversationMember(w, r)
+	if !ok {
+		return
+	}
+
+	// The client names the newest message it has shown, so a message that
+	// arrives meanwhile stays unread
+	type parameters struct {
+		MessageID string `json:"message_id" validate:"required,uuid"`
+	}
+	params, ok := decode[parameters](w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := cfg.database.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         id,
+		MessageID:      uuid.MustParse(params.MessageID),
+	})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't mark conversation read", err)
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, r, errNotFound, "Couldn't find message in conversation", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// conversationMember authenticates the caller and checks they belong to the
+// conversation in the path. Non-members get a 404 so conversation IDs don't leak.
+func (cfg *apiConfig) conversationMember(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, r, errInvalidID, "conversationID isn't a valid UUID", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	_, err = cfg.database.GetConversationParticipant(r.Context(), database.GetConversationParticipantParams{
+		ConversationID: conversationID,
+		UserID:         id,
+	})
+	if err != nil {
+		respondWithError(w, r, errNotFound, "Couldn't find conversation", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return id, conversationID, true
+}
+
+// getParticipants loads the participants of several conversations in one query.
+func (cfg *apiConfig) getParticipants(r *http.Request, ids []uuid.UUID) (map[uuid.UUID][]Participant, error) {
+	rows, err := cfg.database.GetConversationsParticipants(r.Context(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	participants := map[uuid.UUID][]Participant{}
+	for _, row := range rows {
+		participant := Participant{UserID: row.UserID}
+		if row.LastReadAt.Valid {
+			lastRead := row.LastReadAt.Time
+			participant.LastReadAt = &lastRead
+		}
+		participants[row.ConversationID] = append(participants[row.ConversationID], participant)
+	}
+	return participants, nil
+}
+
+func (cfg *apiConfig) respondWithConversation(w http.ResponseWriter, r *http.Request, conversation database.Conversation, code int) {
+	participants, err := cfg.getParticipants(r, []uuid.UUID{conversation.ID})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't get participants", err)
+		return
+	}
+
+	respondWithJSON(w, code, Conversation{
+		ID:           conversation.ID,
+		CreatedAt:    conversation.CreatedAt,
+		UpdatedAt:    conversation.UpdatedAt,
+		Participants: participants[conversation.ID],
+	})
+}
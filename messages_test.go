@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDirectMessages(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+	hank := ts.signUp("hank@dea.gov")
+
+	participants := map[string][]string{"participant_ids": {jesse.ID.String()}}
+	conversation := expect[Conversation](t, ts.do("POST", "/api/conversations", walt.Token, participants), http.StatusCreated)
+	base := "/api/conversations/" + conversation.ID.String()
+
+	// Starting the same 1:1 conversation again returns the existing one
+	again := expect[Conversation](t, ts.do("POST", "/api/conversations", walt.Token, participants), http.StatusOK)
+	if again.ID != conversation.ID {
+		t.Errorf("got a new conversation %v, want %v", again.ID, conversation.ID)
+	}
+
+	message := expect[Message](t, ts.do("POST", base+"/messages", walt.Token, map[string]string{"body": "We need to cook"}), http.StatusCreated)
+	if message.SenderID != walt.ID {
+		t.Errorf("got sender %v, want %v", message.SenderID, walt.ID)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		wantCount int64
+	}{
+		{
+			name:      "Sender has nothing unread",
+			token:     walt.Token,
+			wantCount: 0,
+		},
+		{
+			name:      "Recipient has one unread",
+			token:     jesse.Token,
+			wantCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversations := expect[[]Conversation](t, ts.do("GET", "/api/conversations", tt.token, nil), http.StatusOK)
+			if len(conversations) != 1 {
+				t.Fatalf("got %d conversations, want 1", len(conversations))
+			}
+			if conversations[0].UnreadCount != tt.wantCount {
+				t.Errorf("got unread count %d, want %d", conversations[0].UnreadCount, tt.wantCount)
+			}
+		})
+	}
+
+	// The recipient is notified
+	type notificationsResponse struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+	}
+	notes := expect[notificationsResponse](t, ts.do("GET", "/api/notifications", jesse.Token, nil), http.StatusOK)
+	if notes.UnreadCount != 1 || len(notes.Notifications) != 1 || notes.Notifications[0].SubjectID != conversation.ID {
+		t.Errorf("got notifications %+v, want one for the conversation", notes)
+	}
+
+	// Reading goes up to the newest message the reader has seen, so one sent
+	// meanwhile stays unread
+	later := expect[Message](t, ts.do("POST", base+"/messages", walt.Token, map[string]string{"body": "Yo"}), http.StatusCreated)
+	reads := []struct {
+		name      string
+		body      any
+		wantCode  int
+		wantCount int64
+	}{
+		{name: "No message", body: map[string]string{}, wantCode: http.StatusBadRequest, wantCount: 2},
+		{name: "Invalid message ID", body: map[string]string{"message_id": "heisenberg"}, wantCode: http.StatusBadRequest, wantCount: 2},
+		{name: "Unknown message", body: map[string]string{"message_id": conversation.ID.String()}, wantCode: http.StatusNotFound, wantCount: 2},
+		{name: "First message", body: map[string]string{"message_id": message.ID.String()}, wantCode: http.StatusNoContent, wantCount: 1},
+		{name: "Latest message", body: map[string]string{"message_id": later.ID.String()}, wantCode: http.StatusNoContent, wantCount: 0},
+		{name: "Older message again", body: map[string]string{"message_id": message.ID.String()}, wantCode: http.StatusNoContent, wantCount: 0},
+	}
+	for _, tt := range reads {
+		t.Run(tt.name, func(t *testing.T) {
+			expect[any](t, ts.do("POST", base+"/read", jesse.Token, tt.body), tt.wantCode)
+			conversations := expect[[]Conversation](t, ts.do("GET", "/api/conversations", jesse.Token, nil), http.StatusOK)
+			if conversations[0].UnreadCount != tt.wantCount {
+				t.Errorf("got unread count %d, want %d", conversations[0].UnreadCount, tt.wantCount)
+			}
+		})
+	}
+
+	// Outsiders can't tell the conversation exists
+	expect[any](t, ts.do("GET", base+"/messages", hank.Token, nil), http.StatusNotFound)
+	expect[any](t, ts.do("POST", base+"/messages", hank.Token, map[string]string{"body": "Knock knock"}), http.StatusNotFound)
+
+	// A block stops either side from posting
+	expect[any](t, ts.do("POST", "/api/blocks", jesse.Token, map[string]string{"user_id": walt.ID.String()}), http.StatusNoContent)
+	expect[any](t, ts.do("POST", base+"/messages", walt.Token, map[string]string{"body": "Jesse?"}), http.StatusForbidden)
+
+	messages := expect[[]Message](t, ts.do("GET", base+"/messages", jesse.Token, nil), http.StatusOK)
+	if len(messages) != 2 || messages[0].ID != message.ID || messages[1].ID != later.ID {
+		t.Errorf("got %d messages, want only the two sent before the block", len(messages))
+	}
+}
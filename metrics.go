@@ -1,23 +1,77 @@
 package main
 
 import (
-	"fmt"
+	"html/template"
 	"net/http"
-	"strconv"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
 )
 
-func (cfg *apiConfig) Metrics(w http.ResponseWriter, req *http.Request) {
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	count := strconv.FormatUint(uint64(cfg.fileserverHits.Load()), 10)
-	body := fmt.Sprintf(`<html>
+// dashboard renders the same metrics Prometheus scrapes from /metrics. They
+// count from when this instance started, except for visits, which count from
+// the last reset.
+var dashboard = template.Must(template.New("dashboard").Parse(`<html>
+  <head>
+    <title>Chirpy Admin</title>
+    <meta http-equiv="refresh" content="10">
+    <style>
+      body { font-family: sans-serif; margin: 2em; }
+      table { border-collapse: collapse; margin-bottom: 1.5em; }
+      th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+      td.n { text-align: right; }
+    </style>
+  </head>
   <body>
     <h1>Welcome, Chirpy Admin</h1>
-    <p>Chirpy has been visited %s times!</p>
+    <p>Chirpy has been visited {{printf "%.0f" .FileserverHits}} times!</p>
+
+    <h2>Activity</h2>
+    <table>
+      <tr><th>Users signed up</th><td class="n">{{printf "%.0f" .UsersCreated}}</td></tr>
+      {{range $status, $n := .ChirpsCreated}}<tr><th>Chirps created ({{$status}})</th><td class="n">{{printf "%.0f" $n}}</td></tr>
+      {{end}}
+      {{range $reason, $n := .LoginFailures}}<tr><th>Failed logins ({{$reason}})</th><td class="n">{{printf "%.0f" $n}}</td></tr>
+      {{end}}
+      {{range $outcome, $n := .Webhooks}}<tr><th>Polka webhooks ({{$outcome}})</th><td class="n">{{printf "%.0f" $n}}</td></tr>
+      {{end}}
+      {{range $policy, $n := .RateLimited}}<tr><th>Rate limited ({{$policy}})</th><td class="n">{{printf "%.0f" $n}}</td></tr>
+      {{end}}
+      {{range $route, $n := .Deprecated}}<tr><th>Deprecated calls ({{$route}})</th><td class="n">{{printf "%.0f" $n}}</td></tr>
+      {{end}}
+    </table>
+
+    <h2>Requests</h2>
+    <table>
+      <tr><th>Route</th><th>Requests</th><th>5XX</th><th>Mean latency</th><th>p95 latency</th></tr>
+      {{range .Routes}}<tr><td>{{.Route}}</td><td class="n">{{printf "%.0f" .Requests}}</td><td class="n">{{printf "%.0f" .Errors}}</td><td class="n">{{.MeanLatency}}</td><td class="n">{{.P95Latency}}</td></tr>
+      {{else}}<tr><td colspan="5">No requests yet</td></tr>
+      {{end}}
+    </table>
+    {{with .DB}}
+    <h2>Database pool</h2>
+    <table>
+      <tr><th>Open connections</th><td class="n">{{printf "%.0f" .OpenConnections}}</td></tr>
+      <tr><th>In use</th><td class="n">{{printf "%.0f" .InUse}}</td></tr>
+      <tr><th>Idle</th><td class="n">{{printf "%.0f" .Idle}}</td></tr>
+      <tr><th>Waits for a connection</th><td class="n">{{printf "%.0f" .WaitCount}}</td></tr>
+      <tr><th>Time spent waiting</th><td class="n">{{.WaitDuration}}</td></tr>
+    </table>
+    {{end}}
+    <p>Raw metrics for Prometheus are at <a href="/metrics">/metrics</a>.</p>
   </body>
-</html>`,
-		count)
-	_, err := w.Write([]byte(body))
+</html>`))
+
+func (cfg *apiConfig) Metrics(w http.ResponseWriter, req *http.Request) {
+	summary, err := cfg.metrics.Summary()
+	if err != nil {
+		respondWithError(w, req, errInternal, "Couldn't gather metrics", err)
+		return
+	}
+	summary.FileserverHits = float64(cfg.fileserverHits.Load())
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = dashboard.Execute(w, summary)
 	if err != nil {
-		fmt.Printf("Error writing response: %v\n", err)
+		logging.FromContext(req.Context()).Error("Error writing response", "err", err)
 	}
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// body reads a plain response body.
+func (ts *testServer) body(path string) string {
+	ts.t.Helper()
+	resp, err := ts.Client().Get(ts.URL + path)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		ts.t.Fatalf("GET %s = %d", path, resp.StatusCode)
+	}
+	dat, err := io.ReadAll(resp.Body)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	return string(dat)
+}
+
+func TestMetrics(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	ts.chirp(walt.Token, "Say my name")
+	expect[Chirp](t, ts.do("POST", "/api/chirps", walt.Token, map[string]any{"body": "Later", "draft": true}), http.StatusCreated)
+	expect[any](t, ts.do("POST", "/api/login", "", map[string]string{"email": "walt@breakingbad.com", "password": "wrong"}), http.StatusUnauthorized)
+	expect[any](t, ts.do("POST", "/api/login", "", map[string]string{"email": "heisenberg@breakingbad.com", "password": "wrong"}), http.StatusUnauthorized)
+	expect[any](t, ts.do("POST", "/api/polka/webhooks", "", map[string]any{"event": "user.payment_failed"}), http.StatusNoContent)
+	expect[any](t, ts.do("GET", "/api/nope", "", nil), http.StatusNotFound)
+	ts.body("/app/")
+
+	metrics := ts.body("/metrics")
+	for _, want := range []string{
+		`chirpy_users_created_total 1`,
+		`chirpy_chirps_created_total{status="published"} 1`,
+		`chirpy_chirps_created_total{status="draft"} 1`,
+		`chirpy_chirps_created_total{status="scheduled"} 0`,
+		`chirpy_login_failures_total{reason="unknown_email"} 1`,
+		`chirpy_login_failures_total{reason="wrong_password"} 1`,
+		`chirpy_polka_webhooks_total{outcome="ignored"} 1`,
+		`chirpy_fileserver_hits_total 1`,
+		`chirpy_http_requests_total{route="POST /api/login",status="401"} 2`,
+		`chirpy_http_requests_total{route="unmatched",status="404"} 1`,
+		`chirpy_http_request_duration_seconds_count{route="POST /api/chirps",status="201"} 2`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("/metrics doesn't contain %s", want)
+		}
+	}
+
+	dashboard := ts.body("/admin/metrics")
+	for _, want := range []string{
+		"Chirpy has been visited 1 times!",
+		"POST /api/login",
+		"Failed logins (wrong_password)",
+	} {
+		if !strings.Contains(dashboard, want) {
+			t.Errorf("dashboard doesn't contain %q", want)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signUp("walt@breakingbad.com")
+	ts.body("/app/")
+
+	ts.cfg.platform = "prod"
+	forbidden := expect[problem](t, ts.do("POST", "/admin/reset", "", nil), http.StatusForbidden)
+	if forbidden.Code != "forbidden" {
+		t.Errorf("got code %q, want forbidden", forbidden.Code)
+	}
+
+	ts.cfg.platform = "dev"
+	resp := ts.do("POST", "/admin/reset", "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if dashboard := ts.body("/admin/metrics"); !strings.Contains(dashboard, "Chirpy has been visited 0 times!") {
+		t.Error("dashboard still counts visits from before the reset")
+	}
+	// Prometheus counters only go up
+	if metrics := ts.body("/metrics"); !strings.Contains(metrics, "chirpy_fileserver_hits_total 1") {
+		t.Error("/metrics reset chirpy_fileserver_hits_total")
+	}
+	expect[problem](t, ts.do("POST", "/api/login", "", map[string]string{"email": "walt@breakingbad.com", "password": testPassword}), http.StatusUnauthorized)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"text/tabwriter"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/migrate"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store"
+	"github.com/pressly/goose/v3"
+)
+
+// The binary carries its own schema, so it can always tell whether the
+// database it's pointed at matches it.
+//
+//go:embed sql/schema/*.sql sql/sqlite/schema/*.sql
+var migrationFiles embed.FS
+
+func newMigrator(db *sql.DB, driver string) (*migrate.Migrator, error) {
+	dir := "sql/schema"
+	if driver == store.DriverSQLite {
+		dir = "sql/sqlite/schema"
+	}
+	fsys, err := fs.Sub(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, driver, fsys)
+}
+
+const migrateUsage = "usage: chirpy migrate up|down|status"
+
+// runMigrate implements `chirpy migrate up|down|status`.
+func runMigrate(ctx context.Context, m *migrate.Migrator, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Fprintln(out, "No pending migrations")
+		}
+		for _, res := range results {
+			fmt.Fprintf(out, "Applied %s (%s)\n", path.Base(res.Source.Path), res.Duration)
+		}
+		return nil
+	case "down":
+		res, err := m.Down(ctx)
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			fmt.Fprintln(out, "No migrations to roll back")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Rolled back %s (%s)\n", path.Base(res.Source.Path), res.Duration)
+		return nil
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+		for _, st := range statuses {
+			appliedAt := "-"
+			if st.State == goose.StateApplied {
+				appliedAt = st.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", st.Source.Version, st.State, appliedAt, path.Base(st.Source.Path))
+		}
+		return tw.Flush()
+	}
+	return errors.New(migrateUsage)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store"
+)
+
+func TestMigrateCommand(t *testing.T) {
+	db, err := store.OpenDB("sqlite://" + filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	m, err := newMigrator(db, store.DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: []string{"status"}, want: "001_users.sql"},
+		{args: []string{"up"}, want: "Applied 001_users.sql"},
+		{args: []string{"up"}, want: "No pending migrations"},
+		{args: []string{"status"}, want: "applied"},
+		{args: []string{"down"}, want: "Rolled back "},
+		{args: []string{"sideways"}, wantErr: true},
+		{args: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var out bytes.Buffer
+			err := runMigrate(context.Background(), m, tt.args, &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runMigrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("output %q doesn't contain %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/notifications"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultNotificationsLimit = 20
+	maxNotificationsLimit     = 100
+)
+
+type Notification struct {
+	ID           uuid.UUID   `json:"id"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+	Type         string      `json:"type"`
+	SubjectID    uuid.UUID   `json:"subject_id"`
+	ActorCount   int64       `json:"actor_count"`
+	RecentActors []uuid.UUID `json:"recent_actors"`
+	Read         bool        `json:"read"`
+	Summary      string      `json:"summary"`
+}
+
+type NotificationPreference struct {
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+func (cfg *apiConfig) getNotifications(w http.ResponseWriter, r *http.Request) {
+	type notificationsResponse struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+		NextCursor    string         `json:"next_cursor,omitempty"`
+	}
+
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultNotificationsLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxNotificationsLimit {
+			respondWithError(w, r, errInvalidParameter, "Invalid limit", err)
+			return
+		}
+		limit = parsed
+	}
+
+	// The first page starts after the newest possible position
+	beforeTime := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	beforeID := uuid.Max
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		var err error
+		beforeTime, beforeID, err = decodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, r, errInvalidParameter, "Invalid cursor", err)
+			return
+		}
+	}
+
+	// Fetch one extra row to know whether there's another page
+	rows, err := cfg.database.GetNotifications(r.Context(), database.GetNotificationsParams{
+		UserID:     id,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		BeforeTime: beforeTime,
+		BeforeID:   beforeID,
+		MaxResults: int32(limit + 1),
+	})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't get notifications", err)
+		return
+	}
+
+	unread, err := cfg.database.CountUnreadNotifications(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't count notifications", err)
+		return
+	}
+
+	respBody := notificationsResponse{
+		Notifications: []Notification{},
+		UnreadCount:   unread,
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		respBody.NextCursor = encodeCursor(last.UpdatedAt, last.ID)
+	}
+	for _, row := range rows {
+		recent := row.RecentActors
+		if recent == nil {
+			recent = []uuid.UUID{}
+		}
+		respBody.Notifications = append(respBody.Notifications, Notification{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			Type:         row.Type,
+			SubjectID:    row.SubjectID,
+			ActorCount:   row.ActorCount,
+			RecentActors: recent,
+			Read:         row.ReadAt.Valid,
+			Summary:      notificationSummary(notifications.Type(row.Type), row.ActorCount),
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, respBody)
+}
+
+func (cfg *apiConfig) markNotificationRead(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, r, errInvalidID, "notificationID isn't a valid UUID", err)
+		return
+	}
+
+	updated, err := cfg.database.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: id,
+	})
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't mark notification read", err)
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, r, errNotFound, "Couldn't find notification", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) markAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.database.MarkAllNotificationsRead(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't mark notifications read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+	cfg.respondWithPreferences(w, r, id)
+}
+
+func (cfg *apiConfig) updateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	// Body maps types to whether they're enabled, e.g. {"like": false}
+	params, ok := decode[map[string]bool](w, r)
+	if !ok {
+		return
+	}
+	var errs []fieldError
+	for _, typ := range slices.Sorted(maps.Keys(params)) {
+		if !notifications.Valid(typ) {
+			errs = append(errs, fieldError{Pointer: pointer(typ), Code: "unknown", Detail: "Unknown notification type: " + typ})
+		}
+	}
+	if len(errs) > 0 {
+		respondWithInvalid(w, r, errs...)
+		return
+	}
+
+	for typ, enabled := range params {
+		err := cfg.database.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  id,
+			Type:    typ,
+			Enabled: enabled,
+		})
+		if err != nil {
+			respondWithError(w, r, errInternal, "Couldn't update preferences", err)
+			return
+		}
+	}
+
+	cfg.respondWithPreferences(w, r, id)
+}
+
+// respondWithPreferences lists every notification type; types without a
+// stored preference are enabled.
+func (cfg *apiConfig) respondWithPreferences(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	stored, err := cfg.database.GetNotificationPreferences(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't get preferences", err)
+		return
+	}
+	enabled := map[string]bool{}
+	for _, pref := range stored {
+		enabled[pref.Type] = pref.Enabled
+	}
+
+	respBody := []NotificationPreference{}
+	for _, typ := range notifications.Types {
+		on, ok := enabled[string(typ)]
+		respBody = append(respBody, NotificationPreference{
+			Type:    string(typ),
+			Enabled: on || !ok,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, respBody)
+}
+
+func notificationSummary(typ notifications.Type, actors int64) string {
+	who := "Someone"
+	if actors > 1 {
+		who = fmt.Sprintf("%d people", actors)
+	}
+	switch typ {
+	case notifications.TypeLike:
+		return who + " liked your chirp"
+	case notifications.TypeReply:
+		return who + " replied to your chirp"
+	case notifications.TypeFollow:
+		return who + " followed you"
+	case notifications.TypeMention:
+		return who + " mentioned you"
+	case notifications.TypeMessage:
+		return who + " sent you a message"
+	}
+	return who + " interacted with you"
+}
+
+// Cursors are the (updated_at, id) position of the last notification on a
+// page; the id breaks ties between notifications updated at the same instant.
+// Later pages only hold notifications below that position, so a group that's
+// bumped while the client pages moves above the cursor: it isn't repeated,
+// and the next first page picks it up.
+func encodeCursor(t time.Time, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(t.Format(time.RFC3339Nano) + "|" + id.String()))
+}
+
+func decodeCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	timePart, idPart, found := strings.Cut(string(raw), "|")
+	if !found {
+		return time.Time{}, uuid.Nil, fmt.Errorf("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, timePart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	return t, id, nil
+}
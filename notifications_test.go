@@ -0,0 +1,202 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/notifications"
+	"github.com/google/uuid"
+)
+
+type notificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int64          `json:"unread_count"`
+	NextCursor    string         `json:"next_cursor"`
+}
+
+// notify records a notification for recipient as the service's callers do.
+func (ts *testServer) notify(recipient, actor User, typ notifications.Type, subject uuid.UUID) {
+	ts.t.Helper()
+	err := ts.cfg.notifications.Notify(ts.t.Context(), recipient.ID, actor.ID, typ, subject)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+}
+
+// notificationPages reads the user's notifications one page at a time,
+// calling between before each page after the first.
+func (ts *testServer) notificationPages(token string, limit string, between func()) []uuid.UUID {
+	ts.t.Helper()
+	var ids []uuid.UUID
+	query := url.Values{"limit": {limit}}
+	for {
+		page := expect[notificationPage](ts.t, ts.do("GET", "/api/notifications?"+query.Encode(), token, nil), http.StatusOK)
+		for _, notification := range page.Notifications {
+			ids = append(ids, notification.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		query.Set("cursor", page.NextCursor)
+		if between != nil {
+			between()
+		}
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+	hank := ts.signUp("hank@dea.gov")
+	chirp := uuid.New()
+
+	ts.notify(walt, jesse, notifications.TypeLike, chirp)
+	ts.notify(walt, hank, notifications.TypeLike, chirp)
+	ts.notify(walt, jesse, notifications.TypeFollow, walt.ID)
+
+	page := expect[notificationPage](t, ts.do("GET", "/api/notifications", walt.Token, nil), http.StatusOK)
+	if len(page.Notifications) != 2 || page.UnreadCount != 2 {
+		t.Fatalf("got %d notifications, %d unread, want 2 of each", len(page.Notifications), page.UnreadCount)
+	}
+	follow, likes := page.Notifications[0], page.Notifications[1]
+	if likes.Summary != "2 people liked your chirp" || likes.ActorCount != 2 {
+		t.Errorf("got summary %q from %d actors for grouped likes", likes.Summary, likes.ActorCount)
+	}
+	if !slices.Equal(likes.RecentActors, []uuid.UUID{hank.ID, jesse.ID}) {
+		t.Errorf("got recent actors %v, want hank then jesse", likes.RecentActors)
+	}
+	if follow.Summary != "Someone followed you" {
+		t.Errorf("got summary %q for one follow", follow.Summary)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		path     string
+		wantCode int
+	}{
+		{name: "No token", path: "/api/notifications/" + likes.ID.String() + "/read", wantCode: http.StatusUnauthorized},
+		{name: "Invalid ID", token: walt.Token, path: "/api/notifications/heisenberg/read", wantCode: http.StatusBadRequest},
+		{name: "Someone else's", token: jesse.Token, path: "/api/notifications/" + likes.ID.String() + "/read", wantCode: http.StatusNotFound},
+		{name: "Own", token: walt.Token, path: "/api/notifications/" + likes.ID.String() + "/read", wantCode: http.StatusNoContent},
+		{name: "Already read", token: walt.Token, path: "/api/notifications/" + likes.ID.String() + "/read", wantCode: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := ts.do("POST", tt.path, tt.token, nil)
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+		})
+	}
+
+	unread := expect[notificationPage](t, ts.do("GET", "/api/notifications?unread=true", walt.Token, nil), http.StatusOK)
+	if len(unread.Notifications) != 1 || unread.Notifications[0].ID != follow.ID || unread.UnreadCount != 1 {
+		t.Errorf("got unread %+v, want only the follow", unread)
+	}
+
+	// A like after reading starts a new group
+	ts.notify(walt, hank, notifications.TypeLike, chirp)
+	expect[any](t, ts.do("POST", "/api/notifications/read", walt.Token, nil), http.StatusNoContent)
+	page = expect[notificationPage](t, ts.do("GET", "/api/notifications", walt.Token, nil), http.StatusOK)
+	if len(page.Notifications) != 3 || page.UnreadCount != 0 {
+		t.Errorf("got %d notifications, %d unread after marking all read, want 3 and 0", len(page.Notifications), page.UnreadCount)
+	}
+	for _, notification := range page.Notifications {
+		if !notification.Read {
+			t.Errorf("notification %s is unread after marking all read", notification.ID)
+		}
+	}
+}
+
+func TestNotificationsPagination(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+	hank := ts.signUp("hank@dea.gov")
+
+	var want []uuid.UUID
+	for range 5 {
+		ts.notify(walt, jesse, notifications.TypeLike, uuid.New())
+	}
+	all := expect[notificationPage](t, ts.do("GET", "/api/notifications", walt.Token, nil), http.StatusOK)
+	for _, notification := range all.Notifications {
+		want = append(want, notification.ID)
+	}
+
+	for _, limit := range []string{"1", "2", "5"} {
+		t.Run("Limit "+limit, func(t *testing.T) {
+			if got := ts.notificationPages(walt.Token, limit, nil); !slices.Equal(got, want) {
+				t.Errorf("paged through %v, want %v", got, want)
+			}
+		})
+	}
+
+	for _, query := range []string{"limit=0", "limit=101", "limit=many", "cursor=heisenberg"} {
+		t.Run(query, func(t *testing.T) {
+			got := expect[problem](t, ts.do("GET", "/api/notifications?"+query, walt.Token, nil), http.StatusBadRequest)
+			if got.Code != "invalid_parameter" {
+				t.Errorf("got code %q, want invalid_parameter", got.Code)
+			}
+		})
+	}
+
+	// Groups bumped while paging move to the top, ahead of the cursor: one
+	// already seen isn't repeated, and one not yet seen waits for the next
+	// first page rather than turning up out of order
+	notes := all.Notifications
+	bumped := false
+	got := ts.notificationPages(walt.Token, "2", func() {
+		if bumped {
+			return
+		}
+		bumped = true
+		ts.notify(walt, hank, notifications.TypeLike, notes[1].SubjectID)
+		ts.notify(walt, hank, notifications.TypeLike, notes[3].SubjectID)
+	})
+	wantPaged := []uuid.UUID{want[0], want[1], want[2], want[4]}
+	if !slices.Equal(got, wantPaged) {
+		t.Errorf("paged through %v while bumping, want %v", got, wantPaged)
+	}
+	first := expect[notificationPage](t, ts.do("GET", "/api/notifications?limit=2", walt.Token, nil), http.StatusOK)
+	if len(first.Notifications) != 2 || first.Notifications[0].ID != want[3] || first.Notifications[1].ID != want[1] {
+		t.Errorf("got first page %+v, want the bumped groups newest first", first.Notifications)
+	}
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+
+	preferences := expect[[]NotificationPreference](t, ts.do("GET", "/api/notifications/preferences", walt.Token, nil), http.StatusOK)
+	if len(preferences) != len(notifications.Types) {
+		t.Fatalf("got %d preferences, want one per type", len(preferences))
+	}
+	for _, pref := range preferences {
+		if !pref.Enabled {
+			t.Errorf("%s is disabled by default", pref.Type)
+		}
+	}
+
+	invalid := expect[problem](t, ts.do("PUT", "/api/notifications/preferences", walt.Token, map[string]bool{"like": false, "poke": false}), http.StatusBadRequest)
+	if len(invalid.Errors) != 1 || invalid.Errors[0].Pointer != "/poke" {
+		t.Errorf("got errors %+v, want one for /poke", invalid.Errors)
+	}
+
+	preferences = expect[[]NotificationPreference](t, ts.do("PUT", "/api/notifications/preferences", walt.Token, map[string]bool{"like": false}), http.StatusOK)
+	for _, pref := range preferences {
+		if pref.Enabled != (pref.Type != string(notifications.TypeLike)) {
+			t.Errorf("got %s enabled=%v after disabling likes", pref.Type, pref.Enabled)
+		}
+	}
+
+	ts.notify(walt, jesse, notifications.TypeLike, uuid.New())
+	page := expect[notificationPage](t, ts.do("GET", "/api/notifications", walt.Token, nil), http.StatusOK)
+	if len(page.Notifications) != 0 {
+		t.Errorf("got %d notifications of a disabled type", len(page.Notifications))
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
+)
+
+// openAPI describes every route in routes. It's written by hand;
+// TestOpenAPI fails when it and the routes or response types disagree.
+//
+//go:embed openapi.json
+var openAPI []byte
+
+// serveOpenAPI serves the API description, which the docs page under
+// /app/docs/ renders.
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, err := w.Write(openAPI)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error writing response", "err", err)
+	}
+}
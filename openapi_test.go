@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/chirpyclient"
+)
+
+type openAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas    map[string]openAPISchema    `json:"schemas"`
+		Parameters map[string]openAPIParameter `json:"parameters"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Parameters []openAPIParameter `json:"parameters"`
+}
+
+type openAPIParameter struct {
+	Ref  string `json:"$ref"`
+	Name string `json:"name"`
+	In   string `json:"in"`
+}
+
+type openAPISchema struct {
+	Properties map[string]any `json:"properties"`
+	Required   []string       `json:"required"`
+}
+
+func loadOpenAPI(t *testing.T) openAPIDocument {
+	t.Helper()
+	var doc openAPIDocument
+	err := json.Unmarshal(openAPI, &doc)
+	if err != nil {
+		t.Fatalf("openapi.json isn't valid JSON: %v", err)
+	}
+	return doc
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	doc := loadOpenAPI(t)
+
+	var documented []string
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+	var registered []string
+	for _, pattern := range newTestServer(t).cfg.mux(".").patterns {
+		// Static files aren't part of the API
+		if pattern != "/app/" {
+			registered = append(registered, pattern)
+		}
+	}
+	slices.Sort(documented)
+	slices.Sort(registered)
+
+	for _, route := range registered {
+		if !slices.Contains(documented, route) {
+			t.Errorf("%s is registered but missing from openapi.json", route)
+		}
+	}
+	for _, route := range documented {
+		if !slices.Contains(registered, route) {
+			t.Errorf("%s is in openapi.json but not registered", route)
+		}
+	}
+}
+
+func TestOpenAPIPathParameters(t *testing.T) {
+	doc := loadOpenAPI(t)
+	wildcard := regexp.MustCompile(`\{(\w+)\}`)
+
+	for path, operations := range doc.Paths {
+		var want []string
+		for _, match := range wildcard.FindAllStringSubmatch(path, -1) {
+			want = append(want, match[1])
+		}
+		for method, op := range operations {
+			var got []string
+			for _, p := range op.Parameters {
+				if name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/"); ok {
+					p = doc.Components.Parameters[name]
+				}
+				if p.In == "path" {
+					got = append(got, p.Name)
+				}
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("%s %s has path parameters %v, want %v", method, path, got, want)
+			}
+		}
+	}
+}
+
+// TestOpenAPISchemas checks that the schemas for response types list the
+// same fields as the structs, both the server's and the client's, and
+// require those that are always sent.
+func TestOpenAPISchemas(t *testing.T) {
+	doc := loadOpenAPI(t)
+	types := []struct {
+		schema string
+		typ    reflect.Type
+	}{
+		{"User", reflect.TypeFor[User]()},
+		{"UserV2", reflect.TypeFor[UserV2]()},
+		{"Session", reflect.TypeFor[Session]()},
+		{"Chirp", reflect.TypeFor[Chirp]()},
+		{"Block", reflect.TypeFor[Block]()},
+		{"Mute", reflect.TypeFor[Mute]()},
+		{"Conversation", reflect.TypeFor[Conversation]()},
+		{"Participant", reflect.TypeFor[Participant]()},
+		{"Message", reflect.TypeFor[Message]()},
+		{"Notification", reflect.TypeFor[Notification]()},
+		{"NotificationPreference", reflect.TypeFor[NotificationPreference]()},
+		{"Problem", reflect.TypeFor[problem]()},
+		{"FieldError", reflect.TypeFor[fieldError]()},
+		{"User", reflect.TypeFor[chirpyclient.User]()},
+		{"Chirp", reflect.TypeFor[chirpyclient.Chirp]()},
+		{"Block", reflect.TypeFor[chirpyclient.Block]()},
+		{"Mute", reflect.TypeFor[chirpyclient.Mute]()},
+		{"Conversation", reflect.TypeFor[chirpyclient.Conversation]()},
+		{"Participant", reflect.TypeFor[chirpyclient.Participant]()},
+		{"Message", reflect.TypeFor[chirpyclient.Message]()},
+		{"Notification", reflect.TypeFor[chirpyclient.Notification]()},
+		{"NotificationPage", reflect.TypeFor[chirpyclient.NotificationPage]()},
+		{"NotificationPreference", reflect.TypeFor[chirpyclient.NotificationPreference]()},
+		{"Readiness", reflect.TypeFor[chirpyclient.Readiness]()},
+		{"FieldError", reflect.TypeFor[chirpyclient.FieldError]()},
+	}
+
+	for _, tt := range types {
+		t.Run(tt.typ.String(), func(t *testing.T) {
+			schema, ok := doc.Components.Schemas[tt.schema]
+			if !ok {
+				t.Fatalf("openapi.json has no %s schema", tt.schema)
+			}
+			var fields, required []string
+			for i := range tt.typ.NumField() {
+				field := tt.typ.Field(i)
+				tag, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+				if tag == "-" {
+					continue
+				}
+				fields = append(fields, tag)
+				if !strings.Contains(opts, "omitempty") {
+					required = append(required, tag)
+				}
+			}
+			slices.Sort(fields)
+			slices.Sort(required)
+			properties := slices.Sorted(maps.Keys(schema.Properties))
+			schemaRequired := slices.Sorted(slices.Values(schema.Required))
+
+			if !slices.Equal(properties, fields) {
+				t.Errorf("schema properties are %v, struct fields are %v", properties, fields)
+			}
+			if !slices.Equal(schemaRequired, required) {
+				t.Errorf("schema requires %v, struct always sends %v", schemaRequired, required)
+			}
+		})
+	}
+}
+
+// TestOpenAPIRefs checks that every $ref points at something.
+func TestOpenAPIRefs(t *testing.T) {
+	var doc map[string]any
+	err := json.Unmarshal(openAPI, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				target := any(doc)
+				for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := target.(map[string]any)
+					target = m[token]
+				}
+				if target == nil {
+					t.Errorf("$ref %s doesn't resolve", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	ts := newTestServer(t)
+	resp := ts.do("GET", "/api/openapi.json", "", nil)
+	doc := expect[openAPIDocument](t, resp, http.StatusOK)
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("got openapi %q, want 3.1.0", doc.OpenAPI)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", got)
+	}
+}
@@ -0,0 +1,1039 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: chirpy/v1/chirps.proto
+
+package chirpyv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ChirpStatus int32
+
+const (
+	ChirpStatus_CHIRP_STATUS_UNSPECIFIED ChirpStatus = 0
+	ChirpStatus_CHIRP_STATUS_DRAFT       ChirpStatus = 1
+	ChirpStatus_CHIRP_STATUS_SCHEDULED   ChirpStatus = 2
+	ChirpStatus_CHIRP_STATUS_PUBLISHED   ChirpStatus = 3
+)
+
+// Enum value maps for ChirpStatus.
+var (
+	ChirpStatus_name = map[int32]string{
+		0: "CHIRP_STATUS_UNSPECIFIED",
+		1: "CHIRP_STATUS_DRAFT",
+		2: "CHIRP_STATUS_SCHEDULED",
+		3: "CHIRP_STATUS_PUBLISHED",
+	}
+	ChirpStatus_value = map[string]int32{
+		"CHIRP_STATUS_UNSPECIFIED": 0,
+		"CHIRP_STATUS_DRAFT":       1,
+		"CHIRP_STATUS_SCHEDULED":   2,
+		"CHIRP_STATUS_PUBLISHED":   3,
+	}
+)
+
+func (x ChirpStatus) Enum() *ChirpStatus {
+	p := new(ChirpStatus)
+	*p = x
+	return p
+}
+
+func (x ChirpStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChirpStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_chirpy_v1_chirps_proto_enumTypes[0].Descriptor()
+}
+
+func (ChirpStatus) Type() protoreflect.EnumType {
+	return &file_chirpy_v1_chirps_proto_enumTypes[0]
+}
+
+func (x ChirpStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChirpStatus.Descriptor instead.
+func (ChirpStatus) EnumDescriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{0}
+}
+
+type Chirp struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Body      string                 `protobuf:"bytes,4,opt,name=body,proto3" json:"body,omitempty"`
+	UserId    string                 `protobuf:"bytes,5,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Status    ChirpStatus            `protobuf:"varint,6,opt,name=status,proto3,enum=chirpy.v1.ChirpStatus" json:"status,omitempty"`
+	// publish_at is set on scheduled chirps
+	PublishAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=publish_at,json=publishAt,proto3" json:"publish_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chirp) Reset() {
+	*x = Chirp{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chirp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chirp) ProtoMessage() {}
+
+func (x *Chirp) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chirp.ProtoReflect.Descriptor instead.
+func (*Chirp) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Chirp) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Chirp) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Chirp) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Chirp) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *Chirp) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *Chirp) GetStatus() ChirpStatus {
+	if x != nil {
+		return x.Status
+	}
+	return ChirpStatus_CHIRP_STATUS_UNSPECIFIED
+}
+
+func (x *Chirp) GetPublishAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PublishAt
+	}
+	return nil
+}
+
+type CreateChirpRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Body  string                 `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	// draft keeps the chirp private until it's edited again
+	Draft bool `protobuf:"varint,2,opt,name=draft,proto3" json:"draft,omitempty"`
+	// publish_at schedules the chirp; a time that has passed publishes it
+	// now
+	PublishAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=publish_at,json=publishAt,proto3" json:"publish_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateChirpRequest) Reset() {
+	*x = CreateChirpRequest{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateChirpRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateChirpRequest) ProtoMessage() {}
+
+func (x *CreateChirpRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateChirpRequest.ProtoReflect.Descriptor instead.
+func (*CreateChirpRequest) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateChirpRequest) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *CreateChirpRequest) GetDraft() bool {
+	if x != nil {
+		return x.Draft
+	}
+	return false
+}
+
+func (x *CreateChirpRequest) GetPublishAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PublishAt
+	}
+	return nil
+}
+
+type CreateChirpResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Chirp         *Chirp                 `protobuf:"bytes,1,opt,name=chirp,proto3" json:"chirp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateChirpResponse) Reset() {
+	*x = CreateChirpResponse{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateChirpResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateChirpResponse) ProtoMessage() {}
+
+func (x *CreateChirpResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateChirpResponse.ProtoReflect.Descriptor instead.
+func (*CreateChirpResponse) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateChirpResponse) GetChirp() *Chirp {
+	if x != nil {
+		return x.Chirp
+	}
+	return nil
+}
+
+type GetChirpRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetChirpRequest) Reset() {
+	*x = GetChirpRequest{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetChirpRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetChirpRequest) ProtoMessage() {}
+
+func (x *GetChirpRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetChirpRequest.ProtoReflect.Descriptor instead.
+func (*GetChirpRequest) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetChirpRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetChirpResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Chirp         *Chirp                 `protobuf:"bytes,1,opt,name=chirp,proto3" json:"chirp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetChirpResponse) Reset() {
+	*x = GetChirpResponse{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetChirpResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetChirpResponse) ProtoMessage() {}
+
+func (x *GetChirpResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetChirpResponse.ProtoReflect.Descriptor instead.
+func (*GetChirpResponse) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetChirpResponse) GetChirp() *Chirp {
+	if x != nil {
+		return x.Chirp
+	}
+	return nil
+}
+
+type ListChirpsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// author_id, if set, lists only this user's chirps
+	AuthorId string `protobuf:"bytes,1,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty"`
+	// descending lists newest first
+	Descending    bool `protobuf:"varint,2,opt,name=descending,proto3" json:"descending,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListChirpsRequest) Reset() {
+	*x = ListChirpsRequest{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListChirpsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListChirpsRequest) ProtoMessage() {}
+
+func (x *ListChirpsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListChirpsRequest.ProtoReflect.Descriptor instead.
+func (*ListChirpsRequest) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListChirpsRequest) GetAuthorId() string {
+	if x != nil {
+		return x.AuthorId
+	}
+	return ""
+}
+
+func (x *ListChirpsRequest) GetDescending() bool {
+	if x != nil {
+		return x.Descending
+	}
+	return false
+}
+
+type ListChirpsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Chirps        []*Chirp               `protobuf:"bytes,1,rep,name=chirps,proto3" json:"chirps,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListChirpsResponse) Reset() {
+	*x = ListChirpsResponse{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListChirpsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListChirpsResponse) ProtoMessage() {}
+
+func (x *ListChirpsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListChirpsResponse.ProtoReflect.Descriptor instead.
+func (*ListChirpsResponse) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListChirpsResponse) GetChirps() []*Chirp {
+	if x != nil {
+		return x.Chirps
+	}
+	return nil
+}
+
+type ListDraftsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDraftsRequest) Reset() {
+	*x = ListDraftsRequest{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDraftsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDraftsRequest) ProtoMessage() {}
+
+func (x *ListDraftsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDraftsRequest.ProtoReflect.Descriptor instead.
+func (*ListDraftsRequest) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{7}
+}
+
+type ListDraftsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Chirps        []*Chirp               `protobuf:"bytes,1,rep,name=chirps,proto3" json:"chirps,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDraftsResponse) Reset() {
+	*x = ListDraftsResponse{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDraftsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDraftsResponse) ProtoMessage() {}
+
+func (x *ListDraftsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDraftsResponse.ProtoReflect.Descriptor instead.
+func (*ListDraftsResponse) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListDraftsResponse) GetChirps() []*Chirp {
+	if x != nil {
+		return x.Chirps
+	}
+	return nil
+}
+
+type UpdateDraftRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Body          string                 `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`
+	Draft         bool                   `protobuf:"varint,3,opt,name=draft,proto3" json:"draft,omitempty"`
+	PublishAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=publish_at,json=publishAt,proto3" json:"publish_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateDraftRequest) Reset() {
+	*x = UpdateDraftRequest{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateDraftRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateDraftRequest) ProtoMessage() {}
+
+func (x *UpdateDraftRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateDraftRequest.ProtoReflect.Descriptor instead.
+func (*UpdateDraftRequest) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *UpdateDraftRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UpdateDraftRequest) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *UpdateDraftRequest) GetDraft() bool {
+	if x != nil {
+		return x.Draft
+	}
+	return false
+}
+
+func (x *UpdateDraftRequest) GetPublishAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PublishAt
+	}
+	return nil
+}
+
+type UpdateDraftResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Chirp         *Chirp                 `protobuf:"bytes,1,opt,name=chirp,proto3" json:"chirp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateDraftResponse) Reset() {
+	*x = UpdateDraftResponse{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateDraftResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateDraftResponse) ProtoMessage() {}
+
+func (x *UpdateDraftResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateDraftResponse.ProtoReflect.Descriptor instead.
+func (*UpdateDraftResponse) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *UpdateDraftResponse) GetChirp() *Chirp {
+	if x != nil {
+		return x.Chirp
+	}
+	return nil
+}
+
+type DeleteChirpRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteChirpRequest) Reset() {
+	*x = DeleteChirpRequest{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteChirpRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteChirpRequest) ProtoMessage() {}
+
+func (x *DeleteChirpRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteChirpRequest.ProtoReflect.Descriptor instead.
+func (*DeleteChirpRequest) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *DeleteChirpRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeleteChirpResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteChirpResponse) Reset() {
+	*x = DeleteChirpResponse{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteChirpResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteChirpResponse) ProtoMessage() {}
+
+func (x *DeleteChirpResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteChirpResponse.ProtoReflect.Descriptor instead.
+func (*DeleteChirpResponse) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{12}
+}
+
+type WatchChirpsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// last_event_id resumes a stream, replaying what came after the event
+	// with this ID
+	LastEventId   int64 `protobuf:"varint,1,opt,name=last_event_id,json=lastEventId,proto3" json:"last_event_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchChirpsRequest) Reset() {
+	*x = WatchChirpsRequest{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchChirpsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchChirpsRequest) ProtoMessage() {}
+
+func (x *WatchChirpsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchChirpsRequest.ProtoReflect.Descriptor instead.
+func (*WatchChirpsRequest) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *WatchChirpsRequest) GetLastEventId() int64 {
+	if x != nil {
+		return x.LastEventId
+	}
+	return 0
+}
+
+type WatchChirpsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// event_id is what to resume from with last_event_id
+	EventId int64 `protobuf:"varint,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*WatchChirpsResponse_Created
+	//	*WatchChirpsResponse_DeletedId
+	Event         isWatchChirpsResponse_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchChirpsResponse) Reset() {
+	*x = WatchChirpsResponse{}
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchChirpsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchChirpsResponse) ProtoMessage() {}
+
+func (x *WatchChirpsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chirpy_v1_chirps_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchChirpsResponse.ProtoReflect.Descriptor instead.
+func (*WatchChirpsResponse) Descriptor() ([]byte, []int) {
+	return file_chirpy_v1_chirps_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *WatchChirpsResponse) GetEventId() int64 {
+	if x != nil {
+		return x.EventId
+	}
+	return 0
+}
+
+func (x *WatchChirpsResponse) GetEvent() isWatchChirpsResponse_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *WatchChirpsResponse) GetCreated() *Chirp {
+	if x != nil {
+		if x, ok := x.Event.(*WatchChirpsResponse_Created); ok {
+			return x.Created
+		}
+	}
+	return nil
+}
+
+func (x *WatchChirpsResponse) GetDeletedId() string {
+	if x != nil {
+		if x, ok := x.Event.(*WatchChirpsResponse_DeletedId); ok {
+			return x.DeletedId
+		}
+	}
+	return ""
+}
+
+type isWatchChirpsResponse_Event interface {
+	isWatchChirpsResponse_Event()
+}
+
+type WatchChirpsResponse_Created struct {
+	Created *Chirp `protobuf:"bytes,2,opt,name=created,proto3,oneof"`
+}
+
+type WatchChirpsResponse_DeletedId struct {
+	// deleted_id is the ID of a deleted chirp
+	DeletedId string `protobuf:"bytes,3,opt,name=deleted_id,json=deletedId,proto3,oneof"`
+}
+
+func (*WatchChirpsResponse_Created) isWatchChirpsResponse_Event() {}
+
+func (*WatchChirpsResponse_DeletedId) isWatchChirpsResponse_Event() {}
+
+var File_chirpy_v1_chirps_proto protoreflect.FileDescriptor
+
+const file_chirpy_v1_chirps_proto_rawDesc = "" +
+	"\n" +
+	"\x16chirpy/v1/chirps.proto\x12\tchirpy.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xa5\x02\n" +
+	"\x05Chirp\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x129\n" +
+	"\n" +
+	"created_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x12\n" +
+	"\x04body\x18\x04 \x01(\tR\x04body\x12\x17\n" +
+	"\auser_id\x18\x05 \x01(\tR\x06userId\x12.\n" +
+	"\x06status\x18\x06 \x01(\x0e2\x16.chirpy.v1.ChirpStatusR\x06status\x129\n" +
+	"\n" +
+	"publish_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tpublishAt\"y\n" +
+	"\x12CreateChirpRequest\x12\x12\n" +
+	"\x04body\x18\x01 \x01(\tR\x04body\x12\x14\n" +
+	"\x05draft\x18\x02 \x01(\bR\x05draft\x129\n" +
+	"\n" +
+	"publish_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tpublishAt\"=\n" +
+	"\x13CreateChirpResponse\x12&\n" +
+	"\x05chirp\x18\x01 \x01(\v2\x10.chirpy.v1.ChirpR\x05chirp\"!\n" +
+	"\x0fGetChirpRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\":\n" +
+	"\x10GetChirpResponse\x12&\n" +
+	"\x05chirp\x18\x01 \x01(\v2\x10.chirpy.v1.ChirpR\x05chirp\"P\n" +
+	"\x11ListChirpsRequest\x12\x1b\n" +
+	"\tauthor_id\x18\x01 \x01(\tR\bauthorId\x12\x1e\n" +
+	"\n" +
+	"descending\x18\x02 \x01(\bR\n" +
+	"descending\">\n" +
+	"\x12ListChirpsResponse\x12(\n" +
+	"\x06chirps\x18\x01 \x03(\v2\x10.chirpy.v1.ChirpR\x06chirps\"\x13\n" +
+	"\x11ListDraftsRequest\">\n" +
+	"\x12ListDraftsResponse\x12(\n" +
+	"\x06chirps\x18\x01 \x03(\v2\x10.chirpy.v1.ChirpR\x06chirps\"\x89\x01\n" +
+	"\x12UpdateDraftRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04body\x18\x02 \x01(\tR\x04body\x12\x14\n" +
+	"\x05draft\x18\x03 \x01(\bR\x05draft\x129\n" +
+	"\n" +
+	"publish_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tpublishAt\"=\n" +
+	"\x13UpdateDraftResponse\x12&\n" +
+	"\x05chirp\x18\x01 \x01(\v2\x10.chirpy.v1.ChirpR\x05chirp\"$\n" +
+	"\x12DeleteChirpRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x15\n" +
+	"\x13DeleteChirpResponse\"8\n" +
+	"\x12WatchChirpsRequest\x12\"\n" +
+	"\rlast_event_id\x18\x01 \x01(\x03R\vlastEventId\"\x88\x01\n" +
+	"\x13WatchChirpsResponse\x12\x19\n" +
+	"\bevent_id\x18\x01 \x01(\x03R\aeventId\x12,\n" +
+	"\acreated\x18\x02 \x01(\v2\x10.chirpy.v1.ChirpH\x00R\acreated\x12\x1f\n" +
+	"\n" +
+	"deleted_id\x18\x03 \x01(\tH\x00R\tdeletedIdB\a\n" +
+	"\x05event*{\n" +
+	"\vChirpStatus\x12\x1c\n" +
+	"\x18CHIRP_STATUS_UNSPECIFIED\x10\x00\x12\x16\n" +
+	"\x12CHIRP_STATUS_DRAFT\x10\x01\x12\x1a\n" +
+	"\x16CHIRP_STATUS_SCHEDULED\x10\x02\x12\x1a\n" +
+	"\x16CHIRP_STATUS_PUBLISHED\x10\x032\xa3\x04\n" +
+	"\fChirpService\x12L\n" +
+	"\vCreateChirp\x12\x1d.chirpy.v1.CreateChirpRequest\x1a\x1e.chirpy.v1.CreateChirpResponse\x12C\n" +
+	"\bGetChirp\x12\x1a.chirpy.v1.GetChirpRequest\x1a\x1b.chirpy.v1.GetChirpResponse\x12I\n" +
+	"\n" +
+	"ListChirps\x12\x1c.chirpy.v1.ListChirpsRequest\x1a\x1d.chirpy.v1.ListChirpsResponse\x12I\n" +
+	"\n" +
+	"ListDrafts\x12\x1c.chirpy.v1.ListDraftsRequest\x1a\x1d.chirpy.v1.ListDraftsResponse\x12L\n" +
+	"\vUpdateDraft\x12\x1d.chirpy.v1.UpdateDraftRequest\x1a\x1e.chirpy.v1.UpdateDraftResponse\x12L\n" +
+	"\vDeleteChirp\x12\x1d.chirpy.v1.DeleteChirpRequest\x1a\x1e.chirpy.v1.DeleteChirpResponse\x12N\n" +
+	"\vWatchChirps\x12\x1d.chirpy.v1.WatchChirpsRequest\x1a\x1e.chirpy.v1.WatchChirpsResponse0\x01BGZEgithub.com/Brandon-Butterbaugh/Chirbooty.git/proto/chirpy/v1;chirpyv1b\x06proto3"
+
+var (
+	file_chirpy_v1_chirps_proto_rawDescOnce sync.Once
+	file_chirpy_v1_chirps_proto_rawDescData []byte
+)
+
+func file_chirpy_v1_chirps_proto_rawDescGZIP() []byte {
+	file_chirpy_v1_chirps_proto_rawDescOnce.Do(func() {
+		file_chirpy_v1_chirps_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_chirpy_v1_chirps_proto_rawDesc), len(file_chirpy_v1_chirps_proto_rawDesc)))
+	})
+	return file_chirpy_v1_chirps_proto_rawDescData
+}
+
+var file_chirpy_v1_chirps_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_chirpy_v1_chirps_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_chirpy_v1_chirps_proto_goTypes = []any{
+	(ChirpStatus)(0),              // 0: chirpy.v1.ChirpStatus
+	(*Chirp)(nil),                 // 1: chirpy.v1.Chirp
+	(*CreateChirpRequest)(nil),    // 2: chirpy.v1.CreateChirpRequest
+	(*CreateChirpResponse)(nil),   // 3: chirpy.v1.CreateChirpResponse
+	(*GetChirpRequest)(nil),       // 4: chirpy.v1.GetChirpRequest
+	(*GetChirpResponse)(nil),      // 5: chirpy.v1.GetChirpResponse
+	(*ListChirpsRequest)(nil),     // 6: chirpy.v1.ListChirpsRequest
+	(*ListChirpsResponse)(nil),    // 7: chirpy.v1.ListChirpsResponse
+	(*ListDraftsRequest)(nil),     // 8: chirpy.v1.ListDraftsRequest
+	(*ListDraftsResponse)(nil),    // 9: chirpy.v1.ListDraftsResponse
+	(*UpdateDraftRequest)(nil),    // 10: chirpy.v1.UpdateDraftRequest
+	(*UpdateDraftResponse)(nil),   // 11: chirpy.v1.UpdateDraftResponse
+	(*DeleteChirpRequest)(nil),    // 12: chirpy.v1.DeleteChirpRequest
+	(*DeleteChirpResponse)(nil),   // 13: chirpy.v1.DeleteChirpResponse
+	(*WatchChirpsRequest)(nil),    // 14: chirpy.v1.WatchChirpsRequest
+	(*WatchChirpsResponse)(nil),   // 15: chirpy.v1.WatchChirpsResponse
+	(*timestamppb.Timestamp)(nil), // 16: google.protobuf.Timestamp
+}
+var file_chirpy_v1_chirps_proto_depIdxs = []int32{
+	16, // 0: chirpy.v1.Chirp.created_at:type_name -> google.protobuf.Timestamp
+	16, // 1: chirpy.v1.Chirp.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 2: chirpy.v1.Chirp.status:type_name -> chirpy.v1.ChirpStatus
+	16, // 3: chirpy.v1.Chirp.publish_at:type_name -> google.protobuf.Timestamp
+	16, // 4: chirpy.v1.CreateChirpRequest.publish_at:type_name -> google.protobuf.Timestamp
+	1,  // 5: chirpy.v1.CreateChirpResponse.chirp:type_name -> chirpy.v1.Chirp
+	1,  // 6: chirpy.v1.GetChirpResponse.chirp:type_name -> chirpy.v1.Chirp
+	1,  // 7: chirpy.v1.ListChirpsResponse.chirps:type_name -> chirpy.v1.Chirp
+	1,  // 8: chirpy.v1.ListDraftsResponse.chirps:type_name -> chirpy.v1.Chirp
+	16, // 9: chirpy.v1.UpdateDraftRequest.publish_at:type_name -> google.protobuf.Timestamp
+	1,  // 10: chirpy.v1.UpdateDraftResponse.chirp:type_name -> chirpy.v1.Chirp
+	1,  // 11: chirpy.v1.WatchChirpsResponse.created:type_name -> chirpy.v1.Chirp
+	2,  // 12: chirpy.v1.ChirpService.CreateChirp:input_type -> chirpy.v1.CreateChirpRequest
+	4,  // 13: chirpy.v1.ChirpService.GetChirp:input_type -> chirpy.v1.GetChirpRequest
+	6,  // 14: chirpy.v1.ChirpService.ListChirps:input_type -> chirpy.v1.ListChirpsRequest
+	8,  // 15: chirpy.v1.ChirpService.ListDrafts:input_type -> chirpy.v1.ListDraftsRequest
+	10, // 16: chirpy.v1.ChirpService.UpdateDraft:input_type -> chirpy.v1.UpdateDraftRequest
+	12, // 17: chirpy.v1.ChirpService.DeleteChirp:input_type -> chirpy.v1.DeleteChirpRequest
+	14, // 18: chirpy.v1.ChirpService.WatchChirps:input_type -> chirpy.v1.WatchChirpsRequest
+	3,  // 19: chirpy.v1.ChirpService.CreateChirp:output_type -> chirpy.v1.CreateChirpResponse
+	5,  // 20: chirpy.v1.ChirpService.GetChirp:output_type -> chirpy.v1.GetChirpResponse
+	7,  // 21: chirpy.v1.ChirpService.ListChirps:output_type -> chirpy.v1.ListChirpsResponse
+	9,  // 22: chirpy.v1.ChirpService.ListDrafts:output_type -> chirpy.v1.ListDraftsResponse
+	11, // 23: chirpy.v1.ChirpService.UpdateDraft:output_type -> chirpy.v1.UpdateDraftResponse
+	13, // 24: chirpy.v1.ChirpService.DeleteChirp:output_type -> chirpy.v1.DeleteChirpResponse
+	15, // 25: chirpy.v1.ChirpService.WatchChirps:output_type -> chirpy.v1.WatchChirpsResponse
+	19, // [19:26] is the sub-list for method output_type
+	12, // [12:19] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
+}
+
+func init() { file_chirpy_v1_chirps_proto_init() }
+func file_chirpy_v1_chirps_proto_init() {
+	if File_chirpy_v1_chirps_proto != nil {
+		return
+	}
+	file_chirpy_v1_chirps_proto_msgTypes[14].OneofWrappers = []any{
+		(*WatchChirpsResponse_Created)(nil),
+		(*WatchChirpsResponse_DeletedId)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_chirpy_v1_chirps_proto_rawDesc), len(file_chirpy_v1_chirps_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_chirpy_v1_chirps_proto_goTypes,
+		DependencyIndexes: file_chirpy_v1_chirps_proto_depIdxs,
+		EnumInfos:         file_chirpy_v1_chirps_proto_enumTypes,
+		MessageInfos:      file_chirpy_v1_chirps_proto_msgTypes,
+	}.Build()
+	File_chirpy_v1_chirps_proto = out.File
+	file_chirpy_v1_chirps_proto_goTypes = nil
+	file_chirpy_v1_chirps_proto_depIdxs = nil
+}
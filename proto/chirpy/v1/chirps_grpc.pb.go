@@ -0,0 +1,377 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: chirpy/v1/chirps.proto
+
+package chirpyv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ChirpService_CreateChirp_FullMethodName = "/chirpy.v1.ChirpService/CreateChirp"
+	ChirpService_GetChirp_FullMethodName    = "/chirpy.v1.ChirpService/GetChirp"
+	ChirpService_ListChirps_FullMethodName  = "/chirpy.v1.ChirpService/ListChirps"
+	ChirpService_ListDrafts_FullMethodName  = "/chirpy.v1.ChirpService/ListDrafts"
+	ChirpService_UpdateDraft_FullMethodName = "/chirpy.v1.ChirpService/UpdateDraft"
+	ChirpService_DeleteChirp_FullMethodName = "/chirpy.v1.ChirpService/DeleteChirp"
+	ChirpService_WatchChirps_FullMethodName = "/chirpy.v1.ChirpService/WatchChirps"
+)
+
+// ChirpServiceClient is the client API for ChirpService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ChirpService mirrors /api/chirps and /api/drafts, and streams the
+// timeline like /api/stream.
+type ChirpServiceClient interface {
+	// CreateChirp publishes a chirp, saves it as a draft, or schedules it.
+	CreateChirp(ctx context.Context, in *CreateChirpRequest, opts ...grpc.CallOption) (*CreateChirpResponse, error)
+	// GetChirp returns a published chirp, unless the caller and its author
+	// have blocked each other.
+	GetChirp(ctx context.Context, in *GetChirpRequest, opts ...grpc.CallOption) (*GetChirpResponse, error)
+	// ListChirps returns published chirps, leaving out those the caller has
+	// blocked or muted. Signing in is optional.
+	ListChirps(ctx context.Context, in *ListChirpsRequest, opts ...grpc.CallOption) (*ListChirpsResponse, error)
+	// ListDrafts returns the caller's drafts and scheduled chirps.
+	ListDrafts(ctx context.Context, in *ListDraftsRequest, opts ...grpc.CallOption) (*ListDraftsResponse, error)
+	// UpdateDraft edits a chirp that isn't published yet.
+	UpdateDraft(ctx context.Context, in *UpdateDraftRequest, opts ...grpc.CallOption) (*UpdateDraftResponse, error)
+	DeleteChirp(ctx context.Context, in *DeleteChirpRequest, opts ...grpc.CallOption) (*DeleteChirpResponse, error)
+	// WatchChirps streams chirps as they're published and deleted, until the
+	// caller hangs up.
+	WatchChirps(ctx context.Context, in *WatchChirpsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchChirpsResponse], error)
+}
+
+type chirpServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewChirpServiceClient(cc grpc.ClientConnInterface) ChirpServiceClient {
+	return &chirpServiceClient{cc}
+}
+
+func (c *chirpServiceClient) CreateChirp(ctx context.Context, in *CreateChirpRequest, opts ...grpc.CallOption) (*CreateChirpResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateChirpResponse)
+	err := c.cc.Invoke(ctx, ChirpService_CreateChirp_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chirpServiceClient) GetChirp(ctx context.Context, in *GetChirpRequest, opts ...grpc.CallOption) (*GetChirpResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetChirpResponse)
+	err := c.cc.Invoke(ctx, ChirpService_GetChirp_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chirpServiceClient) ListChirps(ctx context.Context, in *ListChirpsRequest, opts ...grpc.CallOption) (*ListChirpsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListChirpsResponse)
+	err := c.cc.Invoke(ctx, ChirpService_ListChirps_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chirpServiceClient) ListDrafts(ctx context.Context, in *ListDraftsRequest, opts ...grpc.CallOption) (*ListDraftsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDraftsResponse)
+	err := c.cc.Invoke(ctx, ChirpService_ListDrafts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chirpServiceClient) UpdateDraft(ctx context.Context, in *UpdateDraftRequest, opts ...grpc.CallOption) (*UpdateDraftResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateDraftResponse)
+	err := c.cc.Invoke(ctx, ChirpService_UpdateDraft_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chirpServiceClient) DeleteChirp(ctx context.Context, in *DeleteChirpRequest, opts ...grpc.CallOption) (*DeleteChirpResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteChirpResponse)
+	err := c.cc.Invoke(ctx, ChirpService_DeleteChirp_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chirpServiceClient) WatchChirps(ctx context.Context, in *WatchChirpsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchChirpsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ChirpService_ServiceDesc.Streams[0], ChirpService_WatchChirps_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchChirpsRequest, WatchChirpsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChirpService_WatchChirpsClient = grpc.ServerStreamingClient[WatchChirpsResponse]
+
+// ChirpServiceServer is the server API for ChirpService service.
+// All implementations must embed UnimplementedChirpServiceServer
+// for forward compatibility.
+//
+// ChirpService mirrors /api/chirps and /api/drafts, and streams the
+// timeline like /api/stream.
+type ChirpServiceServer interface {
+	// CreateChirp publishes a chirp, saves it as a draft, or schedules it.
+	CreateChirp(context.Context, *CreateChirpRequest) (*CreateChirpResponse, error)
+	// GetChirp returns a published chirp, unless the caller and its author
+	// have blocked each other.
+	GetChirp(context.Context, *GetChirpRequest) (*GetChirpResponse, error)
+	// ListChirps returns published chirps, leaving out those the caller has
+	// blocked or muted. Signing in is optional.
+	ListChirps(context.Context, *ListChirpsRequest) (*ListChirpsResponse, error)
+	// ListDrafts returns the caller's drafts and scheduled chirps.
+	ListDrafts(context.Context, *ListDraftsRequest) (*ListDraftsResponse, error)
+	// UpdateDraft edits a chirp that isn't published yet.
+	UpdateDraft(context.Context, *UpdateDraftRequest) (*UpdateDraftResponse, error)
+	DeleteChirp(context.Context, *DeleteChirpRequest) (*DeleteChirpResponse, error)
+	// WatchChirps streams chirps as they're published and deleted, until the
+	// caller hangs up.
+	WatchChirps(*WatchChirpsRequest, grpc.ServerStreamingServer[WatchChirpsResponse]) error
+	mustEmbedUnimplementedChirpServiceServer()
+}
+
+// UnimplementedChirpServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedChirpServiceServer struct{}
+
+func (UnimplementedChirpServiceServer) CreateChirp(context.Context, *CreateChirpRequest) (*CreateChirpResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateChirp not implemented")
+}
+func (UnimplementedChirpServiceServer) GetChirp(context.Context, *GetChirpRequest) (*GetChirpResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChirp not implemented")
+}
+func (UnimplementedChirpServiceServer) ListChirps(context.Context, *ListChirpsRequest) (*ListChirpsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListChirps not implemented")
+}
+func (UnimplementedChirpServiceServer) ListDrafts(context.Context, *ListDraftsRequest) (*ListDraftsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDrafts not implemented")
+}
+func (UnimplementedChirpServiceServer) UpdateDraft(context.Context, *UpdateDraftRequest) (*UpdateDraftResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateDraft not implemented")
+}
+func (UnimplementedChirpServiceServer) DeleteChirp(context.Context, *DeleteChirpRequest) (*DeleteChirpResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteChirp not implemented")
+}
+func (UnimplementedChirpServiceServer) WatchChirps(*WatchChirpsRequest, grpc.ServerStreamingServer[WatchChirpsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchChirps not implemented")
+}
+func (UnimplementedChirpServiceServer) mustEmbedUnimplementedChirpServiceServer() {}
+func (UnimplementedChirpServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeChirpServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ChirpServiceServer will
+// result in compilation errors.
+type UnsafeChirpServiceServer interface {
+	mustEmbedUnimplementedChirpServiceServer()
+}
+
+func RegisterChirpServiceServer(s grpc.ServiceRegistrar, srv ChirpServiceServer) {
+	// If the following call pancis, it indicates UnimplementedChirpServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ChirpService_ServiceDesc, srv)
+}
+
+func _ChirpService_CreateChirp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateChirpRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChirpServiceServer).CreateChirp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChirpService_CreateChirp_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChirpServiceServer).CreateChirp(ctx, req.(*CreateChirpRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChirpService_GetChirp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChirpRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChirpServiceServer).GetChirp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChirpService_GetChirp_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChirpServiceServer).GetChirp(ctx, req.(*GetChirpRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChirpService_ListChirps_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListChirpsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChirpServiceServer).ListChirps(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChirpService_ListChirps_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChirpServiceServer).ListChirps(ctx, req.(*ListChirpsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChirpService_ListDrafts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDraftsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChirpServiceServer).ListDrafts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChirpService_ListDrafts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChirpServiceServer).ListDrafts(ctx, req.(*ListDraftsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChirpService_UpdateDraft_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateDraftRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChirpServiceServer).UpdateDraft(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChirpService_UpdateDraft_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChirpServiceServer).UpdateDraft(ctx, req.(*UpdateDraftRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChirpService_DeleteChirp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteChirpRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChirpServiceServer).DeleteChirp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChirpService_DeleteChirp_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChirpServiceServer).DeleteChirp(ctx, req.(*DeleteChirpRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChirpService_WatchChirps_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchChirpsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChirpServiceServer).WatchChirps(m, &grpc.GenericServerStream[WatchChirpsRequest, WatchChirpsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChirpService_WatchChirpsServer = grpc.ServerStreamingServer[WatchChirpsResponse]
+
+// ChirpService_ServiceDesc is the grpc.ServiceDesc for ChirpService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ChirpService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chirpy.v1.ChirpService",
+	HandlerType: (*ChirpServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateChirp",
+			Handler:    _ChirpService_CreateChirp_Handler,
+		},
+		{
+			MethodName: "GetChirp",
+			Handler:    _ChirpService_GetChirp_Handler,
+		},
+		{
+			MethodName: "ListChirps",
+			Handler:    _ChirpService_ListChirps_Handler,
+		},
+		{
+			MethodName: "ListDrafts",
+			Handler:    _ChirpService_ListDrafts_Handler,
+		},
+		{
+			MethodName: "UpdateDraft",
+			Handler:    _ChirpService_UpdateDraft_Handler,
+		},
+		{
+			MethodName: "DeleteChirp",
+			Handler:    _ChirpService_DeleteChirp_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchChirps",
+			Handler:       _ChirpService_WatchChirps_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "chirpy/v1/chirps.proto",
+}
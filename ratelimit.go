@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/auth"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/ratelimit"
+	"github.com/google/uuid"
+)
+
+// rateLimitPolicy is the budget for one group of routes. Each client gets a
+// bucket per policy: signed-in users by their ID, everyone else by IP.
+type rateLimitPolicy struct {
+	name  string
+	limit ratelimit.Limit
+	// red replaces limit for Chirpy Red members, if it's set
+	red ratelimit.Limit
+}
+
+var (
+	signupPolicy = rateLimitPolicy{
+		name:  "signup",
+		limit: ratelimit.Limit{Burst: 5, Per: time.Hour},
+	}
+	// loginPolicy slows down password guessing
+	loginPolicy = rateLimitPolicy{
+		name:  "login",
+		limit: ratelimit.Limit{Burst: 10, Per: 10 * time.Minute},
+	}
+	chirpPolicy = rateLimitPolicy{
+		name:  "chirps",
+		limit: ratelimit.Limit{Burst: 20, Per: 10 * time.Minute},
+		red:   ratelimit.Limit{Burst: 100, Per: 10 * time.Minute},
+	}
+	messagePolicy = rateLimitPolicy{
+		name:  "messages",
+		limit: ratelimit.Limit{Burst: 60, Per: 10 * time.Minute},
+		red:   ratelimit.Limit{Burst: 300, Per: 10 * time.Minute},
+	}
+)
+
+// rateLimit serves next within the policy's budget, and refuses with 429
+// Too Many Requests once the client has spent it. Every response says where
+// the client stands in RateLimit-* headers. If the limiter's store fails,
+// requests are let through rather than taking the API down with it.
+func (cfg *apiConfig) rateLimit(policy rateLimitPolicy, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.limiter == nil {
+			next(w, r)
+			return
+		}
+
+		key, limit := cfg.rateLimitKey(r.Context(), r.Header, r.RemoteAddr, policy)
+		res, err := cfg.limiter.Allow(r.Context(), policy.name+":"+key, limit)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Couldn't check rate limit", "policy", policy.name, "err", err)
+			next(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, int(limit.Per.Seconds())))
+		h.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", seconds(res.Reset))
+		if !res.Allowed {
+			cfg.metrics.RateLimited.WithLabelValues(policy.name).Inc()
+			h.Set("Retry-After", seconds(res.RetryAfter))
+			respondWithError(w, r, errRateLimited, "Too many requests, try again later", nil)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// rateLimitKey picks whose bucket a request or RPC comes out of, and how
+// big it is. A token that doesn't validate is treated as no token; the
+// handler rejects it afterwards.
+func (cfg *apiConfig) rateLimitKey(ctx context.Context, header http.Header, remoteAddr string, policy rateLimitPolicy) (string, ratelimit.Limit) {
+	token, err := auth.GetBearerToken(header)
+	if err != nil {
+		return "ip:" + cfg.clientIP(header, remoteAddr), policy.limit
+	}
+	id, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return "ip:" + cfg.clientIP(header, remoteAddr), policy.limit
+	}
+	key := "user:" + id.String()
+	if policy.red.Burst == 0 || !cfg.isRed(ctx, id) {
+		return key, policy.limit
+	}
+	return key, policy.red
+}
+
+func (cfg *apiConfig) isRed(ctx context.Context, id uuid.UUID) bool {
+	user, err := cfg.database.GetUserFromID(ctx, id)
+	if err != nil {
+		return false
+	}
+	return user.IsChirpyRed
+}
+
+// clientIP is the address the request came from. Behind a proxy that's the
+// last X-Forwarded-For entry, the one our proxy added; earlier entries come
+// from the client and can't be trusted.
+func (cfg *apiConfig) clientIP(header http.Header, remoteAddr string) string {
+	if cfg.trustForwardedFor {
+		if forwarded := header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			last := forwarded[len(forwarded)-1]
+			if i := strings.LastIndex(last, ","); i >= 0 {
+				last = last[i+1:]
+			}
+			if ip := strings.TrimSpace(last); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// seconds rounds up, so a client that waits as long as it's told is never
+// refused for being a moment early.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
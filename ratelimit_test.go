@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/ratelimit"
+)
+
+func TestRateLimitChirps(t *testing.T) {
+	tests := []struct {
+		name  string
+		red   bool
+		burst int
+	}{
+		{name: "Free", burst: chirpPolicy.limit.Burst},
+		{name: "Chirpy Red", red: true, burst: chirpPolicy.red.Burst},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			user := ts.signUp("walt@example.com")
+			other := ts.signUp("jesse@example.com")
+			if tt.red {
+				_, err := ts.store.SetUserRed(context.Background(), database.SetUserRedParams{ID: user.ID, IsChirpyRed: true})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			ts.cfg.limiter = ratelimit.New(ratelimit.NewMemoryStore())
+
+			for i := range tt.burst {
+				resp := ts.do("POST", "/api/chirps", user.Token, map[string]string{"body": "Say my name"})
+				expect[Chirp](t, resp, http.StatusCreated)
+				if got, want := resp.Header.Get("RateLimit-Remaining"), strconv.Itoa(tt.burst-i-1); got != want {
+					t.Fatalf("chirp %d: RateLimit-Remaining got %q, want %q", i, got, want)
+				}
+			}
+
+			resp := ts.do("POST", "/api/chirps", user.Token, map[string]string{"body": "Say my name"})
+			expect[any](t, resp, http.StatusTooManyRequests)
+			if got, want := resp.Header.Get("RateLimit-Limit"), strconv.Itoa(tt.burst); got != want {
+				t.Errorf("RateLimit-Limit got %q, want %q", got, want)
+			}
+			retry, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+			if err != nil || retry < 1 {
+				t.Errorf("Retry-After got %q, want a positive number of seconds", resp.Header.Get("Retry-After"))
+			}
+
+			// Other users have their own budget
+			ts.chirp(other.Token, "Yeah, science!")
+		})
+	}
+}
+
+func TestRateLimitLogin(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signUp("walt@example.com")
+	ts.cfg.limiter = ratelimit.New(ratelimit.NewMemoryStore())
+	ts.cfg.trustForwardedFor = true
+
+	login := func(forwardedFor string) *http.Response {
+		req, err := http.NewRequest("POST", ts.URL+"/api/login", jsonBody(t, map[string]string{
+			"email":    "walt@example.com",
+			"password": "wrongPassword",
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	for range loginPolicy.limit.Burst {
+		expect[any](t, login("203.0.113.7"), http.StatusUnauthorized)
+	}
+	expect[any](t, login("203.0.113.7"), http.StatusTooManyRequests)
+	// Only the entry our proxy added counts, so clients can't pick their
+	// own bucket
+	expect[any](t, login("198.51.100.1, 203.0.113.7"), http.StatusTooManyRequests)
+	expect[any](t, login("198.51.100.1"), http.StatusUnauthorized)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/logging"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs taken from clients, which end up in
+// every log line for the request.
+const maxRequestIDLength = 128
+
+// logRequests gives every request an ID, a logger that carries it and a
+// server span, and writes one access log line per request once it's served,
+// which also feeds the request metrics. A request ID or W3C trace context
+// sent by the client or a proxy in front of us is kept, so logs and traces
+// can be followed across services.
+func (cfg *apiConfig) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		// The span is named for the route once the mux has matched one
+		ctx, span := tracing.Tracer().Start(tracing.Extract(r.Context(), r.Header), r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method)),
+		)
+		defer span.End()
+
+		logger := slog.Default().With("request_id", id)
+		if sc := span.SpanContext(); sc.HasTraceID() {
+			logger = logger.With("trace_id", sc.TraceID().String())
+		}
+		ctx = logging.NewContext(ctx, logger)
+		r = r.WithContext(ctx)
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		// The mux fills in the pattern it matched, and handlers that
+		// authenticate have added the user ID by now
+		latency := time.Since(start)
+		level := slog.LevelInfo
+		if sw.status >= 500 {
+			level = slog.LevelError
+			span.SetStatus(codes.Error, http.StatusText(sw.status))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sw.Status()))
+		if r.Pattern != "" {
+			route := routePath(r.Pattern)
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		logging.FromContext(ctx).LogAttrs(ctx, level, "request",
+			slog.String("method", r.Method),
+			slog.String("route", r.Pattern),
+			slog.Int("status", sw.Status()),
+			slog.Duration("latency", latency),
+		)
+		cfg.metrics.ObserveRequest(r.Pattern, sw.Status(), latency)
+	})
+}
+
+// setRequestUser records the authenticated user in the request's logs and
+// span.
+func setRequestUser(ctx context.Context, id uuid.UUID) {
+	logging.With(ctx, "user_id", id)
+	tracing.SetUser(ctx, id.String())
+}
+
+// routePath drops the method from a mux pattern such as
+// "GET /api/chirps/{chirpID}", leaving the route as traces expect it.
+func routePath(pattern string) string {
+	_, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return pattern
+	}
+	return path
+}
+
+// validRequestID accepts IDs short enough and plain enough to log as they
+// are.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// statusWriter records the status code a handler responds with.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Status is the code sent, which is 200 if the handler wrote nothing.
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, which the
+// event stream needs to flush.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack is called directly by the WebSocket upgrader, which doesn't go
+// through http.ResponseController.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
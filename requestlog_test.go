@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// syncBuffer lets the server goroutines and the test share a log buffer.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// accessLogs returns the access log lines written so far.
+func (b *syncBuffer) accessLogs(t *testing.T) []map[string]any {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var lines []map[string]any
+	for line := range strings.Lines(b.buf.String()) {
+		var entry map[string]any
+		err := json.Unmarshal([]byte(line), &entry)
+		if err != nil {
+			t.Fatalf("Log line %q isn't JSON: %v", line, err)
+		}
+		if entry["msg"] == "request" {
+			lines = append(lines, entry)
+		}
+	}
+	return lines
+}
+
+func TestLogRequests(t *testing.T) {
+	logs := &syncBuffer{}
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+
+	tests := []struct {
+		name      string
+		requestID string
+		token     string
+		path      string
+		wantRoute string
+		wantCode  int
+		wantUser  bool
+	}{
+		{
+			name:      "Propagates the request ID",
+			requestID: "from-the-proxy",
+			path:      "/api/healthz",
+			wantRoute: "GET /api/healthz",
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "Records the user",
+			token:     walt.Token,
+			path:      "/api/drafts",
+			wantRoute: "GET /api/drafts",
+			wantCode:  http.StatusOK,
+			wantUser:  true,
+		},
+		{
+			name:      "Replaces an unusable request ID",
+			requestID: "has spaces",
+			path:      "/api/drafts",
+			wantRoute: "GET /api/drafts",
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:     "Unknown route",
+			path:     "/api/nope",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", ts.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.requestID != "" {
+				req.Header.Set(requestIDHeader, tt.requestID)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			id := resp.Header.Get(requestIDHeader)
+			if tt.requestID != "" && validRequestID(tt.requestID) && id != tt.requestID {
+				t.Errorf("got request ID %q, want %q", id, tt.requestID)
+			}
+			if !validRequestID(id) {
+				t.Fatalf("got request ID %q", id)
+			}
+
+			// The access log line is written after the response, so it may
+			// take a moment
+			var entry map[string]any
+			deadline := time.Now().Add(time.Second)
+			for entry == nil && time.Now().Before(deadline) {
+				for _, line := range logs.accessLogs(t) {
+					if line["request_id"] == id {
+						entry = line
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			if entry == nil {
+				t.Fatalf("no access log for request %s", id)
+			}
+			if entry["route"] != tt.wantRoute || entry["status"] != float64(tt.wantCode) || entry["method"] != "GET" {
+				t.Errorf("got access log %v", entry)
+			}
+			if _, ok := entry["latency"]; !ok {
+				t.Errorf("access log has no latency: %v", entry)
+			}
+			if got := entry["user_id"] == walt.ID.String(); got != tt.wantUser {
+				t.Errorf("access log user_id = %v, want user logged %v", entry["user_id"], tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestTraceRequests(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+	tests := []struct {
+		name        string
+		traceparent string
+		token       string
+		path        string
+		wantName    string
+		wantRoute   string
+		wantCode    int
+		wantUser    bool
+	}{
+		{
+			name:        "Continues the caller's trace",
+			traceparent: "00-" + traceID + "-" + parentID + "-01",
+			path:        "/api/healthz",
+			wantName:    "GET /api/healthz",
+			wantRoute:   "/api/healthz",
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:      "Records the user",
+			token:     walt.Token,
+			path:      "/api/drafts",
+			wantName:  "GET /api/drafts",
+			wantRoute: "/api/drafts",
+			wantCode:  http.StatusOK,
+			wantUser:  true,
+		},
+		{
+			name:     "Unknown route",
+			path:     "/api/nope",
+			wantName: "GET",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", ts.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.traceparent != "" {
+				req.Header.Set("Traceparent", tt.traceparent)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			before := len(recorder.Ended())
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			// The span ends after the response is written, so it may take a
+			// moment
+			deadline := time.Now().Add(time.Second)
+			for len(recorder.Ended()) == before && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			spans := recorder.Ended()
+			if len(spans) != before+1 {
+				t.Fatalf("got %d new spans, want 1", len(spans)-before)
+			}
+			span := spans[before]
+
+			if span.Name() != tt.wantName || span.SpanKind() != trace.SpanKindServer {
+				t.Errorf("got %v span %q, want server span %q", span.SpanKind(), span.Name(), tt.wantName)
+			}
+			if tt.traceparent != "" {
+				if span.SpanContext().TraceID().String() != traceID || span.Parent().SpanID().String() != parentID {
+					t.Errorf("span isn't a child of %s in trace %s", parentID, traceID)
+				}
+			}
+			attrs := attribute.NewSet(span.Attributes()...)
+			if v, _ := attrs.Value(semconv.HTTPRouteKey); v.AsString() != tt.wantRoute {
+				t.Errorf("got http.route %q, want %q", v.AsString(), tt.wantRoute)
+			}
+			if v, _ := attrs.Value(semconv.HTTPResponseStatusCodeKey); v.AsInt64() != int64(tt.wantCode) {
+				t.Errorf("got status %d, want %d", v.AsInt64(), tt.wantCode)
+			}
+			v, _ := attrs.Value(semconv.UserIDKey)
+			if got := v.AsString() == walt.ID.String(); got != tt.wantUser {
+				t.Errorf("user.id = %q, want user recorded %v", v.AsString(), tt.wantUser)
+			}
+		})
+	}
+}
@@ -6,18 +6,21 @@ import (
 
 func (cfg *apiConfig) Reset(w http.ResponseWriter, r *http.Request) {
 	if cfg.platform != "dev" {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("Reset is only allowed in dev environment."))
+		respondWithError(w, r, errForbidden, "Reset is only allowed in dev environment", nil)
 		return
-	} else {
-		cfg.fileserverHits.Store(0)
-		err := cfg.database.ResetUsers(r.Context())
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Failed to reset the database: " + err.Error()))
-			return
-		}
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Hits reset to 0 and database reset to initial state."))
 	}
+
+	cfg.fileserverHits.Store(0)
+	err := cfg.database.ResetConversations(r.Context())
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't reset the database", err)
+		return
+	}
+	err = cfg.database.ResetUsers(r.Context())
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't reset the database", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Hits reset to 0 and database reset to initial state."))
 }
@@ -1,26 +1,130 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status, publish_at)
 VALUES (
-    gen_random_uuid(), NOW(), NOW(), $1, $2
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4
 )
 RETURNING *;
 
 -- name: GetChirps :many
 SELECT *
 FROM chirps
+WHERE status = 'published'
+AND NOT EXISTS (
+    SELECT 1
+    FROM blocks
+    WHERE (blocks.blocker_id = sqlc.arg(viewer_id) AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id))
+)
+AND NOT EXISTS (
+    SELECT 1
+    FROM mutes
+    WHERE mutes.muter_id = sqlc.arg(viewer_id) AND mutes.muted_id = chirps.user_id
+)
 ORDER BY created_at;
 
 -- name: GetAuthorChirps :many
 SELECT *
 FROM chirps
-WHERE user_id = $1
+WHERE user_id = sqlc.arg(user_id)
+AND status = 'published'
+AND NOT EXISTS (
+    SELECT 1
+    FROM blocks
+    WHERE (blocks.blocker_id = sqlc.arg(viewer_id) AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id))
+)
+AND NOT EXISTS (
+    SELECT 1
+    FROM mutes
+    WHERE mutes.muter_id = sqlc.arg(viewer_id) AND mutes.muted_id = chirps.user_id
+)
 ORDER BY created_at;
 
+-- name: GetAuthorsChirps :many
+-- GetAuthorChirps for several authors at once, so GraphQL can batch them.
+-- Each author gets at most per_author chirps, the oldest or the newest.
+SELECT *
+FROM chirps
+WHERE id IN (
+    SELECT ranked.id
+    FROM (
+        SELECT visible.id,
+            CASE WHEN sqlc.arg(newest_first)::boolean
+                THEN ROW_NUMBER() OVER (PARTITION BY visible.user_id ORDER BY visible.created_at DESC, visible.id DESC)
+                ELSE ROW_NUMBER() OVER (PARTITION BY visible.user_id ORDER BY visible.created_at, visible.id)
+            END <= sqlc.arg(per_author)::int AS on_page
+        FROM chirps visible
+        WHERE visible.status = 'published'
+        AND NOT EXISTS (
+            SELECT 1
+            FROM blocks
+            WHERE (blocks.blocker_id = sqlc.arg(viewer_id) AND blocks.blocked_id = visible.user_id)
+               OR (blocks.blocker_id = visible.user_id AND blocks.blocked_id = sqlc.arg(viewer_id))
+        )
+        AND NOT EXISTS (
+            SELECT 1
+            FROM mutes
+            WHERE mutes.muter_id = sqlc.arg(viewer_id) AND mutes.muted_id = visible.user_id
+        )
+        AND visible.user_id = ANY(sqlc.arg(user_ids)::uuid[])
+    ) ranked
+    WHERE ranked.on_page
+)
+ORDER BY created_at, id;
+
 -- name: GetChirp :one
 SELECT *
 FROM chirps
 WHERE id = $1;
 
+-- name: GetVisibleChirp :one
+SELECT *
+FROM chirps
+WHERE id = sqlc.arg(id)
+AND status = 'published'
+AND NOT EXISTS (
+    SELECT 1
+    FROM blocks
+    WHERE (blocks.blocker_id = sqlc.arg(viewer_id) AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id))
+);
+
+-- name: GetDrafts :many
+SELECT *
+FROM chirps
+WHERE user_id = $1
+AND status <> 'published'
+ORDER BY created_at;
+
+-- name: UpdateDraft :one
+-- A draft enters the timeline when it's published, not when it was written.
+UPDATE chirps
+SET body = sqlc.arg(body),
+    status = sqlc.arg(status),
+    publish_at = sqlc.arg(publish_at),
+    created_at = CASE WHEN sqlc.arg(status) = 'published' THEN NOW() ELSE created_at END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+AND status <> 'published'
+RETURNING *;
+
+-- name: PublishDueChirp :one
+-- Only one caller gets the row back, however many race to publish it, and a
+-- chirp that was edited, rescheduled or deleted since is left alone.
+UPDATE chirps
+SET status = 'published',
+    created_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+AND status = 'scheduled'
+AND publish_at <= NOW()
+RETURNING *;
+
 -- name: DeleteChirp :exec
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: DeleteUserChirps :many
+DELETE FROM chirps
+WHERE user_id = $1
+RETURNING id;
@@ -0,0 +1,50 @@
+-- name: CreateJob :exec
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, max_attempts, run_at, unique_key)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5
+)
+ON CONFLICT (unique_key) DO NOTHING;
+
+-- name: ClaimJob :one
+-- A job is due once run_at has passed. A running job whose lease has lapsed
+-- belonged to a worker that crashed or hung, so it is due again too.
+UPDATE jobs
+SET state = 'running', attempts = attempts + 1, locked_until = $1, updated_at = NOW()
+WHERE id = (
+    SELECT id
+    FROM jobs
+    WHERE (state = 'pending' AND run_at <= NOW())
+       OR (state = 'running' AND locked_until < NOW())
+    ORDER BY run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: DeleteJob :exec
+-- DeleteJob, RetryJob and KillJob match on attempts as well as id, so a
+-- worker whose lease lapsed can't touch a job another worker has claimed.
+DELETE FROM jobs
+WHERE id = $1 AND attempts = $2;
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET state = 'pending', run_at = $3, last_error = $4, locked_until = NULL, updated_at = NOW()
+WHERE id = $1 AND attempts = $2;
+
+-- name: KillJob :exec
+UPDATE jobs
+SET state = 'dead', last_error = $3, locked_until = NULL, updated_at = NOW()
+WHERE id = $1 AND attempts = $2;
+
+-- name: GetDeadJobs :many
+SELECT *
+FROM jobs
+WHERE state = 'dead'
+ORDER BY updated_at;
+
+-- name: RequeueDeadJob :one
+UPDATE jobs
+SET state = 'pending', attempts = 0, run_at = NOW(), last_error = NULL, updated_at = NOW()
+WHERE id = $1 AND state = 'dead'
+RETURNING *;
@@ -0,0 +1,109 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at)
+VALUES (
+    gen_random_uuid(), NOW(), NOW()
+)
+RETURNING *;
+
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+VALUES (
+    $1, $2, NOW()
+);
+
+-- name: GetConversationParticipant :one
+SELECT *
+FROM conversation_participants
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: GetConversationsParticipants :many
+SELECT *
+FROM conversation_participants
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY joined_at;
+
+-- name: FindDirectConversation :one
+SELECT conversations.*
+FROM conversations
+JOIN conversation_participants a
+    ON a.conversation_id = conversations.id AND a.user_id = sqlc.arg(user_a)
+JOIN conversation_participants b
+    ON b.conversation_id = conversations.id AND b.user_id = sqlc.arg(user_b)
+WHERE (
+    SELECT COUNT(*)
+    FROM conversation_participants p
+    WHERE p.conversation_id = conversations.id
+) = 2
+LIMIT 1;
+
+-- name: GetUserConversations :many
+SELECT conversations.*,
+    (
+        SELECT COUNT(*)
+        FROM messages
+        WHERE messages.conversation_id = conversations.id
+          AND messages.sender_id <> conversation_participants.user_id
+          AND (conversation_participants.last_read_at IS NULL OR messages.created_at > conversation_participants.last_read_at)
+          AND (conversation_participants.cleared_at IS NULL OR messages.created_at > conversation_participants.cleared_at)
+    ) AS unread_count
+FROM conversations
+JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id
+WHERE conversation_participants.user_id = $1
+  AND (conversation_participants.cleared_at IS NULL OR conversations.updated_at > conversation_participants.cleared_at)
+ORDER BY conversations.updated_at DESC;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: ConversationHasBlock :one
+SELECT EXISTS (
+    SELECT 1
+    FROM conversation_participants
+    JOIN blocks
+        ON (blocks.blocker_id = sqlc.arg(user_id) AND blocks.blocked_id = conversation_participants.user_id)
+        OR (blocks.blocked_id = sqlc.arg(user_id) AND blocks.blocker_id = conversation_participants.user_id)
+    WHERE conversation_participants.conversation_id = sqlc.arg(conversation_id)
+);
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, updated_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetConversationMessages :many
+SELECT messages.*
+FROM messages
+JOIN conversation_participants ON conversation_participants.conversation_id = messages.conversation_id
+WHERE messages.conversation_id = sqlc.arg(conversation_id)
+  AND conversation_participants.user_id = sqlc.arg(user_id)
+  AND (conversation_participants.cleared_at IS NULL OR messages.created_at > conversation_participants.cleared_at)
+ORDER BY messages.created_at;
+
+-- name: MarkConversationRead :execrows
+-- Marks read everything up to the given message, the newest the reader has
+-- seen. NOW() would be the time the transaction started, so it could cover
+-- a message committed meanwhile that the reader never saw. last_read_at
+-- never moves back, and no row changes if the message isn't in the
+-- conversation.
+UPDATE conversation_participants
+SET last_read_at = CASE
+    WHEN conversation_participants.last_read_at > messages.created_at THEN conversation_participants.last_read_at
+    ELSE messages.created_at
+END
+FROM messages
+WHERE conversation_participants.conversation_id = sqlc.arg(conversation_id)
+  AND conversation_participants.user_id = sqlc.arg(user_id)
+  AND messages.id = sqlc.arg(message_id)
+  AND messages.conversation_id = conversation_participants.conversation_id;
+
+-- name: ClearConversation :exec
+UPDATE conversation_participants
+SET cleared_at = NOW(), last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: ResetConversations :exec
+DELETE FROM conversations;
@@ -0,0 +1,73 @@
+-- name: UpsertNotification :one
+INSERT INTO notifications (id, created_at, updated_at, user_id, type, subject_id)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3
+)
+ON CONFLICT (user_id, type, subject_id) WHERE read_at IS NULL
+DO UPDATE SET updated_at = NOW()
+RETURNING *;
+
+-- name: AddNotificationActor :exec
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+VALUES (
+    $1, $2, NOW()
+)
+ON CONFLICT (notification_id, actor_id)
+DO UPDATE SET created_at = NOW();
+
+-- name: GetNotifications :many
+SELECT notifications.*,
+    (
+        SELECT COUNT(*)
+        FROM notification_actors
+        WHERE notification_actors.notification_id = notifications.id
+    ) AS actor_count,
+    ARRAY(
+        SELECT notification_actors.actor_id
+        FROM notification_actors
+        WHERE notification_actors.notification_id = notifications.id
+        ORDER BY notification_actors.created_at DESC
+        LIMIT 3
+    )::uuid[] AS recent_actors
+FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+  AND (NOT sqlc.arg(unread_only)::boolean OR read_at IS NULL)
+  AND (updated_at, id) < (sqlc.arg(before_time)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY updated_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*)
+FROM notifications
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :many
+SELECT *
+FROM notification_preferences
+WHERE user_id = $1
+ORDER BY type;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES (
+    $1, $2, $3, NOW()
+)
+ON CONFLICT (user_id, type)
+DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW();
+
+-- name: NotificationEnabled :one
+SELECT COALESCE((
+    SELECT enabled
+    FROM notification_preferences
+    WHERE user_id = $1 AND type = $2
+), TRUE)::boolean AS enabled;
@@ -0,0 +1,18 @@
+-- name: TakeRateLimit :one
+-- Takes one token from the bucket for key, refilling it for the time since
+-- it was last touched. No row comes back when the bucket is empty, and then
+-- nothing changes.
+INSERT INTO rate_limits (key, full_at)
+VALUES (sqlc.arg(key), sqlc.arg(now)::bigint + sqlc.arg(cost)::bigint)
+ON CONFLICT (key) DO UPDATE
+SET full_at = GREATEST(rate_limits.full_at, sqlc.arg(now)::bigint) + sqlc.arg(cost)::bigint
+WHERE GREATEST(rate_limits.full_at, sqlc.arg(now)::bigint) + sqlc.arg(cost)::bigint <= sqlc.arg(now)::bigint + sqlc.arg(capacity)::bigint
+RETURNING full_at;
+
+-- name: GetRateLimit :one
+SELECT full_at FROM rate_limits
+WHERE key = $1;
+
+-- name: DeleteFullRateLimits :execrows
+DELETE FROM rate_limits
+WHERE full_at <= $1;
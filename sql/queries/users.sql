@@ -18,6 +18,11 @@ SELECT *
 FROM users
 WHERE id = $1;
 
+-- name: GetUsersFromIDs :many
+SELECT *
+FROM users
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
 -- name: UpdateUserPassword :one
 UPDATE users
 SET updated_at = NOW(), hashed_password = $2
@@ -38,6 +43,24 @@ WHERE users.id = $1;
 
 -- name: UpdateUserRed :one
 UPDATE users
-SET updated_at = NOW(), is_chirpy_red = TRUE
+SET updated_at = NOW(), is_chirpy_red = TRUE, chirpy_red_expires_at = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserRed :one
+UPDATE users
+SET updated_at = NOW(), is_chirpy_red = $2, chirpy_red_expires_at = NULL
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+SET updated_at = NOW(), role = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: ExpireChirpyRed :execrows
+UPDATE users
+SET updated_at = NOW(), is_chirpy_red = FALSE, chirpy_red_expires_at = NULL
+WHERE is_chirpy_red
+  AND chirpy_red_expires_at <= NOW();
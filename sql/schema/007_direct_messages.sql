@@ -0,0 +1,43 @@
+-- +goose Up
+CREATE TABLE conversations (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE conversation_participants (
+    conversation_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    joined_at TIMESTAMP NOT NULL,
+    last_read_at TIMESTAMP DEFAULT NULL,
+    cleared_at TIMESTAMP DEFAULT NULL,
+    PRIMARY KEY (conversation_id, user_id),
+    FOREIGN KEY (conversation_id)
+    REFERENCES conversations(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+CREATE INDEX conversation_participants_user_id_idx ON conversation_participants (user_id);
+
+CREATE TABLE messages (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    conversation_id UUID NOT NULL,
+    sender_id UUID NOT NULL,
+    body TEXT NOT NULL,
+    FOREIGN KEY (conversation_id)
+    REFERENCES conversations(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (sender_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+CREATE INDEX messages_conversation_id_created_at_idx ON messages (conversation_id, created_at);
+
+-- +goose Down
+DROP TABLE messages;
+DROP TABLE conversation_participants;
+DROP TABLE conversations;
@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    type TEXT NOT NULL,
+    user_id UUID DEFAULT NULL,
+    actor_id UUID DEFAULT NULL,
+    payload JSONB NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (actor_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('chirpy_events', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER events_notify
+AFTER INSERT ON events
+FOR EACH ROW EXECUTE FUNCTION notify_event();
+
+-- +goose Down
+DROP TRIGGER events_notify ON events;
+DROP FUNCTION notify_event();
+DROP TABLE events;
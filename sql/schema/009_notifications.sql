@@ -0,0 +1,46 @@
+-- +goose Up
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    type TEXT NOT NULL,
+    subject_id UUID NOT NULL,
+    read_at TIMESTAMP DEFAULT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+-- New events join the unread notification for the same subject
+CREATE UNIQUE INDEX notifications_unread_group_idx ON notifications (user_id, type, subject_id)
+WHERE read_at IS NULL;
+CREATE INDEX notifications_user_id_updated_at_idx ON notifications (user_id, updated_at DESC, id DESC);
+
+CREATE TABLE notification_actors (
+    notification_id UUID NOT NULL,
+    actor_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (notification_id, actor_id),
+    FOREIGN KEY (notification_id)
+    REFERENCES notifications(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (actor_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE notification_preferences (
+    user_id UUID NOT NULL,
+    type TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, type),
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notification_actors;
+DROP TABLE notifications;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    state TEXT NOT NULL DEFAULT 'pending'
+    CHECK (state IN ('pending', 'running', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP DEFAULT NULL,
+    last_error TEXT DEFAULT NULL,
+    unique_key TEXT UNIQUE DEFAULT NULL
+);
+
+CREATE INDEX jobs_runnable ON jobs (run_at)
+WHERE state IN ('pending', 'running');
+
+-- +goose Down
+DROP TABLE jobs;
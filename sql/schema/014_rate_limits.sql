@@ -0,0 +1,10 @@
+-- +goose Up
+-- Each row is a token bucket, stored as the time it will next be full, in
+-- microseconds since the Unix epoch. A bucket that's full again has no row.
+CREATE TABLE rate_limits (
+    key TEXT PRIMARY KEY,
+    full_at BIGINT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE rate_limits;
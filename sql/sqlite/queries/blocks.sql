@@ -0,0 +1,54 @@
+-- name: CreateBlock :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (
+    ?1, ?2, NOW()
+)
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteBlock :execrows
+DELETE FROM blocks
+WHERE blocker_id = ?1 AND blocked_id = ?2;
+
+-- name: GetBlocks :many
+SELECT *
+FROM blocks
+WHERE blocker_id = ?1
+ORDER BY created_at;
+
+-- name: IsBlocked :one
+SELECT CAST(EXISTS (
+    SELECT 1
+    FROM blocks
+    WHERE (blocker_id = sqlc.arg(user_a) AND blocked_id = sqlc.arg(user_b))
+       OR (blocker_id = sqlc.arg(user_b) AND blocked_id = sqlc.arg(user_a))
+) AS BOOLEAN);
+
+-- name: CreateMute :exec
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES (
+    ?1, ?2, NOW()
+)
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: DeleteMute :execrows
+DELETE FROM mutes
+WHERE muter_id = ?1 AND muted_id = ?2;
+
+-- name: GetMutes :many
+SELECT *
+FROM mutes
+WHERE muter_id = ?1
+ORDER BY created_at;
+
+-- name: GetHiddenAuthors :many
+SELECT blocks.blocked_id AS user_id
+FROM blocks
+WHERE blocks.blocker_id = ?1
+UNION
+SELECT blockers.blocker_id
+FROM blocks blockers
+WHERE blockers.blocked_id = ?1
+UNION
+SELECT mutes.muted_id
+FROM mutes
+WHERE mutes.muter_id = ?1;
@@ -0,0 +1,21 @@
+-- name: CreateEvent :one
+INSERT INTO events (created_at, type, user_id, actor_id, payload)
+VALUES (
+    NOW(), ?1, ?2, ?3, ?4
+)
+RETURNING *;
+
+-- name: GetEventsAfter :many
+SELECT *
+FROM events
+WHERE id > ?1
+ORDER BY id
+LIMIT ?2;
+
+-- name: GetLatestEventID :one
+SELECT CAST(COALESCE(MAX(id), 0) AS INTEGER) AS latest_id
+FROM events;
+
+-- name: DeleteEventsBefore :execrows
+DELETE FROM events
+WHERE created_at < ?1;
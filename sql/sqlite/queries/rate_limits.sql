@@ -0,0 +1,18 @@
+-- name: TakeRateLimit :one
+-- Takes one token from the bucket for key, refilling it for the time since
+-- it was last touched. No row comes back when the bucket is empty, and then
+-- nothing changes. SQLite runs one write at a time, so unlike Postgres the
+-- read and the write can be separate parts of the statement.
+INSERT INTO rate_limits (key, full_at)
+SELECT sqlc.arg(key), MAX(COALESCE((SELECT full_at FROM rate_limits WHERE key = sqlc.arg(key)), 0), CAST(sqlc.arg(now) AS INTEGER)) + CAST(sqlc.arg(cost) AS INTEGER)
+WHERE MAX(COALESCE((SELECT full_at FROM rate_limits WHERE key = sqlc.arg(key)), 0), CAST(sqlc.arg(now) AS INTEGER)) + CAST(sqlc.arg(cost) AS INTEGER) <= CAST(sqlc.arg(now) AS INTEGER) + CAST(sqlc.arg(capacity) AS INTEGER)
+ON CONFLICT (key) DO UPDATE SET full_at = excluded.full_at
+RETURNING full_at;
+
+-- name: GetRateLimit :one
+SELECT full_at FROM rate_limits
+WHERE key = ?1;
+
+-- name: DeleteFullRateLimits :execrows
+DELETE FROM rate_limits
+WHERE full_at <= ?1;
@@ -0,0 +1,35 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+VALUES(
+    ?1, NOW(), NOW(), ?2, ?3
+)
+RETURNING *;
+
+-- name: GetRefreshToken :one
+SELECT *
+FROM refresh_tokens
+WHERE token = ?1;
+
+-- name: RevokeRefreshToken :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE token = ?1;
+
+-- name: GetUserFromRefreshToken :one
+SELECT users.*
+FROM users
+JOIN refresh_tokens ON users.id = refresh_tokens.user_id
+WHERE refresh_tokens.token = ?1
+  AND revoked_at IS NULL
+  AND expires_at > NOW();
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = ?1
+  AND revoked_at IS NULL;
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at <= NOW()
+   OR revoked_at IS NOT NULL;
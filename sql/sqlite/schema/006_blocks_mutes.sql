@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE blocks (
+    blocker_id UUID NOT NULL,
+    blocked_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (blocker_id, blocked_id),
+    FOREIGN KEY (blocker_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+CREATE INDEX blocks_blocked_id_idx ON blocks (blocked_id);
+
+CREATE TABLE mutes (
+    muter_id UUID NOT NULL,
+    muted_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (muter_id, muted_id),
+    FOREIGN KEY (muter_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (muted_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX chirps_user_id_idx ON chirps (user_id);
+
+-- +goose Down
+DROP INDEX chirps_user_id_idx;
+DROP TABLE mutes;
+DROP TABLE blocks;
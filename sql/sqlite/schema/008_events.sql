@@ -0,0 +1,19 @@
+-- +goose Up
+-- SQLite has no LISTEN/NOTIFY, so there is no trigger; the hub polls instead
+CREATE TABLE events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL,
+    type TEXT NOT NULL,
+    user_id UUID DEFAULT NULL,
+    actor_id UUID DEFAULT NULL,
+    payload BLOB NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (actor_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE events;
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN status TEXT NOT NULL DEFAULT 'published'
+CHECK (status IN ('draft', 'scheduled', 'published'));
+
+ALTER TABLE chirps
+ADD COLUMN publish_at TIMESTAMP DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN publish_at;
+
+ALTER TABLE chirps
+DROP COLUMN status;
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/google/uuid"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+func (cfg *apiConfig) stream(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	// EventSource sends Last-Event-ID on reconnect; the query parameter
+	// covers clients that can't set headers
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastID int64
+	if lastEventID != "" {
+		parsed, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			respondWithError(w, r, errInvalidParameter, "Invalid Last-Event-ID", err)
+			return
+		}
+		lastID = parsed
+	}
+
+	feed, err := cfg.followEvents(r.Context(), id, lastID)
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't load blocks", err)
+		return
+	}
+	defer feed.Close()
+
+	// The server's write timeout would cut the stream off, so each heartbeat
+	// pushes the deadline on instead. A client that stops reading still
+	// gets dropped.
+	rc := http.NewResponseController(w)
+	extendDeadline := func() error {
+		return rc.SetWriteDeadline(time.Now().Add(2 * heartbeatInterval))
+	}
+	if err := extendDeadline(); err != nil {
+		respondWithError(w, r, errInternal, "Couldn't start stream", err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	// A client dropped as a slow consumer resumes from the last ID it got
+	feed.run(r.Context(), func(ev database.Event) error {
+		_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Payload)
+		if err != nil {
+			return err
+		}
+		return rc.Flush()
+	}, func() error {
+		if err := extendDeadline(); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+}
+
+// errFeedDropped ends an eventFeed whose subscriber fell too far behind.
+var errFeedDropped = errors.New("dropped as a slow consumer")
+
+// eventFeed follows the events one user may see, for the streaming APIs.
+type eventFeed struct {
+	cfg    *apiConfig
+	sub    *events.Subscription
+	filter *eventFilter
+	lastID int64
+}
+
+// followEvents starts a feed of the events after lastID, or of new events if
+// it's 0.
+func (cfg *apiConfig) followEvents(ctx context.Context, userID uuid.UUID, lastID int64) (*eventFeed, error) {
+	// Subscribe before reading the backlog so nothing falls in between
+	sub := cfg.events.Subscribe()
+	filter, err := cfg.newEventFilter(ctx, userID)
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+	return &eventFeed{cfg: cfg, sub: sub, filter: filter, lastID: lastID}, nil
+}
+
+func (f *eventFeed) Close() {
+	f.sub.Close()
+}
+
+// run sends each event, replaying what the user missed first, and calls
+// heartbeat every heartbeatInterval. It returns when ctx is done, send or
+// heartbeat fails, or the feed is dropped with errFeedDropped.
+func (f *eventFeed) run(ctx context.Context, send func(database.Event) error, heartbeat func() error) error {
+	deliver := func(ev database.Event) error {
+		if ev.ID <= f.lastID {
+			return nil
+		}
+		f.lastID = ev.ID
+		if !f.filter.visible(ev) {
+			return nil
+		}
+		return send(ev)
+	}
+
+	// Replay what the user missed
+	if f.lastID > 0 {
+		for {
+			backlog, err := f.cfg.events.Since(ctx, f.lastID)
+			if err != nil {
+				return err
+			}
+			for _, ev := range backlog {
+				if err := deliver(ev); err != nil {
+					return err
+				}
+			}
+			if len(backlog) == 0 {
+				break
+			}
+		}
+	}
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-f.sub.Events:
+			if !ok {
+				return errFeedDropped
+			}
+			if err := deliver(ev); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := heartbeat(); err != nil {
+				return err
+			}
+			f.filter.refresh(ctx)
+		}
+	}
+}
+
+// eventFilter decides which events a connected user may see: public events
+// not caused by someone hidden from them, and events addressed to them.
+type eventFilter struct {
+	cfg    *apiConfig
+	userID uuid.UUID
+	hidden map[uuid.UUID]bool
+}
+
+func (cfg *apiConfig) newEventFilter(ctx context.Context, userID uuid.UUID) (*eventFilter, error) {
+	filter := &eventFilter{cfg: cfg, userID: userID}
+	err := filter.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func (f *eventFilter) load(ctx context.Context) error {
+	ids, err := f.cfg.database.GetHiddenAuthors(ctx, f.userID)
+	if err != nil {
+		return err
+	}
+	hidden := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		hidden[id] = true
+	}
+	f.hidden = hidden
+	return nil
+}
+
+// refresh picks up blocks and mutes made since the stream started. On error
+// the previous set is kept.
+func (f *eventFilter) refresh(ctx context.Context) {
+	_ = f.load(ctx)
+}
+
+func (f *eventFilter) visible(ev database.Event) bool {
+	if ev.UserID.Valid && ev.UserID.UUID != f.userID {
+		return false
+	}
+	if ev.ActorID.Valid && f.hidden[ev.ActorID.UUID] {
+		return false
+	}
+	return true
+}
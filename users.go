@@ -1,12 +1,16 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/auth"
 	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/metrics"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/store"
 	"github.com/google/uuid"
 )
 
@@ -21,43 +25,105 @@ type User struct {
 	IsChirpyRed  bool      `json:"is_chirpy_red"`
 }
 
+// UserV2 is a user as /api/v2 returns them. Unlike User it never carries
+// tokens; logging in returns them in a Session.
+type UserV2 struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+// Session is what POST /api/v2/login returns.
+type Session struct {
+	User         UserV2 `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (u User) v2() UserV2 {
+	return UserV2{
+		ID:          u.ID,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		Email:       u.Email,
+		IsChirpyRed: u.IsChirpyRed,
+	}
+}
+
+func userResponse(user database.User) User {
+	return User{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+}
+
+// newUserParameters is the body of POST /api/users.
+type newUserParameters struct {
+	Password string `json:"password" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
+}
+
 func (cfg *apiConfig) newUser(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+	params, ok := decode[newUserParameters](w, r)
+	if !ok {
+		return
 	}
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err := decoder.Decode(&params)
+	user, err := cfg.createUser(r.Context(), params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithFailure(w, r, err)
 		return
 	}
-	hash, err := auth.HashPassword(params.Password)
+	respondWithJSON(w, http.StatusCreated, user)
+}
+
+func (cfg *apiConfig) newUserV2(w http.ResponseWriter, r *http.Request) {
+	params, ok := decode[newUserParameters](w, r)
+	if !ok {
+		return
+	}
+	user, err := cfg.createUser(r.Context(), params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create hash", err)
+		respondWithFailure(w, r, err)
 		return
 	}
+	respondWithJSON(w, http.StatusCreated, user.v2())
+}
+
+// createUser signs up a user. Like the other methods that return a
+// *requestError, it's shared by the HTTP and gRPC APIs, and its parameters
+// have been validated.
+func (cfg *apiConfig) createUser(ctx context.Context, params newUserParameters) (User, error) {
+	hash, err := auth.HashPassword(params.Password)
+	if err != nil {
+		return User{}, failure(errInternal, "Couldn't create hash", err)
+	}
 
-	user, err := cfg.database.CreateUser(r.Context(),
+	user, err := cfg.database.CreateUser(ctx,
 		database.CreateUserParams{
 			Email:          params.Email,
 			HashedPassword: hash},
 	)
+	if store.IsUniqueViolation(err) {
+		return User{}, failure(errConflict, "Email is already registered", err)
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
-		return
+		return User{}, failure(errInternal, "Couldn't create user", err)
 	}
+	cfg.metrics.UsersCreated.Inc()
 
-	respBody := User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
-	}
+	return userResponse(user), nil
+}
 
-	respondWithJSON(w, http.StatusCreated, respBody)
+// userUpdateParameters is the body of PUT /api/users. Fields left out are
+// left as they are.
+type userUpdateParameters struct {
+	Password string `json:"password"`
+	Email    string `json:"email" validate:"email"`
 }
 
 func (cfg *apiConfig) updateUser(w http.ResponseWriter, r *http.Request) {
@@ -65,132 +131,173 @@ func (cfg *apiConfig) updateUser(w http.ResponseWriter, r *http.Request) {
 	// Get access token
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Authorization header failed", err)
+		respondWithError(w, r, errUnauthorized, "Authorization header failed", err)
 		return
 	}
 
 	// Validate user with token
 	id, err := auth.ValidateJWT(token, cfg.secret)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "JWT validation failed", err)
+		respondWithError(w, r, errUnauthorized, "JWT validation failed", err)
 		return
 	}
+	setRequestUser(r.Context(), id)
 
-	// Get user with id
-	user, err := cfg.database.GetUserFromID(r.Context(), id)
+	// Read request body
+	params, ok := decode[userUpdateParameters](w, r)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.editUser(r.Context(), id, params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		respondWithFailure(w, r, err)
 		return
 	}
 
-	// Read request body
-	type parameters struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+	// Find refresh token
+	refresh, err := cfg.database.GetRefreshTokenFromUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't find refresh token from user", err)
+		return
+	}
+	user.Token = token
+	user.RefreshToken = refresh.Token
+
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+// updateUserV2 is updateUser without echoing the caller's tokens back.
+func (cfg *apiConfig) updateUserV2(w http.ResponseWriter, r *http.Request) {
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
 	}
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	params, ok := decode[userUpdateParameters](w, r)
+	if !ok {
+		return
+	}
+	user, err := cfg.editUser(r.Context(), id, params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithFailure(w, r, err)
 		return
 	}
+	respondWithJSON(w, http.StatusOK, user.v2())
+}
+
+// editUser changes the user's password and email, where they're given.
+func (cfg *apiConfig) editUser(ctx context.Context, id uuid.UUID, params userUpdateParameters) (User, error) {
+	// Get user with id
+	user, err := cfg.database.GetUserFromID(ctx, id)
+	if err != nil {
+		return User{}, failure(errInternal, "Couldn't get user", err)
+	}
+
+	// Check for email update, update email to user. It goes first, so an
+	// email that's taken leaves the password as it was
+	if params.Email != "" {
+		user, err = cfg.database.UpdateUserEmail(ctx,
+			database.UpdateUserEmailParams{
+				ID:    user.ID,
+				Email: params.Email,
+			},
+		)
+		if store.IsUniqueViolation(err) {
+			return User{}, failure(errConflict, "Email is already registered", err)
+		}
+		if err != nil {
+			return User{}, failure(errInternal, "Couldn't update user email", err)
+		}
+	}
 
 	// Check for password update, hash new password, update hash to user
 	if params.Password != "" {
 		hash, err := auth.HashPassword(params.Password)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't create hash", err)
-			return
+			return User{}, failure(errInternal, "Couldn't create hash", err)
 		}
-		user, err = cfg.database.UpdateUserPassword(r.Context(),
+		user, err = cfg.database.UpdateUserPassword(ctx,
 			database.UpdateUserPasswordParams{
 				ID:             user.ID,
 				HashedPassword: hash,
 			},
 		)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't update user password", err)
-			return
+			return User{}, failure(errInternal, "Couldn't update user password", err)
 		}
 	}
 
-	// Check for email update, update email to user
-	if params.Email != "" {
-		user, err = cfg.database.UpdateUserEmail(r.Context(),
-			database.UpdateUserEmailParams{
-				ID:    user.ID,
-				Email: params.Email,
-			},
-		)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't update user email", err)
-			return
-		}
+	return userResponse(user), nil
+}
+
+// loginParameters is the body of POST /api/login.
+type loginParameters struct {
+	Password string `json:"password" validate:"required"`
+	Email    string `json:"email" validate:"required"`
+}
+
+func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
+	params, ok := decode[loginParameters](w, r)
+	if !ok {
+		return
 	}
-	// Find refresh token
-	refresh, err := cfg.database.GetRefreshTokenFromUser(r.Context(), user.ID)
+	user, err := cfg.logIn(r.Context(), params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't find refresh token from user", err)
+		respondWithFailure(w, r, err)
 		return
 	}
-
-	respBody := User{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		Token:        token,
-		RefreshToken: refresh.Token,
-		IsChirpyRed:  user.IsChirpyRed,
-	}
-
-	respondWithJSON(w, http.StatusOK, respBody)
+	respondWithJSON(w, http.StatusOK, user)
 }
 
-func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+func (cfg *apiConfig) loginV2(w http.ResponseWriter, r *http.Request) {
+	params, ok := decode[loginParameters](w, r)
+	if !ok {
+		return
 	}
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err := decoder.Decode(&params)
+	user, err := cfg.logIn(r.Context(), params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithFailure(w, r, err)
 		return
 	}
+	respondWithJSON(w, http.StatusOK, Session{
+		User:         user.v2(),
+		Token:        user.Token,
+		RefreshToken: user.RefreshToken,
+	})
+}
 
-	user, err := cfg.database.GetUser(r.Context(), params.Email)
+// logIn checks the user's password and gives them an access token and a
+// refresh token.
+func (cfg *apiConfig) logIn(ctx context.Context, params loginParameters) (User, error) {
+	user, err := cfg.database.GetUser(ctx, params.Email)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
-		return
+		if errors.Is(err, sql.ErrNoRows) {
+			cfg.metrics.LoginFailures.WithLabelValues(metrics.LoginUnknownEmail).Inc()
+		}
+		return User{}, failure(errBadCredentials, "Incorrect email or password", err)
 	}
 
 	authorization, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Error checking password", err)
-		return
+		return User{}, failure(errBadCredentials, "Incorrect email or password", err)
 	}
 
 	if !authorization {
-		respondWithError(w, http.StatusUnauthorized, "incorrect password", err)
-		return
+		cfg.metrics.LoginFailures.WithLabelValues(metrics.LoginWrongPassword).Inc()
+		return User{}, failure(errBadCredentials, "Incorrect email or password", err)
 	}
 
 	token, err := auth.MakeJWT(user.ID, cfg.secret)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error making JWT", err)
-		return
+		return User{}, failure(errInternal, "Error making JWT", err)
 	}
 
 	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error making refresh token", err)
-		return
+		return User{}, failure(errInternal, "Error making refresh token", err)
 	}
 	refresh, err := cfg.database.CreateRefreshToken(
-		r.Context(),
+		ctx,
 		database.CreateRefreshTokenParams{
 			Token:     refreshToken,
 			UserID:    user.ID,
@@ -198,21 +305,13 @@ func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 		},
 	)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error creating refresh token in database", err)
-		return
-	}
-
-	respBody := User{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		Token:        token,
-		RefreshToken: refresh.Token,
-		IsChirpyRed:  user.IsChirpyRed,
+		return User{}, failure(errInternal, "Error creating refresh token in database", err)
 	}
 
-	respondWithJSON(w, http.StatusOK, respBody)
+	resp := userResponse(user)
+	resp.Token = token
+	resp.RefreshToken = refresh.Token
+	return resp, nil
 }
 
 func (cfg *apiConfig) refresh(w http.ResponseWriter, r *http.Request) {
@@ -222,19 +321,13 @@ func (cfg *apiConfig) refresh(w http.ResponseWriter, r *http.Request) {
 
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Authorization header failed", err)
+		respondWithError(w, r, errUnauthorized, "Authorization header failed", err)
 		return
 	}
 
-	user, err := cfg.database.GetUserFromRefreshToken(r.Context(), token)
+	newToken, err := cfg.refreshAccess(r.Context(), token)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Refresh token is bad/expired/revoked", err)
-		return
-	}
-
-	newToken, err := auth.MakeJWT(user.ID, cfg.secret)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error making JWT", err)
+		respondWithFailure(w, r, err)
 		return
 	}
 
@@ -245,22 +338,45 @@ func (cfg *apiConfig) refresh(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, respBody)
 }
 
+// refreshAccess swaps a refresh token for a new access token.
+func (cfg *apiConfig) refreshAccess(ctx context.Context, refreshToken string) (string, error) {
+	user, err := cfg.database.GetUserFromRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return "", failure(errUnauthorized, "Refresh token is bad/expired/revoked", err)
+	}
+	setRequestUser(ctx, user.ID)
+
+	token, err := auth.MakeJWT(user.ID, cfg.secret)
+	if err != nil {
+		return "", failure(errInternal, "Error making JWT", err)
+	}
+	return token, nil
+}
+
 func (cfg *apiConfig) revoke(w http.ResponseWriter, r *http.Request) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Authorization header failed", err)
+		respondWithError(w, r, errUnauthorized, "Authorization header failed", err)
 		return
 	}
 
-	err = cfg.database.RevokeRefreshToken(r.Context(), token)
+	err = cfg.revokeRefresh(r.Context(), token)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error revoking refresh token", err)
+		respondWithFailure(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (cfg *apiConfig) revokeRefresh(ctx context.Context, refreshToken string) error {
+	err := cfg.database.RevokeRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return failure(errInternal, "Error revoking refresh token", err)
+	}
+	return nil
+}
+
 func (cfg *apiConfig) upgrade(w http.ResponseWriter, r *http.Request) {
 
 	// Define data and parameters
@@ -273,45 +389,57 @@ func (cfg *apiConfig) upgrade(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Unmarshal
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err := decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+	params, ok := decodeWebhook[parameters](w, r)
+	if !ok {
+		cfg.metrics.Webhooks.WithLabelValues(metrics.WebhookFailed).Inc()
 		return
 	}
 
 	// Check event
 	if params.Event != "user.upgraded" {
-		respondWithError(w, http.StatusNoContent, "Wrong event", err)
+		cfg.metrics.Webhooks.WithLabelValues(metrics.WebhookIgnored).Inc()
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
 	// Check APIkey
 	apiKey, err := auth.GetAPIKey(r.Header)
 	if apiKey != cfg.polkaKey {
-		respondWithError(w, http.StatusUnauthorized, "Wrong APIkey", err)
+		cfg.metrics.Webhooks.WithLabelValues(metrics.WebhookUnauthorized).Inc()
+		respondWithError(w, r, errUnauthorized, "Wrong API key", err)
 		return
 	}
 
 	// Get user
 	id, err := uuid.Parse(params.Data.UserID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Invalid uuid", err)
+		cfg.metrics.Webhooks.WithLabelValues(metrics.WebhookUnknownUser).Inc()
+		respondWithInvalid(w, r, fieldError{Pointer: "/data/user_id", Code: "invalid_id", Detail: "user_id isn't a valid UUID"})
 		return
 	}
 	user, err := cfg.database.GetUserFromID(r.Context(), id)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		cfg.metrics.Webhooks.WithLabelValues(metrics.WebhookUnknownUser).Inc()
+		respondWithError(w, r, errNotFound, "Couldn't find user", err)
 		return
 	}
 
-	// Update user to red
-	_, err = cfg.database.UpdateUserRed(r.Context(), user.ID)
+	// Update user to red. Polka only tells us about upgrades, so Red lasts
+	// for the configured period from each one, or for good without one
+	var expiresAt sql.NullTime
+	if cfg.chirpyRedPeriod > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().Add(cfg.chirpyRedPeriod).UTC(), Valid: true}
+	}
+	_, err = cfg.database.UpdateUserRed(r.Context(), database.UpdateUserRedParams{
+		ID:                 user.ID,
+		ChirpyRedExpiresAt: expiresAt,
+	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't upgrade to red", err)
+		cfg.metrics.Webhooks.WithLabelValues(metrics.WebhookFailed).Inc()
+		respondWithError(w, r, errInternal, "Couldn't upgrade to red", err)
 		return
 	}
+	cfg.metrics.Webhooks.WithLabelValues(metrics.WebhookUpgraded).Inc()
 
 	// Return status code
 	w.WriteHeader(http.StatusNoContent)
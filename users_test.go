@@ -0,0 +1,243 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/auth"
+)
+
+func TestCreateUser(t *testing.T) {
+	ts := newTestServer(t)
+
+	user := expect[User](t, ts.do("POST", "/api/users", "", map[string]string{
+		"email":    "walt@breakingbad.com",
+		"password": testPassword,
+	}), http.StatusCreated)
+	if user.Email != "walt@breakingbad.com" {
+		t.Errorf("got email %q, want %q", user.Email, "walt@breakingbad.com")
+	}
+	if user.Token != "" || user.RefreshToken != "" {
+		t.Error("creating a user shouldn't return tokens")
+	}
+
+	// Emails are unique
+	resp := ts.do("POST", "/api/users", "", map[string]string{
+		"email":    "walt@breakingbad.com",
+		"password": testPassword,
+	})
+	if got := expect[problem](t, resp, http.StatusConflict); got.Code != "conflict" {
+		t.Errorf("got code %q for a taken email, want conflict", got.Code)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.signUp("saul@bettercall.com")
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantCode int
+	}{
+		{
+			name:     "Correct password",
+			email:    "saul@bettercall.com",
+			password: testPassword,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Wrong password",
+			email:    "saul@bettercall.com",
+			password: "wrongPassword",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Unknown email",
+			email:    "kim@bettercall.com",
+			password: testPassword,
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := ts.do("POST", "/api/login", "", map[string]string{
+				"email":    tt.email,
+				"password": tt.password,
+			})
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			user := expect[User](t, resp, http.StatusOK)
+			id, err := auth.ValidateJWT(user.Token, testSecret)
+			if err != nil {
+				t.Fatalf("login returned an invalid JWT: %v", err)
+			}
+			if id != created.ID {
+				t.Errorf("JWT subject is %v, want %v", id, created.ID)
+			}
+			if user.RefreshToken == "" {
+				t.Error("login didn't return a refresh token")
+			}
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signUp("jesse@breakingbad.com")
+
+	resp := ts.do("PUT", "/api/users", "", map[string]string{"email": "pinkman@breakingbad.com"})
+	expect[any](t, resp, http.StatusUnauthorized)
+
+	updated := expect[User](t, ts.do("PUT", "/api/users", user.Token, map[string]string{
+		"email":    "pinkman@breakingbad.com",
+		"password": "newPassword456!",
+	}), http.StatusOK)
+	if updated.Email != "pinkman@breakingbad.com" {
+		t.Errorf("got email %q, want %q", updated.Email, "pinkman@breakingbad.com")
+	}
+
+	// Only the new credentials work
+	expect[any](t, ts.do("POST", "/api/login", "", map[string]string{
+		"email":    "jesse@breakingbad.com",
+		"password": testPassword,
+	}), http.StatusUnauthorized)
+	expect[User](t, ts.do("POST", "/api/login", "", map[string]string{
+		"email":    "pinkman@breakingbad.com",
+		"password": "newPassword456!",
+	}), http.StatusOK)
+
+	// Someone else's email is taken, and nothing changes
+	ts.signUp("walt@breakingbad.com")
+	expect[problem](t, ts.do("PUT", "/api/users", user.Token, map[string]string{
+		"email":    "walt@breakingbad.com",
+		"password": testPassword,
+	}), http.StatusConflict)
+	expect[User](t, ts.do("POST", "/api/login", "", map[string]string{
+		"email":    "pinkman@breakingbad.com",
+		"password": "newPassword456!",
+	}), http.StatusOK)
+}
+
+func TestRefreshAndRevoke(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signUp("gus@pollos.com")
+
+	type refreshResponse struct {
+		Token string `json:"token"`
+	}
+	refreshed := expect[refreshResponse](t, ts.do("POST", "/api/refresh", user.RefreshToken, nil), http.StatusOK)
+	id, err := auth.ValidateJWT(refreshed.Token, testSecret)
+	if err != nil || id != user.ID {
+		t.Fatalf("refresh returned a bad JWT: id %v, err %v", id, err)
+	}
+
+	// Access tokens aren't refresh tokens
+	expect[any](t, ts.do("POST", "/api/refresh", user.Token, nil), http.StatusUnauthorized)
+
+	expect[any](t, ts.do("POST", "/api/revoke", user.RefreshToken, nil), http.StatusNoContent)
+	expect[any](t, ts.do("POST", "/api/refresh", user.RefreshToken, nil), http.StatusUnauthorized)
+}
+
+func TestPolkaWebhook(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signUp("mike@pollos.com")
+
+	webhook := func(key, event, userID string) *http.Response {
+		req, err := http.NewRequest("POST", ts.URL+"/api/polka/webhooks", jsonBody(t, map[string]any{
+			"event": event,
+			"data":  map[string]string{"user_id": userID},
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "ApiKey "+key)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		event    string
+		userID   string
+		wantCode int
+	}{
+		{
+			name:     "Other events are ignored",
+			key:      testPolkaKey,
+			event:    "user.payment_failed",
+			userID:   user.ID.String(),
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "Wrong API key",
+			key:      "wrong-key",
+			event:    "user.upgraded",
+			userID:   user.ID.String(),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Unknown user",
+			key:      testPolkaKey,
+			event:    "user.upgraded",
+			userID:   "3311741c-680c-4546-99f3-fc9efac2036c",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Upgrade",
+			key:      testPolkaKey,
+			event:    "user.upgraded",
+			userID:   user.ID.String(),
+			wantCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := webhook(tt.key, tt.event, tt.userID)
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+		})
+	}
+
+	login := expect[User](t, ts.do("POST", "/api/login", "", map[string]string{
+		"email":    "mike@pollos.com",
+		"password": testPassword,
+	}), http.StatusOK)
+	if !login.IsChirpyRed {
+		t.Error("user wasn't upgraded to Chirpy Red")
+	}
+
+	// Without a period Red never lapses; with one, each upgrade runs for
+	// that long
+	stored, err := ts.store.GetUserFromID(t.Context(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ChirpyRedExpiresAt.Valid {
+		t.Errorf("Red expires at %v with no period set", stored.ChirpyRedExpiresAt.Time)
+	}
+	ts.cfg.chirpyRedPeriod = 30 * 24 * time.Hour
+	expect[any](t, webhook(testPolkaKey, "user.upgraded", user.ID.String()), http.StatusNoContent)
+	stored, err = ts.store.GetUserFromID(t.Context(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if until := time.Until(stored.ChirpyRedExpiresAt.Time); !stored.ChirpyRedExpiresAt.Valid || until < 29*24*time.Hour || until > 30*24*time.Hour {
+		t.Errorf("got Red expiring at %+v, want in 30 days", stored.ChirpyRedExpiresAt)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// maxBodyBytes bounds JSON request bodies. The biggest we expect is a chirp
+// of maxBodyLength characters, so this leaves plenty of room.
+const maxBodyBytes = 64 << 10
+
+var (
+	errBodyTooLarge         = apiError{http.StatusRequestEntityTooLarge, "body_too_large", "Request body is too large"}
+	errUnsupportedMediaType = apiError{http.StatusUnsupportedMediaType, "unsupported_media_type", "Request body must be JSON"}
+)
+
+// validator is implemented by parameters with rules that span fields, which
+// a validate tag can't express. It's called once every tag rule passes.
+type validator interface {
+	validate() []fieldError
+}
+
+// decode reads the JSON request body into a T and checks it. The body must be
+// a single JSON value of at most maxBodyBytes, sent as application/json,
+// with no fields T doesn't have. Fields are then checked against their
+// validate tags, a comma-separated list of rules:
+//
+//	required  must be present and not empty
+//	email     an email address
+//	uuid      a UUID; on a slice, every element
+//	min=N     at least N characters, or N elements
+//	max=N     at most N characters, or N elements
+//
+// Rules other than required pass on empty values. decode writes the error
+// response and returns false if the body isn't acceptable.
+func decode[T any](w http.ResponseWriter, r *http.Request) (T, bool) {
+	return decodeBody[T](w, r, true)
+}
+
+// decodeWebhook is decode for bodies we don't control. Senders add fields
+// as they please, so unknown fields are ignored.
+func decodeWebhook[T any](w http.ResponseWriter, r *http.Request) (T, bool) {
+	return decodeBody[T](w, r, false)
+}
+
+func decodeBody[T any](w http.ResponseWriter, r *http.Request, strict bool) (T, bool) {
+	var params T
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		respondWithError(w, r, errUnsupportedMediaType, "Content-Type must be application/json", err)
+		return params, false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	err = decoder.Decode(&params)
+	if err == nil {
+		// Anything after the value is a client bug we shouldn't paper over
+		err = decoder.Decode(&struct{}{})
+		if errors.Is(err, io.EOF) {
+			err = nil
+		} else if err == nil {
+			err = errors.New("more than one JSON value")
+		}
+	}
+	if err != nil {
+		respondWithDecodeError(w, r, err)
+		return params, false
+	}
+
+	errs := validateParams(params)
+	if len(errs) > 0 {
+		respondWithInvalid(w, r, errs...)
+		return params, false
+	}
+	return params, true
+}
+
+// validateParams checks params the way decode does, for parameters that
+// don't arrive as JSON.
+func validateParams(params any) []fieldError {
+	errs := validateValue(reflect.ValueOf(params), "")
+	if v, ok := params.(validator); ok && len(errs) == 0 {
+		errs = v.validate()
+	}
+	return errs
+}
+
+func respondWithDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		respondWithError(w, r, errBodyTooLarge, fmt.Sprintf("Request body is larger than %d bytes", tooLarge.Limit), err)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		respondWithInvalid(w, r, fieldError{
+			Pointer: pointer(strings.Split(typeErr.Field, ".")...),
+			Code:    "invalid_type",
+			Detail:  fmt.Sprintf("%s can't be a JSON %s", typeErr.Field, typeErr.Value),
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json only says which field, not where it is
+		name, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		respondWithInvalid(w, r, fieldError{
+			Pointer: pointer(name),
+			Code:    "unknown",
+			Detail:  "Unknown field " + name,
+		})
+	default:
+		respondWithError(w, r, errMalformedBody, "Couldn't decode parameters", err)
+	}
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// validateValue checks the validate tags on a struct's fields, and on the
+// fields of structs nested in it. prefix is the struct's JSON Pointer.
+func validateValue(v reflect.Value, prefix string) []fieldError {
+	if v.Kind() != reflect.Struct || v.Type() == timeType {
+		return nil
+	}
+	var errs []fieldError
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		path := prefix + pointer(name)
+		value := v.Field(i)
+
+		errs = append(errs, validateValue(value, path)...)
+		if rules := field.Tag.Get("validate"); rules != "" {
+			errs = append(errs, checkRules(value, name, path, rules)...)
+		}
+	}
+	return errs
+}
+
+// checkRules applies a field's rules in order, stopping at the first that
+// fails.
+func checkRules(v reflect.Value, name, path, rules string) []fieldError {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v = reflect.Value{}
+		} else {
+			v = v.Elem()
+		}
+	}
+	empty := !v.IsValid() || v.IsZero() || (v.Kind() == reflect.Slice && v.Len() == 0)
+
+	for rule := range strings.SplitSeq(rules, ",") {
+		rule, arg, _ := strings.Cut(rule, "=")
+		if rule == "required" {
+			if empty {
+				return []fieldError{{Pointer: path, Code: "required", Detail: name + " is required"}}
+			}
+			continue
+		}
+		if empty {
+			return nil
+		}
+
+		switch rule {
+		case "email":
+			addr, err := mail.ParseAddress(v.String())
+			if err != nil || addr.Address != v.String() {
+				return []fieldError{{Pointer: path, Code: "invalid_email", Detail: name + " isn't a valid email address"}}
+			}
+		case "uuid":
+			if v.Kind() == reflect.String {
+				if uuid.Validate(v.String()) != nil {
+					return []fieldError{{Pointer: path, Code: "invalid_id", Detail: name + " isn't a valid UUID"}}
+				}
+				continue
+			}
+			var errs []fieldError
+			for i := range v.Len() {
+				if uuid.Validate(v.Index(i).String()) != nil {
+					errs = append(errs, fieldError{
+						Pointer: path + pointer(strconv.Itoa(i)),
+						Code:    "invalid_id",
+						Detail:  fmt.Sprintf("%s[%d] isn't a valid UUID", name, i),
+					})
+				}
+			}
+			if len(errs) > 0 {
+				return errs
+			}
+		case "min", "max":
+			limit, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("validate: bad %s rule on %s", rule, name))
+			}
+			if err := checkLength(v, name, path, rule, limit); err != nil {
+				return []fieldError{*err}
+			}
+		default:
+			panic(fmt.Sprintf("validate: unknown rule %q on %s", rule, name))
+		}
+	}
+	return nil
+}
+
+// checkLength applies a min or max rule: characters for a string, elements
+// for a slice.
+func checkLength(v reflect.Value, name, path, rule string, limit int) *fieldError {
+	n, unit := v.Len(), "elements"
+	code := map[string]string{"min": "too_few", "max": "too_many"}[rule]
+	if v.Kind() == reflect.String {
+		n, unit = utf8.RuneCountInString(v.String()), "characters"
+		code = map[string]string{"min": "too_short", "max": "too_long"}[rule]
+	}
+	switch {
+	case rule == "min" && n < limit:
+		return &fieldError{Pointer: path, Code: code, Detail: fmt.Sprintf("%s must be at least %d %s", name, limit, unit)}
+	case rule == "max" && n > limit:
+		return &fieldError{Pointer: path, Code: code, Detail: fmt.Sprintf("%s must be at most %d %s", name, limit, unit)}
+	}
+	return nil
+}
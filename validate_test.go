@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testParameters struct {
+	Email   string   `json:"email" validate:"required,email"`
+	Name    string   `json:"name" validate:"min=2,max=5"`
+	IDs     []string `json:"ids" validate:"max=2,uuid"`
+	Private bool     `json:"private"`
+	Nested  struct {
+		ID string `json:"id" validate:"uuid"`
+	} `json:"nested"`
+}
+
+func (p testParameters) validate() []fieldError {
+	if p.Private && p.Name == "" {
+		return []fieldError{{Pointer: "/name", Code: "required", Detail: "private needs a name"}}
+	}
+	return nil
+}
+
+func TestDecode(t *testing.T) {
+	const id = "3311741c-680c-4546-99f3-fc9efac2036c"
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantErrors  []fieldError
+	}{
+		{
+			name:       "Valid",
+			body:       `{"email": "walt@breakingbad.com", "name": "Walt", "ids": ["` + id + `"], "nested": {"id": "` + id + `"}}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "Content type with charset",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"email": "walt@breakingbad.com"}`,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "Not JSON",
+			contentType: "text/plain",
+			body:        `{"email": "walt@breakingbad.com"}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantCode:    "unsupported_media_type",
+		},
+		{
+			name:       "Too large",
+			body:       `{"email": "` + strings.Repeat("a", maxBodyBytes) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   "body_too_large",
+		},
+		{
+			name:       "Malformed",
+			body:       `{"email": `,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "malformed_body",
+		},
+		{
+			name:       "Trailing data",
+			body:       `{"email": "walt@breakingbad.com"} {}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "malformed_body",
+		},
+		{
+			name:       "Unknown field",
+			body:       `{"email": "walt@breakingbad.com", "admin": true}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_failed",
+			wantErrors: []fieldError{{Pointer: "/admin", Code: "unknown", Detail: "Unknown field admin"}},
+		},
+		{
+			name:       "Wrong type",
+			body:       `{"email": "walt@breakingbad.com", "nested": {"id": 7}}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_failed",
+			wantErrors: []fieldError{{Pointer: "/nested/id", Code: "invalid_type", Detail: "nested.id can't be a JSON number"}},
+		},
+		{
+			name:       "Rules",
+			body:       `{"email": "walt", "name": "Heisenberg", "ids": ["` + id + `", "nope"], "nested": {"id": "nope"}}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_failed",
+			wantErrors: []fieldError{
+				{Pointer: "/email", Code: "invalid_email", Detail: "email isn't a valid email address"},
+				{Pointer: "/name", Code: "too_long", Detail: "name must be at most 5 characters"},
+				{Pointer: "/ids/1", Code: "invalid_id", Detail: "ids[1] isn't a valid UUID"},
+				{Pointer: "/nested/id", Code: "invalid_id", Detail: "id isn't a valid UUID"},
+			},
+		},
+		{
+			name:       "Required and lengths",
+			body:       `{"name": "W", "ids": ["` + id + `", "` + id + `", "` + id + `"]}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_failed",
+			wantErrors: []fieldError{
+				{Pointer: "/email", Code: "required", Detail: "email is required"},
+				{Pointer: "/name", Code: "too_short", Detail: "name must be at least 2 characters"},
+				{Pointer: "/ids", Code: "too_many", Detail: "ids must be at most 2 elements"},
+			},
+		},
+		{
+			name:       "Cross-field rule",
+			body:       `{"email": "walt@breakingbad.com", "private": true}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_failed",
+			wantErrors: []fieldError{{Pointer: "/name", Code: "required", Detail: "private needs a name"}},
+		},
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := decode[testParameters](w, r)
+		if ok {
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			req.Header.Set("Content-Type", contentType)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+			var got problem
+			err := json.Unmarshal(rec.Body.Bytes(), &got)
+			if err != nil {
+				t.Fatalf("Couldn't decode problem: %v", err)
+			}
+			if got.Code != tt.wantCode {
+				t.Errorf("got code %q, want %q", got.Code, tt.wantCode)
+			}
+			if !reflect.DeepEqual(got.Errors, tt.wantErrors) {
+				t.Errorf("got errors %+v, want %+v", got.Errors, tt.wantErrors)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The API is versioned by path. /api/v1 is an alias of the unversioned /api
+// routes, which clients have always used. /api/v2 serves the same routes,
+// except where a resource was redesigned; those routes are deprecated under
+// /api and /api/v1, and go away at apiV1Sunset.
+var (
+	apiV1Deprecated = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	apiV1Sunset     = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+)
+
+// versioned moves an /api pattern, such as "PUT /api/users", under the given
+// version.
+func versioned(pattern, version string) string {
+	method, path, _ := strings.Cut(pattern, " /api/")
+	return method + " /api/" + version + "/" + path
+}
+
+// handleAPI registers an /api route, its /api/v1 alias and the same route
+// under /api/v2. Only the /api pattern is recorded, since openapi.json
+// documents each route once for all versions.
+func (m *routeMux) handleAPI(pattern string, handler http.Handler) {
+	m.Handle(pattern, handler)
+	m.ServeMux.Handle(versioned(pattern, "v1"), handler)
+	m.ServeMux.Handle(versioned(pattern, "v2"), handler)
+}
+
+// handleAPIFunc is handleAPI for a handler function.
+func (m *routeMux) handleAPIFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.handleAPI(pattern, http.HandlerFunc(handler))
+}
+
+// handleRedesigned registers an /api route whose resource /api/v2 redesigns.
+// The old handler serves /api and /api/v1, deprecated; the new one serves
+// /api/v2, which openapi.json documents separately.
+func (m *routeMux) handleRedesigned(pattern string, old, v2 http.Handler) {
+	m.Handle(pattern, old)
+	m.ServeMux.Handle(versioned(pattern, "v1"), old)
+	m.Handle(versioned(pattern, "v2"), v2)
+}
+
+// deprecated marks responses from a route replaced in /api/v2 with the
+// Deprecation (RFC 9745) and Sunset (RFC 8594) headers, links to the
+// replacement, and counts the call.
+func (cfg *apiConfig) deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api"), "/v1")
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(apiV1Deprecated.Unix(), 10))
+		w.Header().Set("Sunset", apiV1Sunset.Format(http.TimeFormat))
+		w.Header().Set("Link", `</api/v2`+path+`>; rel="successor-version"`)
+		cfg.metrics.DeprecatedRequests.WithLabelValues(r.Pattern).Inc()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestVersionedRoutes(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	chirp := ts.chirp(walt.Token, "Say my name")
+
+	// Routes v2 didn't redesign are the same under every prefix
+	for _, prefix := range []string{"/api", "/api/v1", "/api/v2"} {
+		t.Run(prefix, func(t *testing.T) {
+			resp := ts.do("GET", prefix+"/chirps/"+chirp.ID.String(), "", nil)
+			if got := resp.Header.Get("Deprecation"); got != "" {
+				t.Errorf("got Deprecation %q on a current route", got)
+			}
+			got := expect[Chirp](t, resp, http.StatusOK)
+			if got.ID != chirp.ID {
+				t.Errorf("got chirp %s, want %s", got.ID, chirp.ID)
+			}
+		})
+	}
+}
+
+func TestDeprecatedRoutes(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+	update := map[string]string{"email": "heisenberg@breakingbad.com"}
+
+	tests := []struct {
+		path      string
+		successor string
+	}{
+		{"/api/users", "/api/v2/users"},
+		{"/api/v1/users", "/api/v2/users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp := ts.do("PUT", tt.path, walt.Token, update)
+			if got, want := resp.Header.Get("Deprecation"), "@1792368000"; got != want {
+				t.Errorf("got Deprecation %q, want %q", got, want)
+			}
+			if got, want := resp.Header.Get("Sunset"), "Mon, 19 Apr 2027 00:00:00 GMT"; got != want {
+				t.Errorf("got Sunset %q, want %q", got, want)
+			}
+			if got, want := resp.Header.Get("Link"), `<`+tt.successor+`>; rel="successor-version"`; got != want {
+				t.Errorf("got Link %q, want %q", got, want)
+			}
+			user := expect[User](t, resp, http.StatusOK)
+			if user.Token != walt.Token {
+				t.Error("v1 stopped returning the access token")
+			}
+		})
+	}
+
+	metrics := ts.body("/metrics")
+	for _, want := range []string{
+		`chirpy_deprecated_requests_total{route="POST /api/users"} 1`,
+		`chirpy_deprecated_requests_total{route="POST /api/login"} 1`,
+		`chirpy_deprecated_requests_total{route="PUT /api/users"} 1`,
+		`chirpy_deprecated_requests_total{route="PUT /api/v1/users"} 1`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("/metrics doesn't contain %s", want)
+		}
+	}
+}
+
+func TestUsersV2(t *testing.T) {
+	ts := newTestServer(t)
+	credentials := map[string]string{"email": "walt@breakingbad.com", "password": testPassword}
+
+	resp := ts.do("POST", "/api/v2/users", "", credentials)
+	if got := resp.Header.Get("Deprecation"); got != "" {
+		t.Errorf("got Deprecation %q from v2", got)
+	}
+	created := expect[map[string]any](t, resp, http.StatusCreated)
+	if _, ok := created["token"]; ok {
+		t.Errorf("v2 user has a token: %v", created)
+	}
+
+	_ = expect[problem](t, ts.do("POST", "/api/v2/login", "", map[string]string{"email": "walt@breakingbad.com", "password": "wrong"}), http.StatusUnauthorized)
+	session := expect[Session](t, ts.do("POST", "/api/v2/login", "", credentials), http.StatusOK)
+	if session.Token == "" || session.RefreshToken == "" {
+		t.Fatalf("got session %+v without tokens", session)
+	}
+	if session.User.ID.String() != created["id"] {
+		t.Errorf("logged in as %s, want %v", session.User.ID, created["id"])
+	}
+
+	_ = expect[problem](t, ts.do("PUT", "/api/v2/users", "", map[string]string{"email": "heisenberg@breakingbad.com"}), http.StatusUnauthorized)
+	updated := expect[map[string]any](t, ts.do("PUT", "/api/v2/users", session.Token, map[string]string{"email": "heisenberg@breakingbad.com"}), http.StatusOK)
+	if updated["email"] != "heisenberg@breakingbad.com" {
+		t.Errorf("got email %v after updating it", updated["email"])
+	}
+	for _, field := range []string{"token", "refresh_token"} {
+		if _, ok := updated[field]; ok {
+			t.Errorf("v2 update returned %s", field)
+		}
+	}
+
+	// Tokens from either version work with the other
+	ts.chirp(session.Token, "I am the one who knocks")
+}
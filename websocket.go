@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/database"
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsSendBuffer       = 64
+	wsWriteWait        = 10 * time.Second
+	wsPongWait         = 60 * time.Second
+	wsPingPeriod       = wsPongWait * 9 / 10
+	wsMaxMessageSize   = 4096
+	wsMaxSubscriptions = 50
+)
+
+const (
+	channelTimeline = "timeline"
+	channelHashtag  = "hashtag"
+	channelAuthor   = "author"
+	channelThread   = "thread"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// wsRequest is a message from the client, e.g.
+// {"type": "subscribe", "channel": "hashtag", "value": "golang"}.
+type wsRequest struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	Value   string `json:"value"`
+}
+
+type wsResponse struct {
+	Type    string          `json:"type"`
+	Channel string          `json:"channel,omitempty"`
+	Value   string          `json:"value,omitempty"`
+	ID      int64           `json:"id,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// wsClient is one WebSocket connection. Events addressed to the user are
+// always delivered; public events are delivered when they match one of the
+// connection's channel subscriptions.
+type wsClient struct {
+	conn   *websocket.Conn
+	filter *eventFilter
+	send   chan wsResponse
+
+	timeline bool
+	hashtags map[string]bool
+	authors  map[uuid.UUID]bool
+	threads  map[uuid.UUID]bool
+
+	// closeCode and closeReason are set before send is closed and read by
+	// the writer when it sends the close frame
+	closeCode   int
+	closeReason string
+}
+
+func (cfg *apiConfig) websocket(w http.ResponseWriter, r *http.Request) {
+	// Browsers can't set headers on a WebSocket handshake, so the access
+	// token may also come from the query string
+	if r.Header.Get("Authorization") == "" && r.URL.Query().Get("access_token") != "" {
+		r.Header.Set("Authorization", "Bearer "+r.URL.Query().Get("access_token"))
+	}
+	id, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := cfg.newEventFilter(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, errInternal, "Couldn't load blocks", err)
+		return
+	}
+
+	// Upgrade writes its own error response on failure
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	client := &wsClient{
+		conn:      conn,
+		filter:    filter,
+		send:      make(chan wsResponse, wsSendBuffer),
+		hashtags:  map[string]bool{},
+		authors:   map[uuid.UUID]bool{},
+		threads:   map[uuid.UUID]bool{},
+		closeCode: websocket.CloseNormalClosure,
+	}
+	client.run(r.Context(), cfg.events)
+}
+
+func (c *wsClient) run(ctx context.Context, hub *events.Hub) {
+	defer c.conn.Close()
+
+	sub := hub.Subscribe()
+	defer sub.Close()
+
+	written := make(chan struct{})
+	go func() {
+		c.writePump()
+		close(written)
+	}()
+
+	requests := make(chan wsRequest)
+	readDone := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go c.readPump(requests, readDone, stop)
+
+	refresh := time.NewTicker(heartbeatInterval)
+	defer refresh.Stop()
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			c.closeCode = websocket.CloseGoingAway
+			c.closeReason = "server shutting down"
+			break loop
+		case <-readDone:
+			break loop
+		case req := <-requests:
+			if !c.enqueue(c.handle(req)) {
+				break loop
+			}
+		case ev, ok := <-sub.Events:
+			if !ok {
+				c.closeCode = websocket.CloseTryAgainLater
+				c.closeReason = "event stream fell behind"
+				break loop
+			}
+			if !c.matches(ev) {
+				continue
+			}
+			msg := wsResponse{
+				Type:  "event",
+				ID:    ev.ID,
+				Event: ev.Type,
+				Data:  ev.Payload,
+			}
+			if !c.enqueue(msg) {
+				break loop
+			}
+		case <-refresh.C:
+			c.filter.refresh(ctx)
+		}
+	}
+
+	// The writer drains what's queued, sends the close frame and returns.
+	// Closing the connection afterwards unblocks the reader.
+	close(c.send)
+	<-written
+}
+
+// enqueue queues a message without blocking. A client that lets its send
+// buffer fill up is disconnected rather than holding up the event loop.
+func (c *wsClient) enqueue(msg wsResponse) bool {
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		c.closeCode = websocket.CloseTryAgainLater
+		c.closeReason = "send buffer full"
+		return false
+	}
+}
+
+func (c *wsClient) writePump() {
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(c.closeCode, c.closeReason),
+				)
+				return
+			}
+			if err := c.conn.WriteJSON(msg); err != nil {
+				// Keep draining so run can finish closing the channel
+				for range c.send {
+				}
+				return
+			}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				for range c.send {
+				}
+				return
+			}
+		}
+	}
+}
+
+func (c *wsClient) readPump(requests chan<- wsRequest, done chan<- struct{}, stop <-chan struct{}) {
+	defer close(done)
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		req := wsRequest{}
+		err := c.conn.ReadJSON(&req)
+		if err != nil {
+			return
+		}
+		select {
+		case requests <- req:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// handle applies a subscribe or unsubscribe request and returns the reply.
+func (c *wsClient) handle(req wsRequest) wsResponse {
+	subscribe := req.Type == "subscribe"
+	if !subscribe && req.Type != "unsubscribe" {
+		return wsResponse{Type: "error", Error: "Unknown request type"}
+	}
+	if subscribe && c.subscriptions() >= wsMaxSubscriptions {
+		return wsResponse{Type: "error", Channel: req.Channel, Value: req.Value, Error: "Too many subscriptions"}
+	}
+
+	switch req.Channel {
+	case channelTimeline:
+		c.timeline = subscribe
+	case channelHashtag:
+		tag := strings.ToLower(strings.TrimPrefix(req.Value, "#"))
+		if tag == "" {
+			return wsResponse{Type: "error", Channel: req.Channel, Error: "Missing hashtag"}
+		}
+		setMember(c.hashtags, tag, subscribe)
+	case channelAuthor, channelThread:
+		id, err := uuid.Parse(req.Value)
+		if err != nil {
+			return wsResponse{Type: "error", Channel: req.Channel, Value: req.Value, Error: "Invalid uuid"}
+		}
+		if req.Channel == channelAuthor {
+			setMember(c.authors, id, subscribe)
+		} else {
+			setMember(c.threads, id, subscribe)
+		}
+	default:
+		return wsResponse{Type: "error", Channel: req.Channel, Error: "Unknown channel"}
+	}
+
+	return wsResponse{Type: req.Type + "d", Channel: req.Channel, Value: req.Value}
+}
+
+func (c *wsClient) subscriptions() int {
+	count := len(c.hashtags) + len(c.authors) + len(c.threads)
+	if c.timeline {
+		count++
+	}
+	return count
+}
+
+func (c *wsClient) matches(ev database.Event) bool {
+	if !c.filter.visible(ev) {
+		return false
+	}
+	// Addressed to this user; the filter already checked the recipient
+	if ev.UserID.Valid {
+		return true
+	}
+
+	if ev.Type != events.ChirpCreated && ev.Type != events.ChirpDeleted {
+		return false
+	}
+	if c.timeline {
+		return true
+	}
+	if ev.ActorID.Valid && c.authors[ev.ActorID.UUID] {
+		return true
+	}
+
+	chirp := Chirp{}
+	err := json.Unmarshal(ev.Payload, &chirp)
+	if err != nil {
+		return false
+	}
+	if c.threads[chirp.ID] {
+		return true
+	}
+	for _, tag := range hashtags(chirp.Body) {
+		if c.hashtags[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+func setMember[K comparable](set map[K]bool, key K, member bool) {
+	if member {
+		set[key] = true
+	} else {
+		delete(set, key)
+	}
+}
+
+// hashtags returns the lower-cased tags in a chirp body, without the '#'.
+func hashtags(body string) []string {
+	var tags []string
+	for _, word := range strings.Fields(body) {
+		if !strings.HasPrefix(word, "#") {
+			continue
+		}
+		tag := strings.ToLower(strings.TrimRight(word[1:], ".,!?;:"))
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Brandon-Butterbaugh/Chirbooty.git/internal/events"
+	"github.com/gorilla/websocket"
+)
+
+// pollEvents delivers published events to subscribers until the returned
+// function is called or the test ends, as the hub does in main.
+func (ts *testServer) pollEvents() (stop func()) {
+	ctx, cancel := context.WithCancel(ts.t.Context())
+	done := make(chan struct{})
+	go func() {
+		ts.cfg.events.Poll(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	stop = func() {
+		cancel()
+		<-done
+	}
+	ts.t.Cleanup(stop)
+	return stop
+}
+
+// dialWS opens /api/ws with the access token in the Authorization header.
+func (ts *testServer) dialWS(token string) (*websocket.Conn, *http.Response, error) {
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/ws", header)
+	if conn != nil {
+		ts.t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// wsSend sends a request and returns the reply.
+func wsSend(t *testing.T, conn *websocket.Conn, req wsRequest) wsResponse {
+	t.Helper()
+	err := conn.WriteJSON(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wsRead(t, conn)
+}
+
+func wsRead(t *testing.T, conn *websocket.Conn) wsResponse {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg wsResponse
+	err := conn.ReadJSON(&msg)
+	if err != nil {
+		t.Fatalf("reading from the WebSocket: %v", err)
+	}
+	return msg
+}
+
+// wsNextChirp reads up to the next chirp event, skipping events addressed
+// to the user, and returns the chirp.
+func wsNextChirp(t *testing.T, conn *websocket.Conn) (string, Chirp) {
+	t.Helper()
+	for {
+		msg := wsRead(t, conn)
+		if msg.Type != "event" || !strings.HasPrefix(msg.Event, "chirp.") {
+			continue
+		}
+		var chirp Chirp
+		err := json.Unmarshal(msg.Data, &chirp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg.Event, chirp
+	}
+}
+
+func TestWebSocketAuth(t *testing.T) {
+	ts := newTestServer(t)
+	walt := ts.signUp("walt@breakingbad.com")
+
+	tests := []struct {
+		name     string
+		token    string
+		query    string
+		wantCode int
+	}{
+		{name: "No token", wantCode: http.StatusUnauthorized},
+		{name: "Invalid token", token: "heisenberg", wantCode: http.StatusUnauthorized},
+		{name: "Header", token: walt.Token, wantCode: http.StatusSwitchingProtocols},
+		// Browsers can't set headers on the handshake
+		{name: "Query", query: "?access_token=" + walt.Token, wantCode: http.StatusSwitchingProtocols},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.token != "" {
+				header.Set("Authorization", "Bearer "+tt.token)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/ws"+tt.query, header)
+			if conn != nil {
+				conn.Close()
+			}
+			if resp == nil {
+				t.Fatalf("no handshake response: %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestWebSocketSubscriptions(t *testing.T) {
+	ts := newTestServer(t)
+	ts.pollEvents()
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+	conn, _, err := ts.dialWS(jesse.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replies := []struct {
+		name string
+		req  wsRequest
+		want wsResponse
+	}{
+		{
+			name: "Hashtag",
+			req:  wsRequest{Type: "subscribe", Channel: channelHashtag, Value: "#Science"},
+			want: wsResponse{Type: "subscribed", Channel: channelHashtag, Value: "#Science"},
+		},
+		{
+			name: "Unknown type",
+			req:  wsRequest{Type: "shout", Channel: channelTimeline},
+			want: wsResponse{Type: "error", Error: "Unknown request type"},
+		},
+		{
+			name: "Unknown channel",
+			req:  wsRequest{Type: "subscribe", Channel: "lab"},
+			want: wsResponse{Type: "error", Channel: "lab", Error: "Unknown channel"},
+		},
+		{
+			name: "Invalid author",
+			req:  wsRequest{Type: "subscribe", Channel: channelAuthor, Value: "heisenberg"},
+			want: wsResponse{Type: "error", Channel: channelAuthor, Value: "heisenberg", Error: "Invalid uuid"},
+		},
+	}
+	for _, tt := range replies {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wsSend(t, conn, tt.req)
+			if got.Type != tt.want.Type || got.Channel != tt.want.Channel || got.Value != tt.want.Value || got.Error != tt.want.Error {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Only chirps on a subscribed channel come through
+	ts.chirp(walt.Token, "Say my name")
+	tagged := ts.chirp(walt.Token, "Yeah #science!")
+	if event, got := wsNextChirp(t, conn); event != events.ChirpCreated || got.ID != tagged.ID {
+		t.Errorf("got %s of %s, want the tagged chirp created", event, got.ID)
+	}
+
+	wsSend(t, conn, wsRequest{Type: "unsubscribe", Channel: channelHashtag, Value: "science"})
+	wsSend(t, conn, wsRequest{Type: "subscribe", Channel: channelAuthor, Value: jesse.ID.String()})
+	ts.chirp(walt.Token, "More #science")
+	own := ts.chirp(jesse.Token, "Yo")
+	if _, got := wsNextChirp(t, conn); got.ID != own.ID {
+		t.Errorf("got chirp %s after unsubscribing, want %s", got.ID, own.ID)
+	}
+}
+
+func TestWebSocketHidesBlockedAndMuted(t *testing.T) {
+	ts := newTestServer(t)
+	ts.pollEvents()
+	walt := ts.signUp("walt@breakingbad.com")
+	jesse := ts.signUp("jesse@breakingbad.com")
+	gus := ts.signUp("gus@lospolloshermanos.com")
+	hank := ts.signUp("hank@dea.gov")
+	expect[any](t, ts.do("POST", "/api/blocks", jesse.Token, map[string]string{"user_id": gus.ID.String()}), http.StatusNoContent)
+	expect[any](t, ts.do("POST", "/api/mutes", jesse.Token, map[string]string{"user_id": hank.ID.String()}), http.StatusNoContent)
+
+	conn, _, err := ts.dialWS(jesse.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsSend(t, conn, wsRequest{Type: "subscribe", Channel: channelTimeline})
+
+	ts.chirp(gus.Token, "I hide in plain sight")
+	ts.chirp(hank.Token, "They're minerals")
+	visible := ts.chirp(walt.Token, "Say my name")
+	if _, got := wsNextChirp(t, conn); got.ID != visible.ID {
+		t.Errorf("got chirp %s, want only %s from someone not blocked or muted", got.ID, visible.ID)
+	}
+}
+
+func TestWebSocketClosesWhenDropped(t *testing.T) {
+	ts := newTestServer(t)
+	stop := ts.pollEvents()
+	jesse := ts.signUp("jesse@breakingbad.com")
+	conn, _, err := ts.dialWS(jesse.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsSend(t, conn, wsRequest{Type: "subscribe", Channel: channelTimeline})
+
+	// The hub drops a slow consumer by closing its subscription, as it does
+	// for every subscriber when it stops
+	stop()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err = conn.ReadMessage()
+		if err != nil {
+			break
+		}
+	}
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatalf("got %v, want a close frame", err)
+	}
+	if closeErr.Code != websocket.CloseTryAgainLater {
+		t.Errorf("got close code %d, want %d", closeErr.Code, websocket.CloseTryAgainLater)
+	}
+}